@@ -16,7 +16,10 @@ var baseURL string
 var monitorCmd = &cobra.Command{
 	Use:   "monitor",
 	Short: "Monitor events of the engine",
-	Long:  `Monitor the engine for games, system or other events.`,
+	Long: `Monitor the engine for games, system or other events.
+
+Connected to the system websocket, every line is a command of the admin REPL,
+type help for the list of commands.`,
 	Run: func(cmd *cobra.Command, args []string) {
 
 		// Initialize the engine with the secret
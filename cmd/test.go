@@ -10,14 +10,16 @@ package cmd
 
 void* clips_create();
 void clips_destroy(void*);
-void clips_load(void*, const char*);
+char* clips_load(void*, const char*);
 void clips_reset(void*);
-void clips_run(void*);
+int clips_run(void*, long long);
 void clips_assert(void*, const char*);
+void clips_free_string(void*, char*);
 */
 import "C"
 import (
 	"os"
+	"strings"
 	"unsafe"
 
 	"github.com/spf13/cobra"
@@ -46,11 +48,16 @@ var testCmd = &cobra.Command{
 				if !file.IsDir() {
 					cfile := C.CString(rulePool + "/" + file.Name())
 					defer C.free(unsafe.Pointer(cfile))
-					C.clips_load(env, cfile)
+					if cErrors := C.clips_load(env, cfile); cErrors != nil {
+						cmd.Println("Failed to load rule file:", file.Name())
+						cmd.Println(strings.NewReplacer("\x1f", ": ", "\x1e", "\n").Replace(C.GoString(cErrors)))
+						C.clips_free_string(env, cErrors)
+						return
+					}
 				}
 			}
 			C.clips_reset(env)
-			C.clips_run(env)
+			C.clips_run(env, -1)
 
 			if testFiles, err := os.ReadDir(testPool); err != nil {
 				cmd.Println("Failed to read test pool directory:", err.Error())
@@ -66,7 +73,7 @@ var testCmd = &cobra.Command{
 						}
 						fact := C.CString(string(testBytes))
 						C.clips_assert(env, fact)
-						C.clips_run(env)
+						C.clips_run(env, -1)
 						C.free(unsafe.Pointer(fact))
 					}
 				}
@@ -0,0 +1,270 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	jwtauth "github.com/go-chi/jwtauth/v5"
+)
+
+// Who can watch a room
+const (
+	WatchAnyone  = "anyone"  // any client that knows the room, whatever its visibility and password
+	WatchInvited = "invited" // the clients that could join it
+	WatchNobody  = "nobody"  // nobody, the game is only seen by its players
+)
+
+// Invite codes are made of letters and digits that cannot be mistaken for each other, shown in two halves
+const (
+	inviteAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	inviteLength   = 8
+	maxPasswordLen = 128
+)
+
+// RoomAccess are the access settings of a room, chosen when it is created
+type RoomAccess struct {
+	Visibility string `json:"visibility"` // public (default), unlisted or private
+	Password   string `json:"password"`   // optional, required to join unless an invite code is given
+	Watch      string `json:"watch"`      // anyone, invited (default) or nobody
+}
+
+// RoomCredentials are what a client presents to join or watch a room
+type RoomCredentials struct {
+	Invite   string `json:"invite"`
+	Password string `json:"password"`
+}
+
+// validate checks the access settings and fills in the defaults
+func (a *RoomAccess) validate() []FieldError {
+	fields := make([]FieldError, 0)
+	switch a.Visibility {
+	case "":
+		a.Visibility = VisibilityPublic
+	case VisibilityPublic, VisibilityUnlisted, VisibilityPrivate:
+	default:
+		fields = append(fields, FieldError{Path: "visibility", Message: "must be one of public, unlisted, private"})
+	}
+	switch a.Watch {
+	case "":
+		a.Watch = WatchInvited
+	case WatchAnyone, WatchInvited, WatchNobody:
+	default:
+		fields = append(fields, FieldError{Path: "watch", Message: "must be one of anyone, invited, nobody"})
+	}
+	if len(a.Password) > maxPasswordLen {
+		fields = append(fields, FieldError{Path: "password", Message: "must be at most 128 characters long"})
+	}
+	return fields
+}
+
+// readRoomCredentials decodes the optional credentials of a join or watch request
+func readRoomCredentials(r *http.Request) (RoomCredentials, error) {
+	var creds RoomCredentials
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil && !errors.Is(err, io.EOF) {
+		return creds, err
+	}
+	return creds, nil
+}
+
+// newInviteCode returns a random invite code, as XXXX-XXXX
+func newInviteCode() (string, error) {
+	b := make([]byte, inviteLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := make([]byte, 0, inviteLength+1)
+	for i, c := range b {
+		if i == inviteLength/2 {
+			code = append(code, '-')
+		}
+		code = append(code, inviteAlphabet[int(c)%len(inviteAlphabet)])
+	}
+	return string(code), nil
+}
+
+// normalizeInviteCode lets the clients type invite codes in lowercase, with or without the dash and spaces
+func normalizeInviteCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) != inviteLength {
+		return code
+	}
+	return code[:inviteLength/2] + "-" + code[inviteLength/2:]
+}
+
+// hashPassword salts and hashes a room password
+func hashPassword(salt []byte, password string) []byte {
+	sum := sha256.Sum256(append(append([]byte{}, salt...), password...))
+	return sum[:]
+}
+
+// setAccess applies the access settings to a room being created
+func (r *Room) setAccess(access RoomAccess, owner, invite string) error {
+	r.visibility = access.Visibility
+	r.watch = access.Watch
+	r.owner = owner
+	r.invite = invite
+	if access.Password != "" {
+		r.passwordSalt = make([]byte, 16)
+		if _, err := rand.Read(r.passwordSalt); err != nil {
+			return err
+		}
+		r.passwordHash = hashPassword(r.passwordSalt, access.Password)
+	}
+	return nil
+}
+
+// roomVisibility returns the visibility of the room, rooms built without settings are public
+func (r *Room) roomVisibility() string {
+	if r.visibility == "" {
+		return VisibilityPublic
+	}
+	return r.visibility
+}
+
+// listed tells whether the room is shown in the lobby
+func (r *Room) listed() bool {
+	return r.roomVisibility() == VisibilityPublic
+}
+
+// openToStrangers tells whether anybody can be dropped in the room by the join of the first available room
+func (r *Room) openToStrangers() bool {
+	return r.listed() && r.passwordHash == nil
+}
+
+func (r *Room) checkPassword(password string) bool {
+	return r.passwordHash != nil && subtle.ConstantTimeCompare(hashPassword(r.passwordSalt, password), r.passwordHash) == 1
+}
+
+// admit checks whether a client can join the room, or watch it, with the given credentials. The admin and the
+// client that created the room are always let in, a valid invite code opens any room.
+func (r *Room) admit(clientID string, creds RoomCredentials, watching bool) *CommandError {
+	if clientID == "admin" || (r.owner != "" && clientID == r.owner) {
+		return nil
+	}
+	if watching {
+		switch r.watch {
+		case WatchNobody:
+			return &CommandError{Status: http.StatusForbidden, Message: "room cannot be watched"}
+		case WatchAnyone:
+			return nil
+		}
+	}
+	if r.invite != "" && normalizeInviteCode(creds.Invite) == r.invite {
+		return nil
+	}
+	if r.visibility == VisibilityPrivate && (r.passwordHash == nil || creds.Password == "") {
+		return &CommandError{Status: http.StatusForbidden, Message: "invite code required"}
+	}
+	if r.passwordHash != nil && !r.checkPassword(creds.Password) {
+		return &CommandError{Status: http.StatusForbidden, Message: "wrong password"}
+	}
+	return nil
+}
+
+// member tells whether a client created, plays or watches the room
+func (r *Room) member(clientID string) bool {
+	if r.owner != "" && clientID == r.owner {
+		return true
+	}
+	r.clientsMutex.RLock()
+	_, playing := r.clients[clientID]
+	r.clientsMutex.RUnlock()
+	r.watchersMutex.RLock()
+	_, watching := r.watchers[clientID]
+	r.watchersMutex.RUnlock()
+	return playing || watching
+}
+
+// accessInfo describes the access settings of a room, the password itself is never told
+func (r *Room) accessInfo() map[string]any {
+	watch := r.watch
+	if watch == "" {
+		watch = WatchInvited
+	}
+	return map[string]any{
+		"visibility": r.roomVisibility(),
+		"watch":      watch,
+		"owner":      r.owner,
+		"invite":     r.invite,
+		"password":   r.passwordHash != nil,
+	}
+}
+
+// generateInviteCode returns an invite code no other room uses, the caller holds roomsMutex
+func (e *Engine) generateInviteCode() (string, error) {
+	for {
+		code, err := newInviteCode()
+		if err != nil {
+			return "", err
+		}
+		if _, exists := e.invites[code]; !exists {
+			return code, nil
+		}
+	}
+}
+
+func (e *Engine) searchInvite(code string) (*Room, error) {
+	e.roomsMutex.RLock()
+	defer e.roomsMutex.RUnlock()
+	if id, exists := e.invites[normalizeInviteCode(code)]; exists {
+		if room, exists := e.rooms[id]; exists {
+			return room, nil
+		}
+	}
+	return nil, errors.New("invite not found")
+}
+
+// requestedRoom returns the room of a join or watch request, named by id or by invite code. The invite code of
+// the URL is added to the credentials.
+func (e *Engine) requestedRoom(r *http.Request, idParam string, creds *RoomCredentials) (*Room, error) {
+	if code := chi.URLParam(r, "code"); code != "" {
+		creds.Invite = code
+		return e.searchInvite(code)
+	}
+	return e.searchRoom(chi.URLParam(r, idParam))
+}
+
+// apiRoomInvite tells the invite code of a room to its creator and its players
+func (e *Engine) apiRoomInvite(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	} else if clientID, ok := claims["id"].(string); !ok {
+		Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	} else if room, err := e.searchRoom(id); err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiRoomInvite]")+" ", 0)
+			l.Printf("Room not found: %s", id)
+		}
+		Error(w, http.StatusNotFound, "room not found")
+		return
+	} else {
+		room.clientsMutex.RLock()
+		_, playing := room.clients[clientID]
+		room.clientsMutex.RUnlock()
+		if !playing && clientID != "admin" && clientID != room.owner {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiRoomInvite]")+" ", 0)
+				l.Printf("Forbidden invite request in room %s by %s", room.id, clientID)
+			}
+			Error(w, http.StatusForbidden, "forbidden")
+			return
+		}
+		JSON(w, http.StatusOK, map[string]string{"room_id": room.id, "invite": room.invite})
+	}
+}
@@ -0,0 +1,130 @@
+package rulemancer
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRoomAdmit(t *testing.T) {
+	newRoom := func(access RoomAccess) *Room {
+		room, _ := newEventsTestRoom(&Game{id: "tictactoe"})
+		if fields := access.validate(); len(fields) > 0 {
+			t.Fatalf("invalid access settings: %v", fields)
+		}
+		if err := room.setAccess(access, "owner", "ABCD-EFGH"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return room
+	}
+
+	tests := []struct {
+		name     string
+		access   RoomAccess
+		client   string
+		creds    RoomCredentials
+		watching bool
+		message  string
+	}{
+		{name: "public", access: RoomAccess{}, client: "bob"},
+		{name: "unlisted", access: RoomAccess{Visibility: VisibilityUnlisted}, client: "bob"},
+		{name: "private without invite", access: RoomAccess{Visibility: VisibilityPrivate}, client: "bob", message: "invite code required"},
+		{name: "private with invite", access: RoomAccess{Visibility: VisibilityPrivate}, client: "bob", creds: RoomCredentials{Invite: "abcd efgh"}},
+		{name: "private with wrong invite", access: RoomAccess{Visibility: VisibilityPrivate}, client: "bob", creds: RoomCredentials{Invite: "ABCD-EFGK"}, message: "invite code required"},
+		{name: "private owner", access: RoomAccess{Visibility: VisibilityPrivate}, client: "owner"},
+		{name: "private admin", access: RoomAccess{Visibility: VisibilityPrivate}, client: "admin"},
+		{name: "private with password", access: RoomAccess{Visibility: VisibilityPrivate, Password: "secret"}, client: "bob", creds: RoomCredentials{Password: "secret"}},
+		{name: "password missing", access: RoomAccess{Password: "secret"}, client: "bob", message: "wrong password"},
+		{name: "password wrong", access: RoomAccess{Password: "secret"}, client: "bob", creds: RoomCredentials{Password: "guess"}, message: "wrong password"},
+		{name: "password bypassed by invite", access: RoomAccess{Password: "secret"}, client: "bob", creds: RoomCredentials{Invite: "ABCDEFGH"}},
+		{name: "watch invited", access: RoomAccess{Visibility: VisibilityPrivate}, client: "bob", watching: true, message: "invite code required"},
+		{name: "watch anyone", access: RoomAccess{Visibility: VisibilityPrivate, Password: "secret", Watch: WatchAnyone}, client: "bob", watching: true},
+		{name: "watch nobody", access: RoomAccess{Watch: WatchNobody}, client: "bob", creds: RoomCredentials{Invite: "ABCD-EFGH"}, watching: true, message: "room cannot be watched"},
+		{name: "watch nobody joins", access: RoomAccess{Watch: WatchNobody}, client: "bob"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ce := newRoom(tt.access).admit(tt.client, tt.creds, tt.watching)
+			if tt.message == "" && ce != nil {
+				t.Errorf("unexpected refusal: %s", ce.Message)
+			} else if tt.message != "" && (ce == nil || ce.Message != tt.message) {
+				t.Errorf("expected %q, got %v", tt.message, ce)
+			}
+		})
+	}
+}
+
+func TestRoomAccessValidate(t *testing.T) {
+	access := RoomAccess{}
+	if fields := access.validate(); len(fields) != 0 || access.Visibility != VisibilityPublic || access.Watch != WatchInvited {
+		t.Errorf("unexpected defaults %+v, %v", access, fields)
+	}
+	access = RoomAccess{Visibility: "hidden", Watch: "friends", Password: string(make([]byte, 129))}
+	fields := access.validate()
+	if len(fields) != 3 || fields[0].Path != "visibility" || fields[1].Path != "watch" || fields[2].Path != "password" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+}
+
+func TestInviteCode(t *testing.T) {
+	format := regexp.MustCompile(`^[A-HJ-NP-Z2-9]{4}-[A-HJ-NP-Z2-9]{4}$`)
+	for i := 0; i < 50; i++ {
+		if code, err := newInviteCode(); err != nil || !format.MatchString(code) {
+			t.Fatalf("unexpected invite code %q, %v", code, err)
+		} else if normalizeInviteCode(" "+code[:4]+code[5:]+" ") != code {
+			t.Errorf("%q does not survive normalization", code)
+		}
+	}
+
+	e := NewEngine("secret")
+	room, _ := newEventsTestRoom(&Game{id: "tictactoe"})
+	room.invite = "ABCD-EFGH"
+	e.rooms[room.id] = room
+	e.invites[room.invite] = room.id
+	if found, err := e.searchInvite("abcdefgh"); err != nil || found != room {
+		t.Errorf("the room was not found by its invite code: %v", err)
+	}
+	if _, err := e.searchInvite("ABCD-EFGK"); err == nil {
+		t.Errorf("an unknown invite code must not be found")
+	}
+}
+
+func TestLobbyHidesPrivateRooms(t *testing.T) {
+	e := NewEngine("secret")
+	for id, visibility := range map[string]string{"a": VisibilityPublic, "b": VisibilityUnlisted, "c": VisibilityPrivate} {
+		room, _ := newEventsTestRoom(&Game{id: "tictactoe"})
+		room.id = id
+		room.maxClients = 2
+		room.visibility = visibility
+		room.lobby = newEventHub("", 8)
+		e.rooms[id] = room
+	}
+	e.rooms["b"].owner = "bob"
+	e.rooms["c"].clients["carol"] = &Client{id: "carol"}
+
+	tests := []struct {
+		client string
+		rooms  string
+	}{
+		{client: "", rooms: "a"},
+		{client: "alice", rooms: "a"},
+		{client: "bob", rooms: "a,b"},
+		{client: "carol", rooms: "a,c"},
+	}
+	for _, tt := range tests {
+		ids := make([]string, 0)
+		for _, room := range e.lobbyRooms(tt.client) {
+			ids = append(ids, room.Room)
+		}
+		if got := strings.Join(ids, ","); got != tt.rooms {
+			t.Errorf("%q: expected %s, got %s", tt.client, tt.rooms, got)
+		}
+	}
+
+	lobby, _, _, _ := e.rooms["c"].lobby.subscribe("lobby", "", false, 0)
+	e.rooms["c"].emit(EventPlayerJoined, "carol", map[string]string{"client": "carol"})
+	if events := receivedEvents(t, lobby.ch); len(events) != 0 {
+		t.Errorf("a private room must not be announced in the lobby, got %v", events)
+	}
+}
@@ -0,0 +1,297 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	jwtauth "github.com/go-chi/jwtauth/v5"
+)
+
+// botMoveLimit is the number of moves a bot plays in a row, without any other player acting, before it stops
+// to wait for them. It keeps a bot whose moves are refused from playing forever.
+const botMoveLimit = 10
+
+// Bot is a player owned by the engine, it plays its turns through the same assertions as the clients
+type Bot struct {
+	client   *Client
+	room     *Room
+	strategy string
+	play     BotStrategy
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// AddBotRequest is the optional body of a request adding a bot to a room
+type AddBotRequest struct {
+	Strategy string `json:"strategy"` // random by default
+	Name     string `json:"name"`
+}
+
+func (b *Bot) Info() map[string]any {
+	return map[string]any{
+		"client":   b.client.id,
+		"name":     b.client.name,
+		"strategy": b.strategy,
+	}
+}
+
+// stopBots makes every bot of the room leave
+func (r *Room) stopBots() {
+	r.clientsMutex.RLock()
+	defer r.clientsMutex.RUnlock()
+	for _, b := range r.bots {
+		b.stopOnce.Do(func() { close(b.stop) })
+	}
+}
+
+// botsInfo describes the bots of the room, the caller holds clientsMutex
+func (r *Room) botsInfo() []map[string]any {
+	bots := make([]map[string]any, 0, len(r.bots))
+	for _, b := range r.bots {
+		bots = append(bots, b.Info())
+	}
+	return bots
+}
+
+// addBot seats a new bot using the given strategy on the first free seat of the room
+func (e *Engine) addBot(room *Room, strategy, name string) (*Bot, *CommandError) {
+	return e.startBot(room, strategy, name, func(client *Client) *CommandError { return e.seatClient(room, client) })
+}
+
+// startBot creates a bot using the given strategy and starts it once seat has given it a seat in the room
+func (e *Engine) startBot(room *Room, strategy, name string, seat func(client *Client) *CommandError) (*Bot, *CommandError) {
+	if e.ClipsLessMode {
+		return nil, &CommandError{Status: http.StatusBadRequest, Message: "bots need CLIPS"}
+	}
+	strategies := room.game.botStrategyNames()
+	if len(strategies) == 0 {
+		return nil, &CommandError{Status: http.StatusUnprocessableEntity, Message: "game does not declare its moves, bots cannot play it"}
+	}
+	if strategy == "" {
+		strategy = "random"
+	}
+	if !isInSlice(strategies, strategy) {
+		return nil, &CommandError{Status: http.StatusBadRequest, Message: "invalid payload",
+			Fields: []FieldError{{Path: "strategy", Message: "must be one of " + strings.Join(strategies, ", ")}}}
+	}
+	if room.hasEnded() {
+		return nil, &CommandError{Status: http.StatusConflict, Message: "game has ended"}
+	}
+	if name == "" {
+		name = "bot-" + strategy
+	}
+
+	play, err := e.newBotStrategy(room, strategy)
+	if err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/startBot]")+" ", 0)
+			l.Printf("Failed to start the %s strategy in room %s: %v", strategy, room.id, err)
+		}
+		return nil, &CommandError{Status: http.StatusInternalServerError, Message: "failed to start the bot strategy"}
+	}
+	b := &Bot{
+		client:   e.newBotClient(name, "Bot playing with the "+strategy+" strategy"),
+		room:     room,
+		strategy: strategy,
+		play:     play,
+		stop:     make(chan struct{}),
+	}
+
+	// The bot listens to the room before taking its seat, so that it does not miss its first turn
+	sub, _, _, _ := room.events.subscribe("bot:"+b.client.id, "", false, 0)
+	if ce := seat(b.client); ce != nil {
+		room.events.unsubscribe(sub)
+		play.Close()
+		e.removeClient(b.client.id)
+		return nil, ce
+	}
+	room.clientsMutex.Lock()
+	if room.bots == nil {
+		room.bots = make(map[string]*Bot)
+	}
+	room.bots[b.client.id] = b
+	room.clientsMutex.Unlock()
+
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/startBot]")+" ", 0)
+		l.Printf("Bot %s (%s) joined room %s", b.client.id, strategy, room.id)
+	}
+	go e.runBot(b, sub)
+	return b, nil
+}
+
+// runBot plays the turns of a bot until it is stopped. The bot thinks when a player joins, when the state of
+// the room changes and when a paused game goes on.
+func (e *Engine) runBot(b *Bot, sub *subscriber) {
+	defer func() {
+		b.room.events.unsubscribe(sub)
+		b.play.Close()
+		e.removeClient(b.client.id)
+	}()
+
+	inARow := 0
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-sub.lagged:
+			// Some events were dropped, the state is read again anyway
+		case msg := <-sub.ch:
+			var event RoomEvent
+			if err := json.Unmarshal(msg.message, &event); err != nil {
+				continue
+			}
+			if event.Type != EventPlayerJoined && event.Type != EventStateChanged && event.Type != EventGameResumed {
+				continue
+			}
+			if event.Actor == b.client.id {
+				inARow++
+			} else {
+				inARow = 0
+			}
+		}
+		if inARow < botMoveLimit {
+			e.botTurn(b)
+		}
+	}
+}
+
+// botTurn plays a move if it is the turn of the bot: the room is full, the game is on and, for games with turns,
+// the turn relation names the seat of the bot
+func (e *Engine) botTurn(b *Bot) {
+	room := b.room
+	if room.hasEnded() || room.corruptedReason() != "" {
+		return
+	}
+	room.clientsMutex.RLock()
+	full := len(room.clients) == room.maxClients
+	seat := room.seats[b.client.id]
+	room.clientsMutex.RUnlock()
+	if !full {
+		return
+	}
+
+	ctx := context.Background()
+	if rel, slot := room.game.turnRelation(); rel != "" {
+		var raw string
+		if err := room.clipsInstance.Do(ctx, func() error {
+			var err error
+			raw, err = room.clipsInstance.QueryFactsAtomic(rel)
+			return err
+		}); err != nil {
+			return
+		}
+		if facts, err := genericFactToMap(e.Config, rel, raw); err != nil || activeSeat(facts, slot, room.game.seats) != seat {
+			return
+		}
+	}
+
+	select {
+	case <-b.stop:
+		return
+	case <-time.After(time.Duration(e.BotMoveDelayMs) * time.Millisecond):
+	}
+
+	move, ok, err := b.play.Move(ctx, room, seat)
+	if err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/botTurn]")+" ", 0)
+			l.Printf("Bot %s failed to choose a move in room %s: %v", b.client.id, room.id, err)
+		}
+		return
+	} else if !ok {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/botTurn]")+" ", 0)
+			l.Printf("Bot %s has no move to play in room %s", b.client.id, room.id)
+		}
+		return
+	}
+
+	if ce := e.canAssert(room, b.client.id); ce != nil {
+		return
+	}
+	if facts, ce := e.prepareAssertion(room, b.client.id, move.Assertion, move.Payload); ce != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/botTurn]")+" ", 0)
+			l.Printf("Bot %s chose an invalid move in room %s: %s %v", b.client.id, room.id, ce.Message, ce.Fields)
+		}
+	} else if _, ce := e.execAssertion(ctx, room, b.client.id, move.Assertion, facts); ce != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/botTurn]")+" ", 0)
+			l.Printf("Bot %s move failed in room %s: %s", b.client.id, room.id, ce.Message)
+		}
+	}
+}
+
+// apiAddBot seats a bot in a room, on behalf of its creator, of one of its players or of the admin
+func (e *Engine) apiAddBot(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	clientID, ok := claims["id"].(string)
+	if !ok {
+		Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	var req AddBotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiAddBot]")+" ", 0)
+			l.Printf("Invalid JSON: %v", err)
+		}
+		Error(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	if room, err := e.searchRoom(id); err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiAddBot]")+" ", 0)
+			l.Printf("Room not found: %s", id)
+		}
+		Error(w, http.StatusNotFound, "room not found")
+		return
+	} else {
+		room.clientsMutex.RLock()
+		_, playing := room.clients[clientID]
+		room.clientsMutex.RUnlock()
+		if !playing && clientID != "admin" && clientID != room.owner {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiAddBot]")+" ", 0)
+				l.Printf("Forbidden bot request in room %s by %s", room.id, clientID)
+			}
+			Error(w, http.StatusForbidden, "forbidden")
+			return
+		}
+
+		if b, ce := e.addBot(room, req.Strategy, req.Name); ce != nil {
+			CommandFailure(w, ce)
+			return
+		} else {
+			room.clientsMutex.RLock()
+			seat := room.seats[b.client.id]
+			room.clientsMutex.RUnlock()
+			JSON(w, http.StatusCreated, map[string]string{
+				"room_id":  room.id,
+				"client":   b.client.id,
+				"seat":     seat,
+				"strategy": b.strategy,
+			})
+		}
+	}
+}
@@ -0,0 +1,283 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Relations of the facts exchanged with the bot rule files: the engine asserts bot-turn with the seat of the bot,
+// the rules answer with a bot-move fact having the slots of the move relation
+const (
+	botTurnRelation = "bot-turn"
+	botMoveRelation = "bot-move"
+)
+
+// Directory of the bot rule files, inside the rules location of the game. Being a directory it is not loaded with
+// the game rules.
+const botRulesDir = "bots"
+
+// MoveSettings tell where the legal moves of a game are, declared with a game-moves fact. The facts of Relation
+// have the slots of the relation asserted by Assertion, SeatSlot tells the seat a move is for.
+type MoveSettings struct {
+	Assertion string `json:"assertion"`
+	Relation  string `json:"relation"`
+	SeatSlot  string `json:"seat_slot"`
+}
+
+// BotMove is a move chosen by a bot, the assertion and its payload as a client sends them. Seat is the seat
+// making the move.
+type BotMove struct {
+	Assertion string
+	Payload   map[string]json.RawMessage
+	Seat      string
+}
+
+// BotStrategy chooses the moves of a bot. Move is called on the turns of the bot, it returns false when the bot
+// has nothing to play. Close releases what the strategy holds, when the bot leaves.
+type BotStrategy interface {
+	Move(ctx context.Context, room *Room, seat string) (BotMove, bool, error)
+	Close()
+}
+
+// botStrategies are the strategies written in Go, available to every game declaring its legal moves. The bot
+// rule files of a game are available as strategies named after the file.
+var botStrategies = map[string]func(e *Engine, room *Room) (BotStrategy, error){
+	"random": newRandomStrategy,
+	"mcts":   newMCTSStrategy,
+}
+
+// parseGameMoves reads the optional game-moves fact
+func parseGameMoves(facts []map[string]string) (*MoveSettings, error) {
+	switch len(facts) {
+	case 0:
+		return nil, nil
+	case 1:
+	default:
+		return nil, errors.New("multiple game-moves facts found in the rules location")
+	}
+	moves := &MoveSettings{
+		Assertion: facts[0]["assertion"],
+		Relation:  facts[0]["relation"],
+		SeatSlot:  facts[0]["seat-slot"],
+	}
+	if moves.Assertion == "" || moves.Assertion == "nil" {
+		return nil, errors.New("game-moves missing assertion slot")
+	}
+	if moves.Relation == "" || moves.Relation == "nil" {
+		return nil, errors.New("game-moves missing relation slot")
+	}
+	if moves.SeatSlot == "" || moves.SeatSlot == "nil" {
+		moves.SeatSlot = "player"
+	}
+	return moves, nil
+}
+
+// checkMoves verifies that the moves can be asserted: the assertion has a single relation and the relation of the
+// legal moves is defined
+func checkMoves(moves *MoveSettings, assertable map[string][]string, templates map[string]*TemplateSchema) error {
+	if rels, ok := assertable[moves.Assertion]; !ok || len(rels) != 1 {
+		return fmt.Errorf("game-moves assertion %s must be an assertable with a single relation", moves.Assertion)
+	}
+	if _, ok := templates[moves.Relation]; !ok {
+		return fmt.Errorf("game-moves relation %s has no deftemplate", moves.Relation)
+	}
+	return nil
+}
+
+// listBotRules returns the names of the bot rule files of a game, sorted
+func listBotRules(rulesLocation string) []string {
+	names := make([]string, 0)
+	if files, err := os.ReadDir(filepath.Join(rulesLocation, botRulesDir)); err == nil {
+		for _, file := range files {
+			if !file.IsDir() && strings.HasSuffix(file.Name(), ".clp") {
+				names = append(names, strings.TrimSuffix(file.Name(), ".clp"))
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// botStrategyNames returns the strategies the bots of the game can use, none when the game does not declare its
+// moves. The search needs the relations ending the game to score its playouts.
+func (g *Game) botStrategyNames() []string {
+	names := make([]string, 0)
+	if g.moves == nil {
+		return names
+	}
+	for name := range botStrategies {
+		if name == "mcts" && len(g.endRelations) == 0 {
+			continue
+		}
+		names = append(names, name)
+	}
+	for _, name := range g.botRules {
+		if !isInSlice(names, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newBotStrategy returns the strategy with the given name for a bot of the room
+func (e *Engine) newBotStrategy(room *Room, name string) (BotStrategy, error) {
+	if newStrategy, ok := botStrategies[name]; ok {
+		return newStrategy(e, room)
+	}
+	if isInSlice(room.game.botRules, name) {
+		return newRuleStrategy(e, room, filepath.Join(room.game.rulesLocation, botRulesDir, name+".clp"))
+	}
+	return nil, fmt.Errorf("unknown bot strategy %s", name)
+}
+
+// movePayload turns the facts of a relation having the slots of the move relation into moves. The facts whose
+// seat slot names another seat are skipped, as those lacking a single field slot of the move relation. With an
+// empty seat the moves of every seat are returned.
+func (g *Game) movePayload(facts []map[string]string, seat string) []BotMove {
+	moves := make([]BotMove, 0, len(facts))
+	rel := g.assertable[g.moves.Assertion][0]
+	tmpl, ok := g.templates[rel]
+	if !ok {
+		return moves
+	}
+	for _, fact := range facts {
+		mover := seat
+		if value, ok := fact[g.moves.SeatSlot]; ok && seat != "" && value != seat {
+			continue
+		} else if ok {
+			mover = value
+		}
+		item := make(map[string][]string)
+		complete := true
+		for _, slot := range tmpl.SlotNames() {
+			if value, ok := fact[slot]; ok && value != "" {
+				item[slot] = []string{value}
+			} else {
+				complete = false
+			}
+		}
+		for _, slot := range tmpl.MultislotNames() {
+			if value, ok := fact[slot]; ok {
+				item[slot] = quotedSplit(value)
+			}
+		}
+		if !complete {
+			continue
+		}
+		if body, err := json.Marshal(item); err == nil {
+			moves = append(moves, BotMove{Assertion: g.moves.Assertion, Payload: map[string]json.RawMessage{rel: body}, Seat: mover})
+		}
+	}
+	return moves
+}
+
+// legalMoves returns the moves the rules give as legal for a seat
+func (e *Engine) legalMoves(ctx context.Context, room *Room, seat string) ([]BotMove, error) {
+	var raw string
+	if err := room.clipsInstance.Do(ctx, func() error {
+		var err error
+		raw, err = room.clipsInstance.QueryFactsAtomic(room.game.moves.Relation)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	facts, err := genericFactToMap(e.Config, room.game.moves.Relation, raw)
+	if err != nil {
+		return nil, err
+	}
+	return room.game.movePayload(facts, seat), nil
+}
+
+// randomStrategy plays one of the legal moves at random
+type randomStrategy struct {
+	e *Engine
+}
+
+func newRandomStrategy(e *Engine, room *Room) (BotStrategy, error) {
+	return &randomStrategy{e: e}, nil
+}
+
+func (s *randomStrategy) Move(ctx context.Context, room *Room, seat string) (BotMove, bool, error) {
+	moves, err := s.e.legalMoves(ctx, room, seat)
+	if err != nil || len(moves) == 0 {
+		return BotMove{}, false, err
+	}
+	return moves[rand.Intn(len(moves))], true, nil
+}
+
+func (s *randomStrategy) Close() {}
+
+// ruleStrategy asks a bot rule file for the moves. The rules run in a clone of the game loaded with the bot
+// rules as well, where the facts of the room are copied before every move.
+type ruleStrategy struct {
+	clone *gameClone
+}
+
+func newRuleStrategy(e *Engine, room *Room, file string) (BotStrategy, error) {
+	clone, err := e.newGameClone(room)
+	if err != nil {
+		return nil, err
+	}
+	if err := clone.ci.Do(context.Background(), func() error {
+		return clone.ci.LoadFileAtomic(file)
+	}); err != nil {
+		clone.Close()
+		return nil, err
+	}
+	return &ruleStrategy{clone: clone}, nil
+}
+
+func (s *ruleStrategy) Move(ctx context.Context, room *Room, seat string) (BotMove, bool, error) {
+	facts, err := roomFacts(ctx, room)
+	if err != nil {
+		return BotMove{}, false, err
+	}
+
+	ci := s.clone.ci
+	var raw string
+	if err := ci.Do(ctx, func() error {
+		if err := s.clone.restoreAtomic(facts); err != nil {
+			return err
+		}
+		if err := ci.AssertFactAtomic("(" + botTurnRelation + " (seat " + seat + "))"); err != nil {
+			return err
+		}
+		if err := ci.RunAtomic(); err != nil {
+			return err
+		}
+		// What the bot rules print is of no interest to the room
+		if _, err := ci.TakeOutputAtomic(); err != nil {
+			return err
+		}
+		var err error
+		raw, err = ci.QueryFactsAtomic(botMoveRelation)
+		return err
+	}); err != nil {
+		return BotMove{}, false, err
+	}
+
+	proposals, err := genericFactToMap(s.clone.e.Config, botMoveRelation, raw)
+	if err != nil {
+		return BotMove{}, false, err
+	}
+	moves := room.game.movePayload(proposals, seat)
+	if len(moves) == 0 {
+		return BotMove{}, false, nil
+	}
+	return moves[0], true, nil
+}
+
+func (s *ruleStrategy) Close() {
+	s.clone.Close()
+}
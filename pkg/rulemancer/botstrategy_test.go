@@ -0,0 +1,125 @@
+package rulemancer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseGameMoves(t *testing.T) {
+	tests := []struct {
+		name   string
+		facts  []map[string]string
+		moves  *MoveSettings
+		failed bool
+	}{
+		{name: "none", facts: nil, moves: nil},
+		{
+			name:  "default seat slot",
+			facts: []map[string]string{{"assertion": "move", "relation": "legal-move", "seat-slot": "nil"}},
+			moves: &MoveSettings{Assertion: "move", Relation: "legal-move", SeatSlot: "player"},
+		},
+		{
+			name:  "seat slot",
+			facts: []map[string]string{{"assertion": "play", "relation": "playable", "seat-slot": "side"}},
+			moves: &MoveSettings{Assertion: "play", Relation: "playable", SeatSlot: "side"},
+		},
+		{name: "missing assertion", facts: []map[string]string{{"relation": "legal-move"}}, failed: true},
+		{name: "missing relation", facts: []map[string]string{{"assertion": "move", "relation": "nil"}}, failed: true},
+		{name: "declared twice", facts: []map[string]string{{"assertion": "move", "relation": "a"}, {"assertion": "move", "relation": "b"}}, failed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			moves, err := parseGameMoves(tt.facts)
+			if tt.failed {
+				if err == nil {
+					t.Errorf("expected an error, got %v", moves)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(moves, tt.moves) {
+				t.Errorf("expected %v, got %v", tt.moves, moves)
+			}
+		})
+	}
+}
+
+func TestMovePayload(t *testing.T) {
+	game := &Game{
+		assertable: map[string][]string{"move": {"move"}},
+		templates: map[string]*TemplateSchema{
+			"move": {Name: "move", Slots: []SlotSchema{{Name: "x"}, {Name: "y"}, {Name: "player"}, {Name: "notes", Multi: true}}},
+		},
+		moves: &MoveSettings{Assertion: "move", Relation: "legal-move", SeatSlot: "player"},
+	}
+	facts := []map[string]string{
+		{"x": "1", "y": "2", "player": "x"},
+		{"x": "3", "y": "3", "player": "o"},
+		{"x": "2", "y": "2", "player": "x", "notes": `center "best move"`},
+		{"x": "2", "player": "x"},
+	}
+
+	moves := game.movePayload(facts, "x")
+	if len(moves) != 2 {
+		t.Fatalf("expected 2 moves for x, got %d: %v", len(moves), moves)
+	}
+	if all := game.movePayload(facts, ""); len(all) != 3 || all[1].Seat != "o" {
+		t.Errorf("expected the 3 complete moves of every seat, got %v", all)
+	}
+	want := []string{
+		`{"player":["x"],"x":["1"],"y":["2"]}`,
+		`{"notes":["center","\"best move\""],"player":["x"],"x":["2"],"y":["2"]}`,
+	}
+	for i, move := range moves {
+		if move.Assertion != "move" {
+			t.Errorf("move %d: expected the move assertion, got %s", i, move.Assertion)
+		}
+		if move.Seat != "x" {
+			t.Errorf("move %d: expected the seat x, got %s", i, move.Seat)
+		}
+		if got := string(move.Payload["move"]); got != want[i] {
+			t.Errorf("move %d: expected %s, got %s", i, want[i], got)
+		}
+		if fields := game.validateAssertion(move.Assertion, move.Payload); len(fields) > 0 {
+			t.Errorf("move %d: the payload does not validate: %v", i, fields)
+		}
+	}
+}
+
+func TestBotStrategyNames(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, botRulesDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"greedy.clp", "cautious.clp", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, botRulesDir, name), []byte(""), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rules := listBotRules(dir)
+	if !reflect.DeepEqual(rules, []string{"cautious", "greedy"}) {
+		t.Errorf("expected the cautious and greedy rule files, got %v", rules)
+	}
+	if rules := listBotRules(t.TempDir()); len(rules) != 0 {
+		t.Errorf("expected no rule files, got %v", rules)
+	}
+
+	game := &Game{botRules: rules}
+	if names := game.botStrategyNames(); len(names) != 0 {
+		t.Errorf("a game without moves has no strategies, got %v", names)
+	}
+	game.moves = &MoveSettings{Assertion: "move", Relation: "legal-move", SeatSlot: "player"}
+	if names := game.botStrategyNames(); !reflect.DeepEqual(names, []string{"cautious", "greedy", "random"}) {
+		t.Errorf("expected the rule files and random, got %v", names)
+	}
+	game.endRelations = []string{"winner"}
+	if names := game.botStrategyNames(); !reflect.DeepEqual(names, []string{"cautious", "greedy", "mcts", "random"}) {
+		t.Errorf("expected the rule files, mcts and random, got %v", names)
+	}
+}
@@ -38,7 +38,7 @@ func (e *Engine) newBrRoom(name, bridgeRef string) (*BrRoom, error) {
 		if err := cli.InitClips(); err != nil {
 			return nil, err
 		}
-		if err := cli.loadGame(rulesLocation); err != nil {
+		if err := cli.loadGame(rulesLocation, nil); err != nil {
 			cli.Dispose()
 			return nil, err
 		}
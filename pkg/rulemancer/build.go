@@ -12,46 +12,28 @@ import (
 
 type ProtocolData struct {
 	*Engine
-	GameName               string              // Name of the game
-	GameNames              []string            // List of all game names
-	CurrentAssert          string              // The name of the current assert (used in assertions)
-	CurrentAssertRelations []string            // The relations involved in the current assert (used in assertions)
-	CurrentAssertParams    []string            // The union of the sets of assertions parameters (used in assertions)
-	CurrentQuery           string              // The name of the current query (used in queries)
-	Assertables            map[string][]string // Assertable of the game
-	Responses              map[string][]string // Responses of the game
-	Queryables             map[string][]string // Queryable of the game
-	Relations              []string            // Relations of the game
-	Slots                  map[string][]string // Slots of the game
-	Multislots             map[string][]string // Multislots of the game
+	GameName               string                     // Name of the game
+	GameNames              []string                   // List of all game names
+	CurrentAssert          string                     // The name of the current assert (used in assertions)
+	CurrentAssertRelations []string                   // The relations involved in the current assert (used in assertions)
+	CurrentAssertParams    []string                   // The union of the sets of assertions parameters (used in assertions)
+	CurrentQuery           string                     // The name of the current query (used in queries)
+	Assertables            map[string][]string        // Assertable of the game
+	Responses              map[string][]string        // Responses of the game
+	Queryables             map[string][]string        // Queryable of the game
+	Relations              []string                   // Relations of the game
+	Slots                  map[string][]string        // Slots of the game
+	Multislots             map[string][]string        // Multislots of the game
+	Templates              map[string]*TemplateSchema // Deftemplates of the game relations
 	funcMap                template.FuncMap
 }
 
 func (e *Engine) newProtocolData(withFuncMap bool) *ProtocolData {
 	slots := make(map[string][]string)
 	multislots := make(map[string][]string)
-	for _, game := range e.games {
-		for _, relations := range game.assertable {
-			for _, rel := range relations {
-				slots[rel] = make([]string, 0)
-				multislots[rel] = make([]string, 0)
-			}
-		}
-		for _, relations := range game.responses {
-			for _, rel := range relations {
-				slots[rel] = make([]string, 0)
-				multislots[rel] = make([]string, 0)
-			}
-		}
-		for _, relations := range game.queryable {
-			for _, rel := range relations {
-				slots[rel] = make([]string, 0)
-				multislots[rel] = make([]string, 0)
-			}
-		}
-	}
+	templates := make(map[string]*TemplateSchema)
 	if !withFuncMap {
-		return &ProtocolData{Engine: e, Slots: slots, Multislots: multislots, funcMap: nil}
+		return &ProtocolData{Engine: e, Slots: slots, Multislots: multislots, Templates: templates, funcMap: nil}
 	}
 
 	funcMap := template.FuncMap{
@@ -105,50 +87,59 @@ func (e *Engine) newProtocolData(withFuncMap bool) *ProtocolData {
 			return strings.ReplaceAll(a, "-", "_")
 		},
 	}
-	return &ProtocolData{Engine: e, Slots: slots, Multislots: multislots, funcMap: funcMap}
+	return &ProtocolData{Engine: e, Slots: slots, Multislots: multislots, Templates: templates, funcMap: funcMap}
 }
 
-// Merge merges another ProtocolData into this one.
-func (pd *ProtocolData) Merge(other *ProtocolData) {
-	for rel, slots := range other.Slots {
-		if _, exists := pd.Slots[rel]; !exists {
-			pd.Slots[rel] = slots
-		} else {
-			existingSlots := pd.Slots[rel]
-			slotSet := make(map[string]struct{})
-			for _, slot := range existingSlots {
-				slotSet[slot] = struct{}{}
-			}
-			for _, slot := range slots {
-				if _, found := slotSet[slot]; !found {
-					existingSlots = append(existingSlots, slot)
-				}
-			}
-			pd.Slots[rel] = existingSlots
+// addGameRelations fills the ProtocolData slots, multislots and templates with the deftemplates of the
+// relations of the game interface
+func (pd *ProtocolData) addGameRelations(game *Game) {
+	for rel, tmpl := range game.interfaceTemplates() {
+		pd.Templates[rel] = tmpl
+		pd.Slots[rel] = tmpl.SlotNames()
+		pd.Multislots[rel] = tmpl.MultislotNames()
+	}
+}
+
+// SlotHint returns a short description of the values accepted by a slot of a relation, or an empty string
+// when the relation or the slot is unknown
+func (pd *ProtocolData) SlotHint(rel, slot string) string {
+	if tmpl, ok := pd.Templates[rel]; ok {
+		if s := tmpl.Slot(slot); s != nil {
+			return s.Hint()
 		}
 	}
-	for rel, multislots := range other.Multislots {
-		if _, exists := pd.Multislots[rel]; !exists {
-			pd.Multislots[rel] = multislots
-		} else {
-			existingMultislots := pd.Multislots[rel]
-			multislotSet := make(map[string]struct{})
-			for _, multislot := range existingMultislots {
-				multislotSet[multislot] = struct{}{}
-			}
-			for _, multislot := range multislots {
-				if _, found := multislotSet[multislot]; !found {
-					existingMultislots = append(existingMultislots, multislot)
-				}
-			}
-			pd.Multislots[rel] = existingMultislots
+	return ""
+}
+
+// SlotInputType returns the HTML input type best suited for a slot of a relation
+func (pd *ProtocolData) SlotInputType(rel, slot string) string {
+	if tmpl, ok := pd.Templates[rel]; ok {
+		if s := tmpl.Slot(slot); s != nil && !s.Multi && s.Numeric() {
+			return "number"
 		}
 	}
+	return "text"
+}
+
+// SlotAllowedValues returns the allowed values of a slot of a relation, if any
+func (pd *ProtocolData) SlotAllowedValues(rel, slot string) []string {
+	if tmpl, ok := pd.Templates[rel]; ok {
+		if s := tmpl.Slot(slot); s != nil {
+			return s.AllowedValues
+		}
+	}
+	return nil
+}
+
+// EventProtocolVersion returns the version of the room events envelope the generated clients understand
+func (pd *ProtocolData) EventProtocolVersion() int {
+	return EventProtocolVersion
 }
 
 func (e *Engine) BuildEngineGamesExtras(shellOutdir string) error {
 	// The rebuild engine reads the rules games directories and the assertables,results and querables from there.
-	// Then uses re2c to write the various artifacts needed to interact with the engine.
+	// Then uses the deftemplates of the loaded CLIPS environments to write the various artifacts needed to interact
+	// with the engine.
 
 	e.loadGames()
 
@@ -396,64 +387,17 @@ func (e *Engine) gameInterfaces() (map[string]*ProtocolData, error) {
 	gamesInterfaces := make(map[string]*ProtocolData)
 	for _, game := range e.games {
 
-		// Create a new ProtocolData for the game, it will hold the slots and multislots information from
-		// the rules files. It also has the funcMap for template execution.
+		// Create a new ProtocolData for the game, it will hold the deftemplates information taken from the
+		// CLIPS environment. It also has the funcMap for template execution.
 		rf := e.newProtocolData(true)
 
 		gameName := game.name
 		rf.GameNames = gameNames
-		rulesLocation := game.rulesLocation
 		if e.Debug {
 			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/BuildEngineExtras]")+" ", 0)
-			l.Printf("Building engine extras for game: %s from rules location: %s", gameName, rulesLocation)
-		}
-		// Load a game from the specified rules location
-		if _, err := os.Stat(rulesLocation); os.IsNotExist(err) {
-			return nil, fmt.Errorf("rules location does not exist: %s", rulesLocation)
-		}
-		if rulesFiles, err := os.ReadDir(rulesLocation); err != nil {
-			return nil, fmt.Errorf("failed to read rules location: %w", err)
-		} else {
-			// Load each rule file into CLIPS
-			for _, file := range rulesFiles {
-				// load the file
-				if !file.IsDir() {
-					if e.Debug {
-						l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/BuildEngineExtras]")+" ", 0)
-						l.Printf("Processing rule file: %s", file.Name())
-					}
-					fileContent, err := os.ReadFile(rulesLocation + "/" + file.Name())
-					if err != nil {
-						if e.Debug {
-							l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/BuildEngineExtras]")+" ", 0)
-							l.Printf("Error reading rule file %s: %v", file.Name(), err)
-						}
-						return nil, fmt.Errorf("failed to read rule file %s: %w", file.Name(), err)
-					}
-
-					if e.Debug {
-						l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/BuildEngineExtras]")+" ", 0)
-						l.Printf("Executing parser on rule file: %s", file.Name())
-					}
-
-					pd := e.newProtocolData(false)
-
-					if err := pd.Compile(string(fileContent) + "\x00"); err != nil {
-						if e.Debug {
-							l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/BuildEngineExtras]")+" ", 0)
-							l.Printf("Error compiling rule file %s: %v", file.Name(), err)
-						}
-						return nil, fmt.Errorf("failed to compile rule file %s: %w", file.Name(), err)
-					}
-					rf.Merge(pd)
-
-					if e.Debug {
-						l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/BuildEngineExtras]")+" ", 0)
-						l.Printf("Successfully processed rule file: %s", file.Name())
-					}
-				}
-			}
+			l.Printf("Building engine extras for game: %s from rules location: %s", gameName, game.rulesLocation)
 		}
+		rf.addGameRelations(game)
 
 		rf.Assertables = game.assertable
 		rf.Responses = game.responses
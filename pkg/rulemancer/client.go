@@ -15,9 +15,43 @@ type Client struct {
 	watchingRooms map[string]*Room
 	watchersMutex sync.RWMutex
 	lastActive    int64
+	bot           bool // owned by the engine, it plays in a single room and has no token
+}
+
+func (c *Client) Info() map[string]any {
+	c.roomsMutex.RLock()
+	playing := make([]string, 0, len(c.playingRooms))
+	for id := range c.playingRooms {
+		playing = append(playing, id)
+	}
+	c.roomsMutex.RUnlock()
+	c.watchersMutex.RLock()
+	watching := make([]string, 0, len(c.watchingRooms))
+	for id := range c.watchingRooms {
+		watching = append(watching, id)
+	}
+	c.watchersMutex.RUnlock()
+	return map[string]any{
+		"id":             c.id,
+		"name":           c.name,
+		"description":    c.description,
+		"playing_rooms":  playing,
+		"watching_rooms": watching,
+		"last_active":    c.lastActive,
+		"bot":            c.bot,
+	}
 }
 
 func (e *Engine) newClient(name, description string) *Client {
+	return e.addClient(name, description, false)
+}
+
+// newBotClient creates the client of a bot, owned by the engine
+func (e *Engine) newBotClient(name, description string) *Client {
+	return e.addClient(name, description, true)
+}
+
+func (e *Engine) addClient(name, description string, bot bool) *Client {
 	e.clientsMutex.Lock()
 	defer e.clientsMutex.Unlock()
 	client := &Client{
@@ -29,6 +63,7 @@ func (e *Engine) newClient(name, description string) *Client {
 		watchingRooms: make(map[string]*Room),
 		watchersMutex: sync.RWMutex{},
 		lastActive:    time.Now().Unix(),
+		bot:           bot,
 	}
 
 	e.numClients++
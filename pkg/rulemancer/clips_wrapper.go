@@ -7,51 +7,168 @@ package rulemancer
 
 void* clips_create();
 void clips_destroy(void*);
-void clips_load(void*, const char*);
+char* clips_load(void*, const char*);
 void clips_reset(void*);
-void clips_run(void*);
+int clips_run(void*, long long);
+void clips_halt(void*);
+void clips_clear_halt(void*);
+void clips_refresh_logical(void*);
+char* clips_take_output(void*);
 void clips_assert(void*, const char*);
 char* find_facts_as_string(void*, const char*);
+char* find_indexed_facts_as_string(void*, const char*);
 char* find_all_facts_as_string(void*);
+char* clips_templates_as_string(void*);
+char* clips_eval(void*, const char*);
 void clips_free_string(void*, char*);
 */
 import "C"
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"runtime"
+	"sync"
+	"time"
 	"unsafe"
 )
 
+var (
+	// ErrRulesLimit is returned when a run fires the maximum number of rules with activations still pending
+	ErrRulesLimit = errors.New("rule firing limit exceeded")
+	// ErrRunTimeout is returned when a run is halted by the watchdog after the wall clock budget
+	ErrRunTimeout = errors.New("rule execution timed out")
+	// ErrRuleError is returned when a run stops on an evaluation error in the actions of a rule
+	ErrRuleError = errors.New("rule execution failed")
+	// ErrRunHalted is returned when a run is stopped by the halt function of a rule
+	ErrRunHalted = errors.New("rule execution halted by the rules")
+	// ErrClipsBusy is returned when a job cannot be handed to the CLIPS worker before the queue timeout or the
+	// end of the caller context
+	ErrClipsBusy = errors.New("CLIPS instance busy")
+	// ErrClipsPanic is returned when a job panics, the worker survives and keeps serving the instance
+	ErrClipsPanic = errors.New("CLIPS job panicked")
+	// ErrClipsDisposed is returned when a job is submitted to a disposed instance
+	ErrClipsDisposed = errors.New("CLIPS instance disposed")
+)
+
+// RunLimits bounds every run of the CLIPS engine, zero values mean no limit
+type RunLimits struct {
+	MaxRulesFired int64         `json:"max_rules_fired"`
+	Timeout       time.Duration `json:"timeout"`
+}
+
+// clipsJob is a closure executed by the worker goroutine of a CLIPS instance
+type clipsJob struct {
+	fn   func() error
+	done chan error
+}
+
 type ClipsInstance struct {
-	e     *Engine
-	cl    unsafe.Pointer
-	sChan chan struct{} // serialize channel
-	rChan chan struct{} // response channel
-	qChan chan struct{} // quit channel
+	e           *Engine
+	cl          unsafe.Pointer
+	limits      RunLimits     // limits applied to every run
+	jobs        chan clipsJob // jobs executed by the worker goroutine
+	qChan       chan struct{} // quit channel
+	disposeOnce sync.Once
 }
 
 func (e *Engine) NewClipsInstance() *ClipsInstance {
 	return &ClipsInstance{
-		e:     e,
-		sChan: make(chan struct{}),
-		rChan: make(chan struct{}),
-		qChan: make(chan struct{}),
+		e:      e,
+		limits: e.defaultRunLimits(),
+		jobs:   make(chan clipsJob),
+		qChan:  make(chan struct{}),
 	}
 }
 
 // InitClips initializes the CLIPS environment for the instance, it is ment to be called once per instance
 func (ci *ClipsInstance) InitClips() error {
-	// Initialize CLIPS environment
-	env := C.clips_create()
-	ci.cl = env
-	ci.spawnSerializer()
+	ci.spawnWorker()
+	// Initialize CLIPS environment, from the worker thread as every other CLIPS call
+	return ci.submit(context.Background(), 0, func() error {
+		ci.cl = C.clips_create()
+		return nil
+	})
+}
 
-	return nil
+// Do executes fn on the worker goroutine of the instance, serialized with every other job. Only the *Atomic
+// methods can be used inside fn. Do gives up with ErrClipsBusy if the worker does not accept the job before the
+// end of ctx or the configured queue timeout; once accepted, the job runs to completion. A panic in fn is
+// recovered and returned as ErrClipsPanic.
+func (ci *ClipsInstance) Do(ctx context.Context, fn func() error) error {
+	return ci.submit(ctx, time.Duration(ci.e.ClipsQueueTimeoutMs)*time.Millisecond, fn)
 }
 
-// loadGame loads the rules from the specified location into a CLIPS instance
-func (ci *ClipsInstance) loadGame(rulesLocation string) error {
+// submit hands a job to the worker, waiting at most timeout (no limit if zero) for the worker to accept it
+func (ci *ClipsInstance) submit(ctx context.Context, timeout time.Duration, fn func() error) error {
+	job := clipsJob{fn: fn, done: make(chan error, 1)}
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case ci.jobs <- job:
+	case <-ci.qChan:
+		return ErrClipsDisposed
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrClipsBusy, ctx.Err())
+	case <-expired:
+		return fmt.Errorf("%w: queue timeout after %v", ErrClipsBusy, timeout)
+	}
+	return <-job.done
+}
+
+// spawnWorker starts the goroutine executing the instance jobs. The goroutine is pinned to its OS thread, so
+// that the CLIPS environment is always used from the same thread.
+func (ci *ClipsInstance) spawnWorker() {
+	if ci.e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/ClipsInstance]")+" ", 0)
+		l.Println("Spawning CLIPS worker goroutine for instance", fmt.Sprintf("%p", ci))
+	}
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		for {
+			select {
+			case job := <-ci.jobs:
+				job.done <- ci.execute(job.fn)
+			case <-ci.qChan:
+				// On dispose, exit the goroutine
+				return
+			}
+		}
+	}()
+}
+
+// execute runs a job, turning a panic into an error so that the worker is always released
+func (ci *ClipsInstance) execute(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/ClipsInstance]")+" ", 0)
+			l.Printf("Recovered panic in CLIPS job for instance %p: %v", ci, r)
+			err = fmt.Errorf("%w: %v", ErrClipsPanic, r)
+		}
+	}()
+	return fn()
+}
+
+// loadGame loads the rules from the specified location into a CLIPS instance. If any file fails to load, the
+// CLIPS errors of every file are returned as ClipsLoadErrors and the environment is not reset.
+func (ci *ClipsInstance) loadGame(rulesLocation string, facts []string) error {
+	return ci.submit(context.Background(), 0, func() error {
+		return ci.loadGameAtomic(rulesLocation, facts)
+	})
+}
+
+// loadGameAtomic loads the rules from the specified location, it must be called from a CLIPS job. The facts
+// are asserted after the reset, before the first run.
+func (ci *ClipsInstance) loadGameAtomic(rulesLocation string, facts []string) error {
 	// Load a game from the specified rules location
 	if _, err := os.Stat(rulesLocation); os.IsNotExist(err) {
 		return fmt.Errorf("rules location does not exist: %s", rulesLocation)
@@ -59,21 +176,86 @@ func (ci *ClipsInstance) loadGame(rulesLocation string) error {
 	if rulesFiles, err := os.ReadDir(rulesLocation); err != nil {
 		return fmt.Errorf("failed to read rules location: %w", err)
 	} else {
+		loadErrors := make(ClipsLoadErrors, 0)
 		// Load each rule file into CLIPS
 		for _, file := range rulesFiles {
 			if !file.IsDir() {
-				fmt.Printf("Loading CLIPS file: %s\n", file.Name())
+				if ci.e.Debug {
+					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/loadGame]")+" ", 0)
+					l.Printf("Loading CLIPS file: %s", file.Name())
+				}
 				cfile := C.CString(rulesLocation + "/" + file.Name())
 				defer C.free(unsafe.Pointer(cfile))
-				C.clips_load(ci.cl, cfile)
+				if cErrors := C.clips_load(ci.cl, cfile); cErrors != nil {
+					fileErrors := parseLoadErrors(C.GoString(cErrors))
+					C.clips_free_string(ci.cl, cErrors)
+					if ci.e.Debug {
+						l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/loadGame]")+" ", 0)
+						l.Printf("Errors loading CLIPS file %s: %v", file.Name(), fileErrors)
+					}
+					loadErrors = append(loadErrors, fileErrors...)
+				}
 			}
 		}
+		if len(loadErrors) > 0 {
+			return loadErrors
+		}
 		C.clips_reset(ci.cl)
-		C.clips_run(ci.cl)
+		for _, fact := range facts {
+			if err := ci.AssertFactAtomic(fact); err != nil {
+				return err
+			}
+		}
+		if err := ci.RunAtomic(); err != nil {
+			return fmt.Errorf("failed to run the initial facts: %w", err)
+		}
 	}
 	return nil
 }
 
+// LoadFileAtomic loads a single file of rules on top of those already loaded, it must be called from a CLIPS
+// job. The environment is not reset.
+func (ci *ClipsInstance) LoadFileAtomic(path string) error {
+	if ci.cl == nil {
+		return fmt.Errorf("CLIPS instance not initialized")
+	}
+	cfile := C.CString(path)
+	defer C.free(unsafe.Pointer(cfile))
+	if cErrors := C.clips_load(ci.cl, cfile); cErrors != nil {
+		loadErrors := parseLoadErrors(C.GoString(cErrors))
+		C.clips_free_string(ci.cl, cErrors)
+		if len(loadErrors) > 0 {
+			return loadErrors
+		}
+	}
+	return nil
+}
+
+// copyFactsAtomic replaces every fact of the environment with the given ones, without running the rules. It must
+// be called from a CLIPS job.
+func (ci *ClipsInstance) copyFactsAtomic(facts []string) error {
+	if _, err := ci.EvalAtomic("(retract *)"); err != nil {
+		return err
+	}
+	for _, fact := range facts {
+		if err := ci.AssertFactAtomic(fact); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshLogicalAtomic leaves on the agenda only the activations of the rules with a logical conditional element,
+// the following run derives again the facts they support and fires nothing else. It must be called from a CLIPS
+// job.
+func (ci *ClipsInstance) refreshLogicalAtomic() error {
+	if ci.cl == nil {
+		return fmt.Errorf("CLIPS instance not initialized")
+	}
+	C.clips_refresh_logical(ci.cl)
+	return nil
+}
+
 func (ci *ClipsInstance) getGameConfig(config string) (map[string][]string, error) {
 	facts, err := ci.QueryFacts(config)
 	if err != nil {
@@ -102,51 +284,19 @@ func (ci *ClipsInstance) getGameConfig(config string) (map[string][]string, erro
 	return result, nil
 }
 
-func (ci *ClipsInstance) spawnSerializer() {
-	if ci.e.Debug {
-		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/ClipsInstance]")+" ", 0)
-		l.Println("Spawning CLIPS serializer goroutine for instance", fmt.Sprintf("%p", ci.cl))
-	}
-	go func() {
-		for {
-			select {
-			case ci.sChan <- struct{}{}:
-				<-ci.rChan
-				// Every time a request is made, this channel will be read to ensure serialized access
-			case ci.qChan <- struct{}{}:
-				// On dispose, exit the goroutine
-				return
-			}
-		}
-	}()
-}
-
 func (ci *ClipsInstance) Info() map[string]string {
-	// Return basic info about the CLIPS instance
+	// Return basic info about the CLIPS instance, the environment itself is not touched
 	if ci.cl == nil {
 		return map[string]string{
 			"status": "uninitialized",
 		}
 	}
-	<-ci.sChan
-	response := map[string]string{
+	return map[string]string{
 		"status":        "running",
 		"engine":        "CLIPS",
 		"version":       "6.40",
 		"instance_addr": fmt.Sprintf("%p", ci.cl),
 	}
-	ci.rChan <- struct{}{}
-	return response
-}
-
-// Lock the CLIPS instance for serialized access
-func (ci *ClipsInstance) Lock() {
-	<-ci.sChan
-}
-
-// Unlock the CLIPS instance after serialized access
-func (ci *ClipsInstance) Unlock() {
-	ci.rChan <- struct{}{}
 }
 
 // AssertFact asserts a fact into the CLIPS environment
@@ -155,15 +305,12 @@ func (ci *ClipsInstance) AssertFact(fact string) error {
 	if ci.cl == nil {
 		return fmt.Errorf("CLIPS instance not initialized")
 	}
-	<-ci.sChan
-	cFact := C.CString(fact)
-	defer C.free(unsafe.Pointer(cFact))
-	C.clips_assert(ci.cl, cFact)
-	ci.rChan <- struct{}{}
-	return nil
+	return ci.Do(context.Background(), func() error {
+		return ci.AssertFactAtomic(fact)
+	})
 }
 
-// AssertFactAtomic asserts a fact into the CLIPS environment without using the serializer goroutine
+// AssertFactAtomic asserts a fact into the CLIPS environment, it must be called from a CLIPS job
 func (ci *ClipsInstance) AssertFactAtomic(fact string) error {
 	// Assert a fact into the CLIPS environment
 	if ci.cl == nil {
@@ -181,20 +328,115 @@ func (ci *ClipsInstance) Run() error {
 	if ci.cl == nil {
 		return fmt.Errorf("CLIPS instance not initialized")
 	}
-	<-ci.sChan
-	C.clips_run(ci.cl)
-	ci.rChan <- struct{}{}
-	return nil
+	return ci.Do(context.Background(), ci.RunAtomic)
 }
 
-// RunAtomic executes the CLIPS engine without using the serializer goroutine
+// RunAtomic executes the CLIPS engine, it must be called from a CLIPS job. The run is bounded by the
+// instance limits: ErrRulesLimit is returned when too many rules fire and ErrRunTimeout when the watchdog
+// halts the execution. A run stopped by the rules themselves returns ErrRuleError or ErrRunHalted.
 func (ci *ClipsInstance) RunAtomic() error {
 	// Run the CLIPS engine
 	if ci.cl == nil {
 		return fmt.Errorf("CLIPS instance not initialized")
 	}
-	C.clips_run(ci.cl)
-	return nil
+
+	limit := C.longlong(-1)
+	if ci.limits.MaxRulesFired > 0 {
+		limit = C.longlong(ci.limits.MaxRulesFired)
+	}
+
+	stop := ci.watchdog()
+	status := C.clips_run(ci.cl, limit)
+	timedOut := stop()
+	C.clips_clear_halt(ci.cl)
+
+	err := runError(int(status), timedOut, ci.limits)
+	if err != nil && ci.e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/RunAtomic]")+" ", 0)
+		l.Printf("Run stopped: %v", err)
+	}
+	return err
+}
+
+// watchdog halts the execution when the wall clock budget of the instance limits is over. The returned function
+// waits for it, so that a late halt cannot leak into the next job, and tells whether it halted the execution, the
+// rules can halt it too.
+func (ci *ClipsInstance) watchdog() func() bool {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	timedOut := false
+	if ci.limits.Timeout > 0 {
+		go func() {
+			defer close(finished)
+			timer := time.NewTimer(ci.limits.Timeout)
+			defer timer.Stop()
+			select {
+			case <-done:
+			case <-timer.C:
+				timedOut = true
+				C.clips_halt(ci.cl)
+			}
+		}()
+	} else {
+		close(finished)
+	}
+	return func() bool {
+		close(done)
+		<-finished
+		return timedOut
+	}
+}
+
+// runError maps the status of clips_run to the error of the run. A halted run only timed out when the watchdog
+// halted it, otherwise the rules stopped it.
+func runError(status int, timedOut bool, limits RunLimits) error {
+	switch {
+	case status == 0:
+		return nil
+	case status == 1:
+		return fmt.Errorf("%w: %d rules fired", ErrRulesLimit, limits.MaxRulesFired)
+	case timedOut:
+		return fmt.Errorf("%w: halted after %v", ErrRunTimeout, limits.Timeout)
+	case status == 3:
+		return fmt.Errorf("%w: evaluation error in the actions of a rule", ErrRuleError)
+	default:
+		return ErrRunHalted
+	}
+}
+
+// SetRunLimits sets the limits applied to the following runs
+func (ci *ClipsInstance) SetRunLimits(limits RunLimits) {
+	ci.limits = limits
+}
+
+// TakeOutput returns the lines printed by the rules since the last call
+func (ci *ClipsInstance) TakeOutput() ([]OutputLine, error) {
+	if ci.cl == nil {
+		return nil, fmt.Errorf("CLIPS instance not initialized")
+	}
+	var lines []OutputLine
+	err := ci.Do(context.Background(), func() error {
+		var err error
+		lines, err = ci.TakeOutputAtomic()
+		return err
+	})
+	return lines, err
+}
+
+// TakeOutputAtomic returns the lines printed by the rules since the last call, it must be called from a CLIPS
+// job
+func (ci *ClipsInstance) TakeOutputAtomic() ([]OutputLine, error) {
+	if ci.cl == nil {
+		return nil, fmt.Errorf("CLIPS instance not initialized")
+	}
+	output := C.clips_take_output(ci.cl)
+	defer C.clips_free_string(ci.cl, output)
+	lines := parseOutput(C.GoString(output))
+	if ci.e.Debug && len(lines) > 0 {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/TakeOutput]")+" ", 0)
+		l.Println("Captured output:", lines)
+	}
+	return lines, nil
 }
 
 // QueryFacts queries facts matching the given relation pattern
@@ -203,21 +445,16 @@ func (ci *ClipsInstance) QueryFacts(relation string) (string, error) {
 	if ci.cl == nil {
 		return "", fmt.Errorf("CLIPS instance not initialized")
 	}
-	<-ci.sChan
-	cRelation := C.CString(relation)
-	defer C.free(unsafe.Pointer(cRelation))
-	facts := C.find_facts_as_string(ci.cl, cRelation)
-	defer C.clips_free_string(ci.cl, facts)
-	goFacts := sanitizeFacts(C.GoString(facts))
-	if ci.e.Debug {
-		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/QueryFacts]")+" ", 0)
-		l.Println("Queried facts raw:", goFacts)
-	}
-	ci.rChan <- struct{}{}
-	return goFacts, nil
+	var goFacts string
+	err := ci.Do(context.Background(), func() error {
+		var err error
+		goFacts, err = ci.QueryFactsAtomic(relation)
+		return err
+	})
+	return goFacts, err
 }
 
-// QueryFactsAtomic queries facts matching the given relation pattern without using the serializer goroutine
+// QueryFactsAtomic queries facts matching the given relation pattern, it must be called from a CLIPS job
 func (ci *ClipsInstance) QueryFactsAtomic(relation string) (string, error) {
 	// Query facts matching the pattern
 	if ci.cl == nil {
@@ -235,12 +472,40 @@ func (ci *ClipsInstance) QueryFactsAtomic(relation string) (string, error) {
 	return goFacts, nil
 }
 
-func (ci *ClipsInstance) QueryFactsAllFacts() (string, error) {
+// QueryIndexedFactsAtomic queries the facts of a relation along with their fact index, it must be called from a
+// CLIPS job
+func (ci *ClipsInstance) QueryIndexedFactsAtomic(relation string) ([]IndexedFact, error) {
+	if ci.cl == nil {
+		return nil, fmt.Errorf("CLIPS instance not initialized")
+	}
+	cRelation := C.CString(relation)
+	defer C.free(unsafe.Pointer(cRelation))
+	facts := C.find_indexed_facts_as_string(ci.cl, cRelation)
+	if facts == nil {
+		return nil, fmt.Errorf("failed to query facts of %s", relation)
+	}
+	defer C.clips_free_string(ci.cl, facts)
+	return parseIndexedFacts(C.GoString(facts))
+}
+
+// QueryFactsAllFacts queries all the facts of the environment, giving up when ctx ends before the instance is
+// available
+func (ci *ClipsInstance) QueryFactsAllFacts(ctx context.Context) (string, error) {
+	var goFacts string
+	err := ci.Do(ctx, func() error {
+		var err error
+		goFacts, err = ci.QueryFactsAllFactsAtomic()
+		return err
+	})
+	return goFacts, err
+}
+
+// QueryFactsAllFactsAtomic queries all the facts of the environment, it must be called from a CLIPS job
+func (ci *ClipsInstance) QueryFactsAllFactsAtomic() (string, error) {
 	// Query all facts
 	if ci.cl == nil {
 		return "", fmt.Errorf("CLIPS instance not initialized")
 	}
-	<-ci.sChan
 	facts := C.find_all_facts_as_string(ci.cl)
 	defer C.clips_free_string(ci.cl, facts)
 	goFacts := sanitizeFacts(C.GoString(facts))
@@ -248,15 +513,70 @@ func (ci *ClipsInstance) QueryFactsAllFacts() (string, error) {
 		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/QueryFactsAllFacts]")+" ", 0)
 		l.Println("Queried all facts raw:", goFacts)
 	}
-	ci.rChan <- struct{}{}
 	return goFacts, nil
 }
 
-func (ci *ClipsInstance) Dispose() {
+// EvalAtomic evaluates a CLIPS expression and returns the printed form of its result, it must be called from a
+// CLIPS job. The messages printed by CLIPS, errors included, are left in the instance output. The evaluation is
+// bounded by the wall clock budget of the runs, ErrRunTimeout is returned when the watchdog halts it. The exit
+// function is removed from every environment, evaluating it is an error.
+func (ci *ClipsInstance) EvalAtomic(expr string) (string, error) {
 	if ci.cl == nil {
-		return
+		return "", fmt.Errorf("CLIPS instance not initialized")
+	}
+	cExpr := C.CString(expr)
+	defer C.free(unsafe.Pointer(cExpr))
+	stop := ci.watchdog()
+	result := C.clips_eval(ci.cl, cExpr)
+	if stop() {
+		if result != nil {
+			C.clips_free_string(ci.cl, result)
+		}
+		return "", fmt.Errorf("%w: halted after %v", ErrRunTimeout, ci.limits.Timeout)
+	}
+	if result == nil {
+		return "", fmt.Errorf("failed to evaluate expression: %s", expr)
+	}
+	defer C.clips_free_string(ci.cl, result)
+	goResult := C.GoString(result)
+	if ci.e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/Eval]")+" ", 0)
+		l.Printf("Evaluated %s: %s", expr, goResult)
+	}
+	return goResult, nil
+}
+
+// Templates returns the schema of every deftemplate defined in the CLIPS environment
+func (ci *ClipsInstance) Templates() (map[string]*TemplateSchema, error) {
+	if ci.cl == nil {
+		return nil, fmt.Errorf("CLIPS instance not initialized")
+	}
+	var goRaw string
+	if err := ci.Do(context.Background(), func() error {
+		raw := C.clips_templates_as_string(ci.cl)
+		goRaw = C.GoString(raw)
+		C.clips_free_string(ci.cl, raw)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if ci.e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/Templates]")+" ", 0)
+		l.Println("Queried templates raw:", goRaw)
 	}
-	<-ci.qChan
-	// Destroy the CLIPS environment
-	C.clips_destroy(ci.cl)
+	return parseTemplates(goRaw), nil
+}
+
+// Dispose destroys the CLIPS environment and stops the worker goroutine, it waits for the running job
+func (ci *ClipsInstance) Dispose() {
+	ci.disposeOnce.Do(func() {
+		if ci.cl != nil {
+			// Destroy the CLIPS environment
+			ci.submit(context.Background(), 0, func() error {
+				C.clips_destroy(ci.cl)
+				return nil
+			})
+		}
+		close(ci.qChan)
+	})
 }
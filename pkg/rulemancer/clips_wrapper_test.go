@@ -0,0 +1,133 @@
+package rulemancer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestWorker returns an instance with a running worker and no CLIPS environment, enough to exercise the
+// job serialization
+func newTestWorker(queueTimeoutMs int64) *ClipsInstance {
+	config := NewConfig()
+	config.ClipsQueueTimeoutMs = queueTimeoutMs
+	ci := (&Engine{Config: config}).NewClipsInstance()
+	ci.spawnWorker()
+	return ci
+}
+
+func TestClipsInstanceDo(t *testing.T) {
+	sentinel := errors.New("job failed")
+
+	tests := []struct {
+		name    string
+		fn      func() error
+		wantErr error
+	}{
+		{
+			name: "job succeeds",
+			fn:   func() error { return nil },
+		},
+		{
+			name:    "job error is returned",
+			fn:      func() error { return sentinel },
+			wantErr: sentinel,
+		},
+		{
+			name:    "panic is recovered",
+			fn:      func() error { panic("boom") },
+			wantErr: ErrClipsPanic,
+		},
+	}
+
+	ci := newTestWorker(1000)
+	defer close(ci.qChan)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ci.Do(context.Background(), tt.fn)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// The worker must still serve jobs after a panic
+	if err := ci.Do(context.Background(), func() error { return nil }); err != nil {
+		t.Fatalf("worker not released after panic: %v", err)
+	}
+}
+
+func TestClipsInstanceDoBusy(t *testing.T) {
+	ci := newTestWorker(20)
+	defer close(ci.qChan)
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go ci.Do(context.Background(), func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	if err := ci.Do(context.Background(), func() error { return nil }); !errors.Is(err, ErrClipsBusy) {
+		t.Fatalf("expected ErrClipsBusy on queue timeout, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ci.submit(ctx, time.Minute, func() error { return nil }); !errors.Is(err, ErrClipsBusy) {
+		t.Fatalf("expected ErrClipsBusy on cancelled context, got %v", err)
+	}
+
+	close(release)
+	if err := ci.Do(context.Background(), func() error { return nil }); err != nil {
+		t.Fatalf("unexpected error after release: %v", err)
+	}
+}
+
+func TestClipsInstanceDisposed(t *testing.T) {
+	// No worker is running, the job can only be refused
+	ci := (&Engine{Config: NewConfig()}).NewClipsInstance()
+	close(ci.qChan)
+
+	if err := ci.Do(context.Background(), func() error { return nil }); !errors.Is(err, ErrClipsDisposed) {
+		t.Fatalf("expected ErrClipsDisposed, got %v", err)
+	}
+}
+
+func TestRunError(t *testing.T) {
+	limits := RunLimits{MaxRulesFired: 100, Timeout: time.Second}
+	tests := []struct {
+		name     string
+		status   int
+		timedOut bool
+		want     error
+	}{
+		{name: "agenda exhausted", status: 0, want: nil},
+		{name: "late watchdog", status: 0, timedOut: true, want: nil},
+		{name: "rules limit", status: 1, want: ErrRulesLimit},
+		{name: "watchdog", status: 2, timedOut: true, want: ErrRunTimeout},
+		{name: "watchdog on an evaluation error", status: 3, timedOut: true, want: ErrRunTimeout},
+		{name: "halt of a rule", status: 2, want: ErrRunHalted},
+		{name: "evaluation error", status: 3, want: ErrRuleError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runError(tt.status, tt.timedOut, limits)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+			} else if !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
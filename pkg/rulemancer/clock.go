@@ -0,0 +1,288 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Clock modes
+const (
+	ClockPerMove = "per-move" // every turn gets the whole time
+	ClockTotal   = "total"    // the time is for the whole game, the increment is added after every turn
+)
+
+// Relation of the facts telling the rules that a seat ran out of time
+const timeoutRelation = "timeout"
+
+// ClockSettings are the turn clock of a game, declared with a game-clock fact. The active seat is the value of
+// TurnSlot in the facts of TurnRelation.
+type ClockSettings struct {
+	Mode         string `json:"mode"`
+	Time         int64  `json:"time_ms"`
+	Increment    int64  `json:"increment_ms"`
+	TurnRelation string `json:"turn_relation"`
+	TurnSlot     string `json:"turn_slot"`
+}
+
+// parseGameClock reads the optional game-clock fact
+func parseGameClock(facts []map[string]string) (*ClockSettings, error) {
+	switch len(facts) {
+	case 0:
+		return nil, nil
+	case 1:
+	default:
+		return nil, errors.New("multiple game-clock facts found in the rules location")
+	}
+	slot := func(name, def string) string {
+		if value, ok := facts[0][name]; ok && value != "nil" {
+			return value
+		}
+		return def
+	}
+
+	settings := &ClockSettings{
+		Mode:         slot("mode", ClockPerMove),
+		TurnRelation: slot("turn-relation", "turn"),
+		TurnSlot:     slot("turn-slot", "player"),
+	}
+	if settings.Mode != ClockPerMove && settings.Mode != ClockTotal {
+		return nil, errors.New("game-clock mode slot must be per-move or total")
+	}
+	if t, err := strconv.ParseInt(slot("time-ms", ""), 10, 64); err != nil || t <= 0 {
+		return nil, errors.New("game-clock time-ms slot must be a positive integer")
+	} else {
+		settings.Time = t
+	}
+	if inc, err := strconv.ParseInt(slot("increment-ms", "0"), 10, 64); err != nil || inc < 0 {
+		return nil, errors.New("game-clock increment-ms slot must be a non negative integer")
+	} else {
+		settings.Increment = inc
+	}
+	return settings, nil
+}
+
+// checkClockTemplates verifies that the rules define the relations the clock reads and asserts
+func checkClockTemplates(settings *ClockSettings, templates map[string]*TemplateSchema) error {
+	if tmpl, ok := templates[settings.TurnRelation]; !ok || tmpl.Slot(settings.TurnSlot) == nil {
+		return fmt.Errorf("game-clock turn relation %s with slot %s not found", settings.TurnRelation, settings.TurnSlot)
+	}
+	if tmpl, ok := templates[timeoutRelation]; !ok || tmpl.Slot("seat") == nil {
+		return errors.New("game-clock found but no timeout deftemplate with a seat slot")
+	}
+	return nil
+}
+
+// activeSeat returns the seat whose turn it is from the facts of the turn relation, empty when there is none
+func activeSeat(turnFacts []map[string]string, turnSlot string, seats []string) string {
+	for _, fact := range turnFacts {
+		if seat := fact[turnSlot]; isInSlice(seats, seat) {
+			return seat
+		}
+	}
+	return ""
+}
+
+// roomClock times the seats of a room. Only the active seat is timed, from the moment every seat is taken until
+// the game ends; a seat that runs out of time is not timed again until its next turn.
+type roomClock struct {
+	settings  ClockSettings
+	remaining map[string]time.Duration // left to each seat, as of since for the active one
+	active    string
+	since     time.Time // when the active seat started to be timed
+	running   bool
+	paused    bool // a disconnected player is past its grace period, nobody is timed until it is back
+	stopped   bool // the game is over, the clock never runs again
+	timer     *time.Timer
+	gen       int // timers of older turns are ignored
+	onTimeout func(seat string)
+	mutex     sync.Mutex
+}
+
+func newRoomClock(settings ClockSettings, seats []string, onTimeout func(seat string)) *roomClock {
+	c := &roomClock{
+		settings:  settings,
+		remaining: make(map[string]time.Duration, len(seats)),
+		onTimeout: onTimeout,
+		mutex:     sync.Mutex{},
+	}
+	for _, seat := range seats {
+		c.remaining[seat] = time.Duration(settings.Time) * time.Millisecond
+	}
+	return c
+}
+
+// charge takes the time spent since the last change from the active seat, the caller holds the mutex
+func (c *roomClock) charge(now time.Time) {
+	if c.running && !c.paused && c.active != "" {
+		c.remaining[c.active] -= now.Sub(c.since)
+		if c.remaining[c.active] < 0 {
+			c.remaining[c.active] = 0
+		}
+	}
+	c.since = now
+}
+
+// schedule arms the timer of the active seat, the caller holds the mutex
+func (c *roomClock) schedule() {
+	c.gen++
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if !c.running || c.paused || c.active == "" || c.remaining[c.active] <= 0 {
+		return
+	}
+	gen, seat := c.gen, c.active
+	c.timer = time.AfterFunc(c.remaining[seat], func() { c.expire(gen, seat) })
+}
+
+// expire is called by the timer of a seat, the seat is out of time unless its turn is over already
+func (c *roomClock) expire(gen int, seat string) {
+	c.mutex.Lock()
+	if gen != c.gen || !c.running || c.paused || c.active != seat {
+		c.mutex.Unlock()
+		return
+	}
+	c.remaining[seat] = 0
+	c.since = time.Now()
+	c.timer = nil
+	c.mutex.Unlock()
+	if c.onTimeout != nil {
+		c.onTimeout(seat)
+	}
+}
+
+// start times the active seat, once every seat is taken
+func (c *roomClock) start(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.running || c.stopped {
+		return
+	}
+	c.running = true
+	c.since = now
+	c.schedule()
+}
+
+// stop ends the timing for good, when the game is over
+func (c *roomClock) stop(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.charge(now)
+	c.running = false
+	c.stopped = true
+	c.schedule()
+}
+
+// pause stops timing the active seat until resume is called, the time already spent is kept
+func (c *roomClock) pause(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.paused {
+		return
+	}
+	c.charge(now)
+	c.paused = true
+	c.schedule()
+}
+
+// resume times the active seat again after a pause
+func (c *roomClock) resume(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.paused {
+		return
+	}
+	c.paused = false
+	c.since = now
+	c.schedule()
+}
+
+// setActive gives the turn to a seat after a run. The seat that moved gets the increment, in per-move mode the
+// new active seat gets the whole time.
+func (c *roomClock) setActive(seat string, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if seat == c.active {
+		return
+	}
+	c.charge(now)
+	if c.running && c.active != "" && c.remaining[c.active] > 0 {
+		c.remaining[c.active] += time.Duration(c.settings.Increment) * time.Millisecond
+	}
+	c.active = seat
+	if c.settings.Mode == ClockPerMove && seat != "" {
+		c.remaining[seat] = time.Duration(c.settings.Time) * time.Millisecond
+	}
+	c.schedule()
+}
+
+// info tells the clock state, the time left to the active seat counts down to now
+func (c *roomClock) info(now time.Time) map[string]any {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	remaining := make(map[string]int64, len(c.remaining))
+	for seat, left := range c.remaining {
+		if c.running && !c.paused && seat == c.active {
+			left -= now.Sub(c.since)
+		}
+		if left < 0 {
+			left = 0
+		}
+		remaining[seat] = left.Milliseconds()
+	}
+	return map[string]any{
+		"mode":         c.settings.Mode,
+		"active":       c.active,
+		"running":      c.running,
+		"paused":       c.paused,
+		"remaining_ms": remaining,
+		"increment_ms": c.settings.Increment,
+	}
+}
+
+// clockInfo returns the state of the room clock, nil when the game has no clock
+func (r *Room) clockInfo() map[string]any {
+	if r.clock == nil {
+		return nil
+	}
+	return r.clock.info(time.Now())
+}
+
+// clockTimeout tells the rules that a seat ran out of time, they decide what happens to the player
+func (e *Engine) clockTimeout(room *Room, seat string) {
+	if room.hasEnded() || room.corruptedReason() != "" {
+		return
+	}
+	client := ""
+	room.clientsMutex.RLock()
+	for clientID, s := range room.seats {
+		if s == seat {
+			client = clientID
+		}
+	}
+	room.clientsMutex.RUnlock()
+
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/clockTimeout]")+" ", 0)
+		l.Printf("Seat %s (%s) ran out of time in room %s", seat, client, room.id)
+	}
+	room.emit(EventClockTimeout, "", map[string]string{"seat": seat, "client": client})
+	if e.ClipsLessMode {
+		return
+	}
+	fact := "(" + timeoutRelation + " (seat " + seat + "))"
+	if _, ce := e.execAssertion(context.Background(), room, "clock", timeoutRelation, []string{fact}); ce != nil && e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/clockTimeout]")+" ", 0)
+		l.Printf("Failed to assert the timeout in room %s: %s", room.id, ce.Message)
+	}
+}
@@ -0,0 +1,151 @@
+package rulemancer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseGameClock(t *testing.T) {
+	tests := []struct {
+		name   string
+		facts  []map[string]string
+		clock  *ClockSettings
+		failed bool
+	}{
+		{name: "none", facts: nil, clock: nil},
+		{
+			name:  "defaults",
+			facts: []map[string]string{{"mode": "nil", "time-ms": "60000", "increment-ms": "nil", "turn-relation": "nil", "turn-slot": "nil"}},
+			clock: &ClockSettings{Mode: ClockPerMove, Time: 60000, TurnRelation: "turn", TurnSlot: "player"},
+		},
+		{
+			name:  "total with increment",
+			facts: []map[string]string{{"mode": "total", "time-ms": "300000", "increment-ms": "2000", "turn-relation": "to-move", "turn-slot": "side"}},
+			clock: &ClockSettings{Mode: ClockTotal, Time: 300000, Increment: 2000, TurnRelation: "to-move", TurnSlot: "side"},
+		},
+		{name: "unknown mode", facts: []map[string]string{{"mode": "hourglass", "time-ms": "1000"}}, failed: true},
+		{name: "missing time", facts: []map[string]string{{"mode": "total"}}, failed: true},
+		{name: "negative increment", facts: []map[string]string{{"time-ms": "1000", "increment-ms": "-5"}}, failed: true},
+		{name: "declared twice", facts: []map[string]string{{"time-ms": "1000"}, {"time-ms": "2000"}}, failed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock, err := parseGameClock(tt.facts)
+			if tt.failed {
+				if err == nil {
+					t.Errorf("expected an error, got %v", clock)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (clock == nil) != (tt.clock == nil) || (clock != nil && *clock != *tt.clock) {
+				t.Errorf("expected %v, got %v", tt.clock, clock)
+			}
+		})
+	}
+}
+
+func TestActiveSeat(t *testing.T) {
+	seats := []string{"x", "o"}
+	if seat := activeSeat([]map[string]string{{"player": "o"}}, "player", seats); seat != "o" {
+		t.Errorf("expected o, got %q", seat)
+	}
+	if seat := activeSeat([]map[string]string{{"player": "draw"}}, "player", seats); seat != "" {
+		t.Errorf("expected no seat, got %q", seat)
+	}
+	if seat := activeSeat(nil, "player", seats); seat != "" {
+		t.Errorf("expected no seat, got %q", seat)
+	}
+}
+
+func TestRoomClockCharge(t *testing.T) {
+	start := time.Now()
+	remaining := func(c *roomClock, now time.Time, seat string) int64 {
+		return c.info(now)["remaining_ms"].(map[string]int64)[seat]
+	}
+
+	total := newRoomClock(ClockSettings{Mode: ClockTotal, Time: 10000, Increment: 1000}, []string{"x", "o"}, nil)
+	total.setActive("x", start)
+	if remaining(total, start.Add(3*time.Second), "x") != 10000 {
+		t.Errorf("the clock must not run before the start")
+	}
+	total.start(start)
+	if got := remaining(total, start.Add(3*time.Second), "x"); got != 7000 {
+		t.Errorf("expected 7000ms left to x, got %d", got)
+	}
+	total.setActive("o", start.Add(3*time.Second))
+	if got := remaining(total, start.Add(5*time.Second), "x"); got != 8000 {
+		t.Errorf("expected 8000ms left to x after the increment, got %d", got)
+	}
+	if got := remaining(total, start.Add(5*time.Second), "o"); got != 8000 {
+		t.Errorf("expected 8000ms left to o, got %d", got)
+	}
+	total.stop(start.Add(5 * time.Second))
+	if got := remaining(total, start.Add(9*time.Second), "o"); got != 8000 {
+		t.Errorf("expected the clock to stop at 8000ms for o, got %d", got)
+	}
+	total.start(start.Add(9 * time.Second))
+	if total.info(start)["running"].(bool) {
+		t.Errorf("a stopped clock must not start again")
+	}
+
+	perMove := newRoomClock(ClockSettings{Mode: ClockPerMove, Time: 10000}, []string{"x", "o"}, nil)
+	perMove.setActive("x", start)
+	perMove.start(start)
+	perMove.setActive("o", start.Add(4*time.Second))
+	perMove.setActive("x", start.Add(6*time.Second))
+	if got := remaining(perMove, start.Add(6*time.Second), "x"); got != 10000 {
+		t.Errorf("expected the whole time for x on a new move, got %d", got)
+	}
+	if got := remaining(perMove, start.Add(6*time.Second), "o"); got != 8000 {
+		t.Errorf("expected 8000ms left to o, got %d", got)
+	}
+	perMove.stop(start.Add(6 * time.Second))
+}
+
+func TestRoomClockTimeout(t *testing.T) {
+	expired := make(chan string, 2)
+	clock := newRoomClock(ClockSettings{Mode: ClockPerMove, Time: 20}, []string{"x", "o"}, func(seat string) { expired <- seat })
+	clock.setActive("x", time.Now())
+	clock.start(time.Now())
+
+	select {
+	case seat := <-expired:
+		if seat != "x" {
+			t.Errorf("expected x to run out of time, got %s", seat)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the timeout never came")
+	}
+
+	// A turn that ends in time never expires
+	clock = newRoomClock(ClockSettings{Mode: ClockPerMove, Time: 50}, []string{"x", "o"}, func(seat string) { expired <- seat })
+	clock.setActive("x", time.Now())
+	clock.start(time.Now())
+	clock.stop(time.Now())
+	select {
+	case seat := <-expired:
+		t.Errorf("unexpected timeout of %s", seat)
+	case <-time.After(150 * time.Millisecond):
+	}
+}
+
+func TestRoomClockPause(t *testing.T) {
+	start := time.Now()
+	clock := newRoomClock(ClockSettings{Mode: ClockTotal, Time: 10000}, []string{"x", "o"}, nil)
+	clock.setActive("x", start)
+	clock.start(start)
+	clock.pause(start.Add(2 * time.Second))
+	info := clock.info(start.Add(6 * time.Second))
+	if got := info["remaining_ms"].(map[string]int64)["x"]; got != 8000 || !info["paused"].(bool) {
+		t.Errorf("expected the clock paused at 8000ms for x, got %d", got)
+	}
+	clock.resume(start.Add(6 * time.Second))
+	if got := clock.info(start.Add(7 * time.Second))["remaining_ms"].(map[string]int64)["x"]; got != 7000 {
+		t.Errorf("expected 7000ms left to x after the pause, got %d", got)
+	}
+	clock.stop(start.Add(7 * time.Second))
+}
@@ -0,0 +1,143 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"context"
+	"strings"
+)
+
+// gameClone is a CLIPS instance of its own loaded with the rules of a room game, where the facts of the room are
+// copied to look ahead without touching the room. The methods ending in Atomic must be called from a job of the
+// clone instance.
+type gameClone struct {
+	e    *Engine
+	game *Game
+	ci   *ClipsInstance
+}
+
+// newGameClone loads the rules of the room game, with the room parameters, in a new CLIPS instance
+func (e *Engine) newGameClone(room *Room) (*gameClone, error) {
+	ci := e.NewClipsInstance()
+	ci.SetRunLimits(room.game.runLimits)
+	if err := ci.InitClips(); err != nil {
+		return nil, err
+	}
+	if err := ci.loadGame(room.game.rulesLocation, paramFacts(room.params)); err != nil {
+		ci.Dispose()
+		return nil, err
+	}
+	return &gameClone{e: e, game: room.game, ci: ci}, nil
+}
+
+func (c *gameClone) Close() {
+	c.ci.Dispose()
+}
+
+// roomFacts returns the facts of the room, one per item
+func roomFacts(ctx context.Context, room *Room) ([]string, error) {
+	raw, err := room.clipsInstance.QueryFactsAllFacts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return factsList(raw), nil
+}
+
+// factRelation returns the relation of a fact
+func factRelation(fact string) string {
+	fact = strings.TrimPrefix(strings.TrimSpace(fact), "(")
+	if end := strings.IndexAny(fact, " \t\n)"); end >= 0 {
+		return fact[:end]
+	}
+	return fact
+}
+
+// restoreAtomic replaces the facts of the clone with the given room facts. The rules already fired on them in the
+// room, so only the rules with a logical conditional element fire again: the legal moves are not copied, a copied
+// fact loses the logical support it had in the room and would outlive the move that made it stale, those rules
+// derive the moves again instead.
+func (c *gameClone) restoreAtomic(facts []string) error {
+	copied := facts
+	if c.game.moves != nil {
+		copied = make([]string, 0, len(facts))
+		for _, fact := range facts {
+			if factRelation(fact) != c.game.moves.Relation {
+				copied = append(copied, fact)
+			}
+		}
+	}
+	if err := c.ci.copyFactsAtomic(copied); err != nil {
+		return err
+	}
+	if err := c.ci.refreshLogicalAtomic(); err != nil {
+		return err
+	}
+	return c.runAtomic()
+}
+
+// runAtomic runs the rules of the clone, what they print is of no interest to the room
+func (c *gameClone) runAtomic() error {
+	if err := c.ci.RunAtomic(); err != nil {
+		return err
+	}
+	_, err := c.ci.TakeOutputAtomic()
+	return err
+}
+
+// applyAtomic asserts the facts of a move and runs the rules
+func (c *gameClone) applyAtomic(facts []string) error {
+	for _, fact := range facts {
+		if err := c.ci.AssertFactAtomic(fact); err != nil {
+			return err
+		}
+	}
+	return c.runAtomic()
+}
+
+// queryAtomic returns the facts of a relation in the clone
+func (c *gameClone) queryAtomic(rel string) ([]map[string]string, error) {
+	raw, err := c.ci.QueryFactsAtomic(rel)
+	if err != nil {
+		return nil, err
+	}
+	return genericFactToMap(c.e.Config, rel, raw)
+}
+
+// turnAtomic returns the seat to move in the clone, empty when the game has no turns or the turn relation names
+// no seat
+func (c *gameClone) turnAtomic() (string, error) {
+	rel, slot := c.game.turnRelation()
+	if rel == "" {
+		return "", nil
+	}
+	facts, err := c.queryAtomic(rel)
+	if err != nil {
+		return "", err
+	}
+	return activeSeat(facts, slot, c.game.seats), nil
+}
+
+// movesAtomic returns the legal moves of a seat in the clone, of every seat when the seat is empty
+func (c *gameClone) movesAtomic(seat string) ([]BotMove, error) {
+	facts, err := c.queryAtomic(c.game.moves.Relation)
+	if err != nil {
+		return nil, err
+	}
+	return c.game.movePayload(facts, seat), nil
+}
+
+// endingAtomic returns the facts of the relations ending the game in the clone, empty while the game is on
+func (c *gameClone) endingAtomic() (map[string][]map[string]string, error) {
+	ending := make(map[string][]map[string]string)
+	for _, rel := range c.game.endRelations {
+		facts, err := c.queryAtomic(rel)
+		if err != nil {
+			return nil, err
+		}
+		if len(facts) > 0 {
+			ending[rel] = facts
+		}
+	}
+	return ending, nil
+}
@@ -0,0 +1,114 @@
+package rulemancer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// cloneTestRules mark cells in turn. The legal moves are derived with a logical conditional element, each turn is
+// logged with a new symbol so that a rule firing again on the copied facts leaves a fact of its own.
+const cloneTestRules = `
+(deftemplate turn (slot player))
+(deftemplate cell (slot n) (slot owner (default none)))
+(deftemplate mark (slot n))
+(deftemplate legal-move (slot n) (slot player))
+(deftemplate turn-log (slot player) (slot entry))
+
+(deffacts start
+  (turn (player x))
+  (cell (n 1))
+  (cell (n 2))
+  (cell (n 3)))
+
+(defrule legal-moves
+  (logical (turn (player ?p)) (cell (n ?n) (owner none)))
+  =>
+  (assert (legal-move (n ?n) (player ?p))))
+
+(defrule log-turn
+  (turn (player ?p))
+  =>
+  (assert (turn-log (player ?p) (entry (gensym*)))))
+
+(defrule mark-cell
+  ?m <- (mark (n ?n))
+  ?t <- (turn (player ?p))
+  ?c <- (cell (n ?n) (owner none))
+  =>
+  (retract ?m)
+  (modify ?c (owner ?p))
+  (modify ?t (player (if (eq ?p x) then o else x))))
+`
+
+// sortedFacts returns the facts of an instance, sorted
+func sortedFacts(t *testing.T, ci *ClipsInstance) []string {
+	raw, err := ci.QueryFactsAllFacts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	facts := factsList(raw)
+	sort.Strings(facts)
+	return facts
+}
+
+func TestGameCloneRestore(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "game.clp"), []byte(cloneTestRules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e := NewEngine("secret")
+
+	source := e.NewClipsInstance()
+	if err := source.InitClips(); err != nil {
+		t.Fatal(err)
+	}
+	defer source.Dispose()
+	if source.cl == nil {
+		t.Skip("CLIPS environment not available")
+	}
+	if err := source.loadGame(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.AssertFact("(mark (n 2))"); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	ci := e.NewClipsInstance()
+	if err := ci.InitClips(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ci.loadGame(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	clone := &gameClone{e: e, game: &Game{moves: &MoveSettings{Relation: "legal-move"}}, ci: ci}
+	defer clone.Close()
+
+	expected := sortedFacts(t, source)
+	if err := ci.Do(context.Background(), func() error { return clone.restoreAtomic(expected) }); err != nil {
+		t.Fatal(err)
+	}
+	if facts := sortedFacts(t, ci); !reflect.DeepEqual(facts, expected) {
+		t.Errorf("expected the facts of the source %v, got %v", expected, facts)
+	}
+
+	// The moves derived again in the clone follow the moves played in the clone
+	if err := ci.Do(context.Background(), func() error { return clone.applyAtomic([]string{"(mark (n 1))"}) }); err != nil {
+		t.Fatal(err)
+	}
+	moves := make([]string, 0)
+	for _, fact := range sortedFacts(t, ci) {
+		if factRelation(fact) == "legal-move" {
+			moves = append(moves, fact)
+		}
+	}
+	if len(moves) != 1 || moves[0] != "(legal-move (n 3) (player x))" {
+		t.Errorf("expected x to be left with cell 3 only, got %v", moves)
+	}
+}
@@ -0,0 +1,278 @@
+package rulemancer
+
+import (
+	"strconv"
+	"strings"
+)
+
+const clipsUnbounded = "?VARIABLE"
+
+// SlotRange holds the numeric range allowed for a slot, an empty bound means unbounded
+type SlotRange struct {
+	Min string `json:"min,omitempty"`
+	Max string `json:"max,omitempty"`
+}
+
+// SlotSchema describes a slot or multislot of a deftemplate as reported by the CLIPS environment
+type SlotSchema struct {
+	Name          string     `json:"name"`
+	Multi         bool       `json:"multislot"`
+	Types         []string   `json:"types"`
+	AllowedValues []string   `json:"allowed_values,omitempty"`
+	Range         *SlotRange `json:"range,omitempty"`
+	DefaultType   string     `json:"default_type"` // none, static or dynamic
+	Default       []string   `json:"default,omitempty"`
+}
+
+// TemplateSchema describes a deftemplate and its slots, in declaration order
+type TemplateSchema struct {
+	Name  string       `json:"name"`
+	Slots []SlotSchema `json:"slots"`
+}
+
+// Slot returns the schema of the named slot, nil if the template has no such slot
+func (t *TemplateSchema) Slot(name string) *SlotSchema {
+	for i := range t.Slots {
+		if t.Slots[i].Name == name {
+			return &t.Slots[i]
+		}
+	}
+	return nil
+}
+
+// SlotNames returns the names of the single field slots of the template
+func (t *TemplateSchema) SlotNames() []string {
+	names := make([]string, 0)
+	for _, slot := range t.Slots {
+		if !slot.Multi {
+			names = append(names, slot.Name)
+		}
+	}
+	return names
+}
+
+// RequiredSlotNames returns the names of the single field slots a fact must give, those without a static or
+// dynamic default that CLIPS would fill in by itself
+func (t *TemplateSchema) RequiredSlotNames() []string {
+	names := make([]string, 0)
+	for _, slot := range t.Slots {
+		if !slot.Multi && slot.DefaultType != "static" && slot.DefaultType != "dynamic" {
+			names = append(names, slot.Name)
+		}
+	}
+	return names
+}
+
+// MultislotNames returns the names of the multislots of the template
+func (t *TemplateSchema) MultislotNames() []string {
+	names := make([]string, 0)
+	for _, slot := range t.Slots {
+		if slot.Multi {
+			names = append(names, slot.Name)
+		}
+	}
+	return names
+}
+
+// Numeric reports whether the slot only accepts numbers
+func (s SlotSchema) Numeric() bool {
+	if len(s.Types) == 0 {
+		return false
+	}
+	for _, t := range s.Types {
+		if t != "INTEGER" && t != "FLOAT" {
+			return false
+		}
+	}
+	return true
+}
+
+// Hint returns a short human readable description of the values accepted by the slot
+func (s SlotSchema) Hint() string {
+	parts := make([]string, 0)
+	if len(s.Types) > 0 && len(s.Types) < len(clipsAllTypes) {
+		parts = append(parts, strings.Join(s.Types, "|"))
+	}
+	if len(s.AllowedValues) > 0 {
+		parts = append(parts, "one of "+strings.Join(s.AllowedValues, " "))
+	}
+	if s.Range != nil {
+		min, max := s.Range.Min, s.Range.Max
+		if min == "" {
+			min = "-inf"
+		}
+		if max == "" {
+			max = "+inf"
+		}
+		parts = append(parts, "range "+min+".."+max)
+	}
+	if len(s.Default) > 0 && s.DefaultType == "static" {
+		parts = append(parts, "default "+strings.Join(s.Default, " "))
+	}
+	if s.Multi {
+		parts = append(parts, "multislot")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// clipsAllTypes is the type list reported by CLIPS for slots without type restrictions
+var clipsAllTypes = []string{"FLOAT", "INTEGER", "SYMBOL", "STRING", "EXTERNAL-ADDRESS", "FACT-ADDRESS", "INSTANCE-ADDRESS", "INSTANCE-NAME"}
+
+// parseTemplates decodes the deftemplates dump produced by clips_templates_as_string. Every slot is a record
+// terminated by \x1e with fields separated by \x1f: template, slot, multi, types, allowed values, range,
+// default type and default value.
+func parseTemplates(raw string) map[string]*TemplateSchema {
+	templates := make(map[string]*TemplateSchema)
+
+	for _, record := range strings.Split(raw, "\x1e") {
+		if record == "" {
+			continue
+		}
+		fields := strings.Split(record, "\x1f")
+		if len(fields) != 8 {
+			continue
+		}
+
+		tmpl, ok := templates[fields[0]]
+		if !ok {
+			tmpl = &TemplateSchema{Name: fields[0], Slots: make([]SlotSchema, 0)}
+			templates[fields[0]] = tmpl
+		}
+
+		slot := SlotSchema{
+			Name:          fields[1],
+			Multi:         fields[2] == "1",
+			Types:         factsSplit(fields[3]),
+			AllowedValues: quotedSplit(fields[4]),
+			DefaultType:   fields[6],
+			Default:       quotedSplit(fields[7]),
+		}
+		if bounds := factsSplit(fields[5]); len(bounds) == 2 && (bounds[0] != clipsUnbounded || bounds[1] != clipsUnbounded) {
+			slot.Range = &SlotRange{}
+			if bounds[0] != clipsUnbounded {
+				slot.Range.Min = bounds[0]
+			}
+			if bounds[1] != clipsUnbounded {
+				slot.Range.Max = bounds[1]
+			}
+		}
+		if len(slot.AllowedValues) == 0 {
+			slot.AllowedValues = nil
+		}
+		if len(slot.Default) == 0 {
+			slot.Default = nil
+		}
+
+		tmpl.Slots = append(tmpl.Slots, slot)
+	}
+
+	return templates
+}
+
+// quotedSplit splits a list of CLIPS values keeping the quotes around strings, so that they can be told
+// apart from symbols
+func quotedSplit(input string) []string {
+	result := []string{}
+	var current strings.Builder
+	inQuotes := false
+
+	for i := 0; i < len(input); i++ {
+		char := input[i]
+		switch {
+		case char == '"':
+			inQuotes = !inQuotes
+			current.WriteByte(char)
+		case (char == ' ' || char == '\t') && !inQuotes:
+			if current.Len() > 0 {
+				result = append(result, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteByte(char)
+		}
+	}
+	if current.Len() > 0 {
+		result = append(result, current.String())
+	}
+
+	return result
+}
+
+// clipsValueType returns the CLIPS type of a single value as it would be parsed in a fact
+func clipsValueType(value string) string {
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		return "STRING"
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return "INTEGER"
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return "FLOAT"
+	}
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		return "INSTANCE-NAME"
+	}
+	return "SYMBOL"
+}
+
+// clipsDelimiters are the characters ending a CLIPS symbol or number
+const clipsDelimiters = " \t\r\n\f\v\"()&|<~;"
+
+// isClipsAtom reports whether a value is a single CLIPS atom: a string literal whose inner quotes are escaped,
+// or a number or symbol without delimiters. Payload values are pasted as they are in the asserted facts,
+// anything else could close the slot and assert facts of its own.
+func isClipsAtom(value string) bool {
+	if value == "" {
+		return false
+	}
+	if value[0] == '"' {
+		for i := 1; i < len(value); i++ {
+			switch value[i] {
+			case '\\':
+				i++
+			case '"':
+				return i == len(value)-1
+			}
+		}
+		return false
+	}
+	if strings.HasPrefix(value, "?") || strings.HasPrefix(value, "$?") {
+		// Variables are not values
+		return false
+	}
+	return !strings.ContainsAny(value, clipsDelimiters)
+}
+
+// checkValue verifies a single value against the slot constraints, it returns an empty string when the value
+// is acceptable and a description of the problem otherwise
+func (s SlotSchema) checkValue(value string) string {
+	valueType := clipsValueType(value)
+
+	if len(s.Types) > 0 && !isInSlice(s.Types, valueType) {
+		return "value " + value + " has type " + valueType + ", expected " + strings.Join(s.Types, "|")
+	}
+
+	// Allowed values restrict only the types they mention (allowed-symbols does not forbid integers)
+	sameType := false
+	for _, allowed := range s.AllowedValues {
+		if clipsValueType(allowed) == valueType {
+			sameType = true
+			break
+		}
+	}
+	if sameType && !isInSlice(s.AllowedValues, value) {
+		return "value " + value + " is not one of " + strings.Join(s.AllowedValues, " ")
+	}
+
+	if s.Range != nil && (valueType == "INTEGER" || valueType == "FLOAT") {
+		v, _ := strconv.ParseFloat(value, 64)
+		if min, err := strconv.ParseFloat(s.Range.Min, 64); err == nil && v < min {
+			return "value " + value + " is below the minimum " + s.Range.Min
+		}
+		if max, err := strconv.ParseFloat(s.Range.Max, 64); err == nil && v > max {
+			return "value " + value + " is above the maximum " + s.Range.Max
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,137 @@
+package rulemancer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTemplates(t *testing.T) {
+	raw := "move\x1fx\x1f0\x1fINTEGER\x1f\x1f1 3\x1fstatic\x1f1\x1e" +
+		"move\x1fplayer\x1f0\x1fSYMBOL\x1fx o\x1f?VARIABLE ?VARIABLE\x1fstatic\x1fx\x1e" +
+		"hand\x1fcards\x1f1\x1fSYMBOL STRING\x1f\x1f?VARIABLE ?VARIABLE\x1fnone\x1f\x1e" +
+		"hand\x1fowner\x1f0\x1fSTRING\x1f\"a b\" \"c\"\x1f?VARIABLE ?VARIABLE\x1fdynamic\x1f\"a b\"\x1e" +
+		"broken\x1frecord\x1e"
+
+	expected := map[string]*TemplateSchema{
+		"move": {Name: "move", Slots: []SlotSchema{
+			{Name: "x", Types: []string{"INTEGER"}, Range: &SlotRange{Min: "1", Max: "3"}, DefaultType: "static", Default: []string{"1"}},
+			{Name: "player", Types: []string{"SYMBOL"}, AllowedValues: []string{"x", "o"}, DefaultType: "static", Default: []string{"x"}},
+		}},
+		"hand": {Name: "hand", Slots: []SlotSchema{
+			{Name: "cards", Multi: true, Types: []string{"SYMBOL", "STRING"}, DefaultType: "none"},
+			{Name: "owner", Types: []string{"STRING"}, AllowedValues: []string{`"a b"`, `"c"`}, DefaultType: "dynamic", Default: []string{`"a b"`}},
+		}},
+	}
+
+	result := parseTemplates(raw)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("parseTemplates() = %+v, want %+v", result, expected)
+	}
+}
+
+func TestSlotCheckValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		slot     SlotSchema
+		value    string
+		expected string
+	}{
+		{
+			name:     "integer in range",
+			slot:     SlotSchema{Types: []string{"INTEGER"}, Range: &SlotRange{Min: "1", Max: "3"}},
+			value:    "2",
+			expected: "",
+		},
+		{
+			name:     "integer below range",
+			slot:     SlotSchema{Types: []string{"INTEGER"}, Range: &SlotRange{Min: "1", Max: "3"}},
+			value:    "0",
+			expected: "value 0 is below the minimum 1",
+		},
+		{
+			name:     "open upper bound",
+			slot:     SlotSchema{Types: []string{"INTEGER", "FLOAT"}, Range: &SlotRange{Min: "0"}},
+			value:    "1e9",
+			expected: "",
+		},
+		{
+			name:     "wrong type",
+			slot:     SlotSchema{Types: []string{"INTEGER"}},
+			value:    "x",
+			expected: "value x has type SYMBOL, expected INTEGER",
+		},
+		{
+			name:     "string is not a symbol",
+			slot:     SlotSchema{Types: []string{"SYMBOL"}},
+			value:    `"x"`,
+			expected: `value "x" has type STRING, expected SYMBOL`,
+		},
+		{
+			name:     "allowed symbol",
+			slot:     SlotSchema{Types: []string{"SYMBOL", "INTEGER"}, AllowedValues: []string{"x", "o"}},
+			value:    "o",
+			expected: "",
+		},
+		{
+			name:     "allowed symbols do not restrict integers",
+			slot:     SlotSchema{Types: []string{"SYMBOL", "INTEGER"}, AllowedValues: []string{"x", "o"}},
+			value:    "7",
+			expected: "",
+		},
+		{
+			name:     "symbol not allowed",
+			slot:     SlotSchema{Types: []string{"SYMBOL"}, AllowedValues: []string{"x", "o"}},
+			value:    "z",
+			expected: "value z is not one of x o",
+		},
+		{
+			name:     "no constraints",
+			slot:     SlotSchema{},
+			value:    "anything",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.slot.checkValue(tt.value)
+			if result != tt.expected {
+				t.Errorf("checkValue(%q) = %q, want %q", tt.value, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsClipsAtom(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{value: "x", expected: true},
+		{value: "-12", expected: true},
+		{value: "3.5e2", expected: true},
+		{value: "[room-1]", expected: true},
+		{value: `"two words"`, expected: true},
+		{value: `"say \"hi\" (twice)"`, expected: true},
+		{value: "", expected: false},
+		{value: "two words", expected: false},
+		{value: "x) (winner (player x", expected: false},
+		{value: "x\n", expected: false},
+		{value: `x"y`, expected: false},
+		{value: `"open`, expected: false},
+		{value: `"a" "b"`, expected: false},
+		{value: `"escaped end\"`, expected: false},
+		{value: "?x", expected: false},
+		{value: "$?rest", expected: false},
+		{value: "a;comment", expected: false},
+		{value: "~x", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := isClipsAtom(tt.value); got != tt.expected {
+				t.Errorf("isClipsAtom(%q) = %v, want %v", tt.value, got, tt.expected)
+			}
+		})
+	}
+}
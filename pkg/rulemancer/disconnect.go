@@ -0,0 +1,442 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Disconnect policies, what happens to the seat of a player that does not come back within the grace period
+const (
+	DisconnectForfeit = "forfeit" // a forfeit fact tells the rules, they decide the outcome
+	DisconnectPause   = "pause"   // the game waits for the player, nobody can act and the clock stops
+	DisconnectBot     = "bot"     // a bot takes over the seat
+	DisconnectHandoff = "handoff" // the seat goes to the first client standing by in the room
+)
+
+// Relation of the facts telling the rules that a player left its seat for good
+const forfeitRelation = "forfeit"
+
+// DisconnectSettings tell how long the seat of a disconnected player is kept and what happens next, declared
+// with a game-disconnect fact. A Grace of 0 leaves the seat to the player forever.
+type DisconnectSettings struct {
+	Policy string `json:"policy"`
+	Grace  int64  `json:"grace_ms"`
+	Bot    string `json:"bot,omitempty"` // strategy of the bot taking over, bot policy only
+}
+
+// parseGameDisconnect reads the optional game-disconnect fact, without it the seats are handed over after the
+// default grace period
+func parseGameDisconnect(facts []map[string]string, defaultGrace int64) (*DisconnectSettings, error) {
+	settings := &DisconnectSettings{Policy: DisconnectHandoff, Grace: defaultGrace}
+	switch len(facts) {
+	case 0:
+		return settings, nil
+	case 1:
+	default:
+		return nil, errors.New("multiple game-disconnect facts found in the rules location")
+	}
+	slot := func(name, def string) string {
+		if value, ok := facts[0][name]; ok && value != "nil" {
+			return value
+		}
+		return def
+	}
+
+	settings.Policy = slot("policy", DisconnectHandoff)
+	switch settings.Policy {
+	case DisconnectForfeit, DisconnectPause, DisconnectHandoff:
+	case DisconnectBot:
+		settings.Bot = slot("bot", "random")
+	default:
+		return nil, errors.New("game-disconnect policy slot must be forfeit, pause, bot or handoff")
+	}
+	if grace, err := strconv.ParseInt(slot("grace-ms", strconv.FormatInt(defaultGrace, 10)), 10, 64); err != nil || grace < 0 {
+		return nil, errors.New("game-disconnect grace-ms slot must be a non negative integer")
+	} else {
+		settings.Grace = grace
+	}
+	return settings, nil
+}
+
+// checkDisconnectTemplates verifies that the rules define the forfeit relation the forfeit policy asserts
+func checkDisconnectTemplates(settings *DisconnectSettings, templates map[string]*TemplateSchema) error {
+	if settings.Policy != DisconnectForfeit {
+		return nil
+	}
+	if tmpl, ok := templates[forfeitRelation]; !ok || tmpl.Slot("seat") == nil {
+		return errors.New("game-disconnect forfeit policy found but no forfeit deftemplate with a seat slot")
+	}
+	return nil
+}
+
+// seatHold is the seat of a disconnected player, kept for it during the grace period
+type seatHold struct {
+	seat    string
+	since   time.Time
+	timer   *time.Timer
+	expired bool // the grace period is over, the policy applies
+}
+
+// holdsInfo describes the seats of the disconnected players and the clients standing by
+func (r *Room) holdsInfo() map[string]any {
+	r.holdsMutex.Lock()
+	defer r.holdsMutex.Unlock()
+	holds := make(map[string]any, len(r.holds))
+	for clientID, hold := range r.holds {
+		holds[clientID] = map[string]any{"seat": hold.seat, "since": hold.since.Unix(), "expired": hold.expired}
+	}
+	standby := make([]string, len(r.standby))
+	copy(standby, r.standby)
+	return map[string]any{"held_seats": holds, "standby": standby}
+}
+
+// pausedBy returns the players the game waits for under the pause policy, sorted, none when it is on
+func (r *Room) pausedBy() []string {
+	r.holdsMutex.Lock()
+	defer r.holdsMutex.Unlock()
+	waiting := make([]string, 0)
+	if r.game.disconnect == nil || r.game.disconnect.Policy != DisconnectPause {
+		return waiting
+	}
+	for clientID, hold := range r.holds {
+		if hold.expired {
+			waiting = append(waiting, clientID)
+		}
+	}
+	sort.Strings(waiting)
+	return waiting
+}
+
+// releaseHolds stops the grace periods of the room, when it is removed
+func (r *Room) releaseHolds() {
+	r.holdsMutex.Lock()
+	defer r.holdsMutex.Unlock()
+	for clientID, hold := range r.holds {
+		hold.timer.Stop()
+		delete(r.holds, clientID)
+	}
+	r.standby = nil
+}
+
+// releaseHold forgets the seat held for a player that left the room
+func (r *Room) releaseHold(clientID string) {
+	r.holdsMutex.Lock()
+	defer r.holdsMutex.Unlock()
+	if hold, held := r.holds[clientID]; held {
+		hold.timer.Stop()
+		delete(r.holds, clientID)
+	}
+}
+
+// roomConnected records a websocket or an event stream opened on a room, a player coming back gets its seat
+func (e *Engine) roomConnected(room *Room, clientID string) {
+	if room.connected(clientID) {
+		e.playerReconnected(room, clientID)
+	}
+}
+
+// roomDisconnected records a websocket or an event stream closed, a player without any left has its seat held
+func (e *Engine) roomDisconnected(room *Room, clientID string) {
+	if room.disconnected(clientID) {
+		e.playerDisconnected(room, clientID)
+	}
+}
+
+// playerDisconnected holds the seat of a player of a game on that has no connection left to the room, the
+// policy of the game applies if it is not back within the grace period
+func (e *Engine) playerDisconnected(room *Room, clientID string) {
+	settings := room.game.disconnect
+	if settings == nil || settings.Grace <= 0 || room.hasEnded() {
+		return
+	}
+	room.clientsMutex.RLock()
+	client, playing := room.clients[clientID]
+	seat := room.seats[clientID]
+	full := len(room.clients) == room.maxClients
+	room.clientsMutex.RUnlock()
+	if !playing || client.bot || !full {
+		return
+	}
+
+	room.holdsMutex.Lock()
+	if _, held := room.holds[clientID]; held {
+		room.holdsMutex.Unlock()
+		return
+	}
+	if room.holds == nil {
+		room.holds = make(map[string]*seatHold)
+	}
+	hold := &seatHold{seat: seat, since: time.Now()}
+	hold.timer = time.AfterFunc(time.Duration(settings.Grace)*time.Millisecond, func() { e.seatExpired(room, clientID, hold) })
+	room.holds[clientID] = hold
+	room.holdsMutex.Unlock()
+
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/playerDisconnected]")+" ", 0)
+		l.Printf("Player %s (%s) disconnected from room %s, seat held for %d ms", clientID, seat, room.id, settings.Grace)
+	}
+	room.emit(EventPlayerDisconnected, clientID, map[string]any{
+		"client":   clientID,
+		"seat":     seat,
+		"grace_ms": settings.Grace,
+		"policy":   settings.Policy,
+	})
+}
+
+// playerReconnected gives back its seat to a player whose seat is held, a game paused for it goes on
+func (e *Engine) playerReconnected(room *Room, clientID string) {
+	room.holdsMutex.Lock()
+	hold, held := room.holds[clientID]
+	if !held {
+		room.holdsMutex.Unlock()
+		return
+	}
+	hold.timer.Stop()
+	delete(room.holds, clientID)
+	room.holdsMutex.Unlock()
+
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/playerReconnected]")+" ", 0)
+		l.Printf("Player %s (%s) is back in room %s", clientID, hold.seat, room.id)
+	}
+	room.emit(EventPlayerReconnected, clientID, map[string]string{"client": clientID, "seat": hold.seat})
+	if hold.expired && room.game.disconnect.Policy == DisconnectPause && len(room.pausedBy()) == 0 {
+		if room.clock != nil {
+			room.clock.resume(time.Now())
+		}
+		room.emit(EventGameResumed, clientID, map[string]string{"client": clientID})
+	}
+}
+
+// seatExpired applies the disconnect policy of the game to a player that did not come back in time
+func (e *Engine) seatExpired(room *Room, clientID string, hold *seatHold) {
+	settings := room.game.disconnect
+	room.holdsMutex.Lock()
+	if room.holds[clientID] != hold {
+		// The player is back already
+		room.holdsMutex.Unlock()
+		return
+	}
+	hold.expired = true
+	if room.hasEnded() || settings.Policy == DisconnectForfeit || settings.Policy == DisconnectBot {
+		delete(room.holds, clientID)
+	}
+	room.holdsMutex.Unlock()
+	if room.hasEnded() {
+		return
+	}
+
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/seatExpired]")+" ", 0)
+		l.Printf("Player %s (%s) did not come back to room %s, applying the %s policy", clientID, hold.seat, room.id, settings.Policy)
+	}
+	room.emit(EventSeatExpired, "", map[string]string{"client": clientID, "seat": hold.seat, "policy": settings.Policy})
+
+	switch settings.Policy {
+	case DisconnectForfeit:
+		if e.ClipsLessMode {
+			return
+		}
+		fact := "(" + forfeitRelation + " (seat " + hold.seat + "))"
+		if _, ce := e.execAssertion(context.Background(), room, "disconnect", forfeitRelation, []string{fact}); ce != nil && e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/seatExpired]")+" ", 0)
+			l.Printf("Failed to assert the forfeit in room %s: %s", room.id, ce.Message)
+		}
+	case DisconnectPause:
+		if room.clock != nil {
+			room.clock.pause(time.Now())
+		}
+		room.emit(EventGamePaused, "", map[string]string{"client": clientID, "seat": hold.seat})
+	case DisconnectBot:
+		if _, ce := e.startBot(room, settings.Bot, "", func(bot *Client) *CommandError {
+			return e.replacePlayer(room, clientID, bot)
+		}); ce != nil && e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/seatExpired]")+" ", 0)
+			l.Printf("No bot could take over seat %s in room %s: %s", hold.seat, room.id, ce.Message)
+		}
+	case DisconnectHandoff:
+		e.handoff(room)
+	}
+}
+
+// standBy queues a client for the seats of the room that are handed over, it takes one at once if any is free
+func (e *Engine) standBy(room *Room, client *Client) *CommandError {
+	if room.game.disconnect == nil || room.game.disconnect.Policy != DisconnectHandoff {
+		return &CommandError{Status: http.StatusUnprocessableEntity, Message: "game does not hand over the seats"}
+	}
+	if room.hasEnded() {
+		return &CommandError{Status: http.StatusConflict, Message: "game has ended"}
+	}
+	room.clientsMutex.RLock()
+	_, playing := room.clients[client.id]
+	room.clientsMutex.RUnlock()
+	if playing {
+		return &CommandError{Status: http.StatusConflict, Message: "client already in room"}
+	}
+
+	room.holdsMutex.Lock()
+	if !isInSlice(room.standby, client.id) {
+		room.standby = append(room.standby, client.id)
+	}
+	room.holdsMutex.Unlock()
+	e.handoff(room)
+	return nil
+}
+
+// leaveStandby takes a client out of the standby queue of the room
+func (e *Engine) leaveStandby(room *Room, clientID string) *CommandError {
+	room.holdsMutex.Lock()
+	defer room.holdsMutex.Unlock()
+	for i, waiting := range room.standby {
+		if waiting == clientID {
+			room.standby = append(room.standby[:i], room.standby[i+1:]...)
+			return nil
+		}
+	}
+	return &CommandError{Status: http.StatusNotFound, Message: "client not standing by"}
+}
+
+// handoff gives the expired seats of the room, in seat order, to the clients standing by, in the order they came
+func (e *Engine) handoff(room *Room) {
+	for {
+		room.holdsMutex.Lock()
+		leaving := ""
+		for clientID, hold := range room.holds {
+			if hold.expired && (leaving == "" || hold.seat < room.holds[leaving].seat) {
+				leaving = clientID
+			}
+		}
+		if leaving == "" || len(room.standby) == 0 {
+			room.holdsMutex.Unlock()
+			return
+		}
+		hold := room.holds[leaving]
+		delete(room.holds, leaving)
+		waiting := room.standby[0]
+		room.standby = room.standby[1:]
+		room.holdsMutex.Unlock()
+
+		client, err := e.searchClient(waiting)
+		if err == nil {
+			if ce := e.replacePlayer(room, leaving, client); ce == nil {
+				if e.Debug {
+					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/handoff]")+" ", 0)
+					l.Printf("Seat %s of room %s handed over from %s to %s", hold.seat, room.id, leaving, waiting)
+				}
+				continue
+			}
+		}
+		// The client standing by is gone or plays already, the seat waits for the next one
+		room.holdsMutex.Lock()
+		if _, held := room.holds[leaving]; !held {
+			room.holds[leaving] = hold
+		}
+		room.holdsMutex.Unlock()
+	}
+}
+
+// replacePlayer gives the seat of a player to another client, the game goes on as if it always had it
+func (e *Engine) replacePlayer(room *Room, leaving string, client *Client) *CommandError {
+	room.clientsMutex.Lock()
+	defer room.clientsMutex.Unlock()
+	old, playing := room.clients[leaving]
+	if !playing {
+		return &CommandError{Status: http.StatusConflict, Message: "player already left"}
+	}
+	if _, exists := room.clients[client.id]; exists {
+		return &CommandError{Status: http.StatusConflict, Message: "client already in room"}
+	}
+	seat := room.seats[leaving]
+	room.removePlayer(leaving)
+	old.roomsMutex.Lock()
+	delete(old.playingRooms, room.id)
+	old.roomsMutex.Unlock()
+
+	room.watchersMutex.Lock()
+	delete(room.watchers, client.id)
+	room.watchersMutex.Unlock()
+	client.watchersMutex.Lock()
+	delete(client.watchingRooms, room.id)
+	client.watchersMutex.Unlock()
+
+	room.clients[client.id] = client
+	room.seats[client.id] = seat
+	client.roomsMutex.Lock()
+	client.playingRooms[room.id] = room
+	client.roomsMutex.Unlock()
+
+	room.logAction(ActionLogEntry{Kind: "handoff", Actor: client.id, Text: leaving + " " + seat})
+	room.emit(EventPlayerLeft, leaving, map[string]string{"client": leaving, "reason": "disconnected"})
+	room.emit(EventPlayerJoined, client.id, map[string]any{
+		"client":      client.id,
+		"name":        client.name,
+		"seat":        seat,
+		"players":     len(room.clients),
+		"max_players": room.maxClients,
+		"replaces":    leaving,
+	})
+	return nil
+}
+
+// apiStandby queues the requester for the seats of the room handed over by the disconnected players, with the
+// credentials needed to join it
+func (e *Engine) apiStandby(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	clientID, ok := requesterID(w, r)
+	if !ok {
+		return
+	}
+	creds, err := readRoomCredentials(r)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	if room, err := e.searchRoom(id); err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiStandby]")+" ", 0)
+			l.Printf("Room not found: %s", id)
+		}
+		Error(w, http.StatusNotFound, "room not found")
+	} else if ce := room.admit(clientID, creds, false); ce != nil {
+		CommandFailure(w, ce)
+	} else if client, err := e.searchClient(clientID); err != nil {
+		Error(w, http.StatusNotFound, "client not found")
+	} else if ce := e.standBy(room, client); ce != nil {
+		CommandFailure(w, ce)
+	} else {
+		room.clientsMutex.RLock()
+		seat, seated := room.seats[clientID]
+		room.clientsMutex.RUnlock()
+		if seated {
+			JSON(w, http.StatusOK, map[string]string{"status": "seated", "room_id": room.id, "seat": seat})
+		} else {
+			JSON(w, http.StatusAccepted, map[string]string{"status": "standing_by", "room_id": room.id})
+		}
+	}
+}
+
+// apiLeaveStandby takes the requester out of the standby queue of the room
+func (e *Engine) apiLeaveStandby(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if clientID, ok := requesterID(w, r); !ok {
+		return
+	} else if room, err := e.searchRoom(id); err != nil {
+		Error(w, http.StatusNotFound, "room not found")
+	} else if ce := e.leaveStandby(room, clientID); ce != nil {
+		CommandFailure(w, ce)
+	} else {
+		JSON(w, http.StatusOK, map[string]string{"status": "left", "room_id": room.id})
+	}
+}
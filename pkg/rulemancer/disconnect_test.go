@@ -0,0 +1,139 @@
+package rulemancer
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseGameDisconnect(t *testing.T) {
+	tests := []struct {
+		name     string
+		facts    []map[string]string
+		settings DisconnectSettings
+		failed   bool
+	}{
+		{name: "none", facts: nil, settings: DisconnectSettings{Policy: DisconnectHandoff, Grace: 60000}},
+		{
+			name:     "defaults",
+			facts:    []map[string]string{{"policy": "nil", "grace-ms": "nil", "bot": "nil"}},
+			settings: DisconnectSettings{Policy: DisconnectHandoff, Grace: 60000},
+		},
+		{
+			name:     "bot",
+			facts:    []map[string]string{{"policy": "bot", "grace-ms": "30000", "bot": "nil"}},
+			settings: DisconnectSettings{Policy: DisconnectBot, Grace: 30000, Bot: "random"},
+		},
+		{
+			name:     "forfeit kept forever",
+			facts:    []map[string]string{{"policy": "forfeit", "grace-ms": "0"}},
+			settings: DisconnectSettings{Policy: DisconnectForfeit, Grace: 0},
+		},
+		{name: "unknown policy", facts: []map[string]string{{"policy": "vanish"}}, failed: true},
+		{name: "negative grace", facts: []map[string]string{{"policy": "pause", "grace-ms": "-1"}}, failed: true},
+		{name: "declared twice", facts: []map[string]string{{"policy": "pause"}, {"policy": "bot"}}, failed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings, err := parseGameDisconnect(tt.facts, 60000)
+			if tt.failed {
+				if err == nil {
+					t.Errorf("expected an error, got %v", settings)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *settings != tt.settings {
+				t.Errorf("expected %v, got %v", tt.settings, *settings)
+			}
+		})
+	}
+}
+
+// newDisconnectTestRoom returns a room of a game with the given disconnect policy where alice and bob play
+func newDisconnectTestRoom(t *testing.T, policy string) (*Engine, *Room, socketChan, *Client, *Client) {
+	game := duelTestGame()
+	game.disconnect = &DisconnectSettings{Policy: policy, Grace: 30}
+	e, room, clients := newTestRoom(t, game, "alice", "bob")
+	sub, _, _, _ := room.events.subscribe("test", "", false, 0)
+	return e, room, sub.ch, clients[0], clients[1]
+}
+
+// waitEvent reads the room events until one of the given type comes
+func waitEvent(t *testing.T, ch socketChan, eventType string) RoomEvent {
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case msg := <-ch:
+			var event RoomEvent
+			if err := json.Unmarshal(msg.message, &event); err != nil {
+				t.Fatalf("invalid event %s: %v", msg.message, err)
+			}
+			if event.Type == eventType {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("no %s event", eventType)
+		}
+	}
+}
+
+func TestDisconnectHandoff(t *testing.T) {
+	e, room, ch, alice, bob := newDisconnectTestRoom(t, DisconnectHandoff)
+	carol := e.newClient("carol", "")
+
+	// A player back within the grace period keeps its seat
+	e.roomConnected(room, alice.id)
+	e.roomDisconnected(room, alice.id)
+	waitEvent(t, ch, EventPlayerDisconnected)
+	e.roomConnected(room, alice.id)
+	waitEvent(t, ch, EventPlayerReconnected)
+
+	if ce := e.standBy(room, carol); ce != nil {
+		t.Fatalf("unexpected error: %v", ce)
+	}
+	if ce := e.standBy(room, bob); ce == nil || ce.Status != http.StatusConflict {
+		t.Errorf("a player cannot stand by, got %v", ce)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, playing := room.clients[carol.id]; playing {
+		t.Fatalf("the seat of alice was handed over while she was back")
+	}
+
+	e.roomDisconnected(room, alice.id)
+	waitEvent(t, ch, EventSeatExpired)
+	joined := waitEvent(t, ch, EventPlayerJoined)
+	if payload := joined.Payload.(map[string]any); payload["client"] != carol.id || payload["seat"] != "x" || payload["replaces"] != alice.id {
+		t.Errorf("expected carol on the seat of alice, got %v", payload)
+	}
+	room.clientsMutex.RLock()
+	_, alicePlaying := room.clients[alice.id]
+	seat := room.seats[carol.id]
+	room.clientsMutex.RUnlock()
+	if alicePlaying || seat != "x" || carol.playingRooms[room.id] != room {
+		t.Errorf("expected carol to play x instead of alice, got seat %q", seat)
+	}
+}
+
+func TestDisconnectPause(t *testing.T) {
+	e, room, ch, alice, bob := newDisconnectTestRoom(t, DisconnectPause)
+	if ce := e.standBy(room, e.newClient("carol", "")); ce == nil || ce.Status != http.StatusUnprocessableEntity {
+		t.Errorf("seats are not handed over in a paused game, got %v", ce)
+	}
+
+	e.roomConnected(room, alice.id)
+	e.roomDisconnected(room, alice.id)
+	waitEvent(t, ch, EventGamePaused)
+	if ce := e.canAssert(room, bob.id); ce == nil || ce.Status != http.StatusConflict {
+		t.Errorf("expected bob to wait for alice, got %v", ce)
+	}
+	e.roomConnected(room, alice.id)
+	waitEvent(t, ch, EventGameResumed)
+	if ce := e.canAssert(room, bob.id); ce != nil {
+		t.Errorf("unexpected error: %v", ce)
+	}
+}
@@ -22,46 +22,66 @@ import (
 type Engine struct {
 	*Config
 	*jwtauth.JWTAuth
-	bridges      map[string]*Bridge
-	bridgesMutex sync.RWMutex
-	numBridges   int
-	brRooms      map[string]*BrRoom
-	brRoomsMutex sync.RWMutex
-	numBrRooms   int
-	games        map[string]*Game
-	gamesMutex   sync.RWMutex
-	numGames     int
-	rooms        map[string]*Room
-	roomsMutex   sync.RWMutex
-	numRooms     int
-	clients      map[string]*Client
-	clientsMutex sync.RWMutex
-	numClients   int
-	router       chi.Router
-	stopChan     chan os.Signal
+	bridges          map[string]*Bridge
+	bridgesMutex     sync.RWMutex
+	numBridges       int
+	brRooms          map[string]*BrRoom
+	brRoomsMutex     sync.RWMutex
+	numBrRooms       int
+	games            map[string]*Game
+	gamesMutex       sync.RWMutex
+	numGames         int
+	rooms            map[string]*Room
+	roomsMutex       sync.RWMutex
+	invites          map[string]string // room id by invite code, guarded by roomsMutex
+	numRooms         int
+	clients          map[string]*Client
+	clientsMutex     sync.RWMutex
+	numClients       int
+	lobby            *eventHub    // engine wide events, about the rooms
+	tickets          *ticketStore // websocket tickets issued and not used yet
+	ratings          *ratingStore // ratings of the clients, by game
+	stats            *statsStore  // results of the finished games
+	matchmaker       *matchmaker  // clients waiting to be matched, by game
+	tournaments      map[string]*Tournament
+	tournamentsMutex sync.RWMutex
+	parties          map[string]*Party
+	partiesMutex     sync.RWMutex
+	router           chi.Router
+	stopChan         chan os.Signal
 }
 
 func NewEngine(secret string) *Engine {
 	return &Engine{
-		Config:       NewConfig(),
-		JWTAuth:      jwtauth.New("HS256", []byte(secret), nil),
-		bridges:      make(map[string]*Bridge),
-		bridgesMutex: sync.RWMutex{},
-		numBridges:   0,
-		brRooms:      make(map[string]*BrRoom),
-		brRoomsMutex: sync.RWMutex{},
-		numBrRooms:   0,
-		games:        make(map[string]*Game),
-		gamesMutex:   sync.RWMutex{},
-		numGames:     0,
-		rooms:        make(map[string]*Room),
-		roomsMutex:   sync.RWMutex{},
-		numRooms:     0,
-		clients:      make(map[string]*Client),
-		clientsMutex: sync.RWMutex{},
-		numClients:   0,
-		router:       chi.NewRouter(),
-		stopChan:     make(chan os.Signal, 1),
+		Config:           NewConfig(),
+		JWTAuth:          jwtauth.New("HS256", []byte(secret), nil),
+		bridges:          make(map[string]*Bridge),
+		bridgesMutex:     sync.RWMutex{},
+		numBridges:       0,
+		brRooms:          make(map[string]*BrRoom),
+		brRoomsMutex:     sync.RWMutex{},
+		numBrRooms:       0,
+		games:            make(map[string]*Game),
+		gamesMutex:       sync.RWMutex{},
+		numGames:         0,
+		rooms:            make(map[string]*Room),
+		roomsMutex:       sync.RWMutex{},
+		invites:          make(map[string]string),
+		numRooms:         0,
+		clients:          make(map[string]*Client),
+		clientsMutex:     sync.RWMutex{},
+		numClients:       0,
+		lobby:            newEventHub("", NewConfig().EventBufferSize),
+		tickets:          newTicketStore(),
+		ratings:          newRatingStore(),
+		stats:            newStatsStore(),
+		matchmaker:       newMatchmaker(),
+		tournaments:      make(map[string]*Tournament),
+		tournamentsMutex: sync.RWMutex{},
+		parties:          make(map[string]*Party),
+		partiesMutex:     sync.RWMutex{},
+		router:           chi.NewRouter(),
+		stopChan:         make(chan os.Signal, 1),
 	}
 }
 
@@ -69,9 +89,16 @@ func (e *Engine) SpawnEngine() error {
 	// Implement the logic to spawn and run the CLIPS engine
 	// using the provided configuration and rule pool directory
 
+	// The configuration is final only now
+	e.lobby = newEventHub("", e.EventBufferSize)
+
 	e.loadGames()
+	e.loadStats()
 	e.loadBridges()
 
+	go e.watchPresence()
+	go e.runMatchmaker()
+
 	_, tokenString, _ := e.Encode(map[string]interface{}{"id": "admin"})
 	fmt.Printf("admin jwt: %s\n", tokenString)
 
@@ -96,6 +123,10 @@ func (e *Engine) SpawnEngine() error {
 		r.Route("/watch", e.watchRoutes)
 		r.Route("/new", e.newRoutes)
 		r.Route("/web", e.webClientRoutes)
+		r.Route("/lobby", e.lobbyRoutes)
+		r.Route("/match", e.matchRoutes)
+		r.Route("/tournament", e.tournamentRoutes)
+		r.Route("/party", e.partyRoutes)
 	})
 
 	srv := &http.Server{
@@ -119,6 +150,7 @@ func (e *Engine) SpawnEngine() error {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	e.stats.close()
 
 	log.Println("Server exiting")
 
@@ -0,0 +1,254 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+type socketMessage struct {
+	message []byte
+	seq     uint64 // sequence number of the event carried by the message
+}
+
+type socketChan chan socketMessage
+
+// subscriberBuffer is the number of messages queued for a subscriber before it is flagged as lagging
+const subscriberBuffer = 64
+
+// subscriber receives the events of a hub, a websocket or an event stream. When its channel is full the message
+// is dropped and the subscriber is signalled on lagged, it is then up to the subscriber to replay the missing
+// events. The subscription of a client is ended by closing closed, its websocket or event stream is then closed.
+type subscriber struct {
+	addr   string
+	client string // empty when the subscriber is not a client
+	ch     socketChan
+	lagged chan struct{}
+	closed chan struct{}
+}
+
+// eventHub numbers the events of a room or of the lobby, keeps the last ones in a ring to replay them and
+// sends them to the subscribers
+type eventHub struct {
+	room        string // room of the events, empty for the lobby
+	seq         uint64 // sequence number of the last event
+	ring        *eventRing
+	mutex       sync.Mutex
+	subscribers map[*subscriber]struct{}
+	subsMutex   sync.RWMutex
+}
+
+// snapshotFunc returns the full state a subscriber starts again from when the events it missed are gone
+type snapshotFunc func(ctx context.Context) (any, error)
+
+func newEventHub(room string, size int) *eventHub {
+	return &eventHub{
+		room:        room,
+		ring:        newEventRing(size),
+		mutex:       sync.Mutex{},
+		subscribers: make(map[*subscriber]struct{}),
+		subsMutex:   sync.RWMutex{},
+	}
+}
+
+// emit sends an event to the subscribers. The sequence number is assigned, the event stored in the ring and
+// broadcast under the same lock, so that the subscribers receive the events in sequence order.
+func (h *eventHub) emit(eventType, actor string, payload any) {
+	if h == nil {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.seq++
+	if message, err := encodeEvent(h.room, h.seq, eventType, actor, payload); err != nil {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/emit]")+" ", 0)
+		l.Printf("Error encoding %s event for room %s: %v", eventType, h.room, err)
+	} else {
+		h.ring.push(h.seq, message)
+		h.broadcast(message, h.seq)
+	}
+}
+
+func encodeEvent(room string, seq uint64, eventType, actor string, payload any) ([]byte, error) {
+	return json.Marshal(RoomEvent{
+		Version: EventProtocolVersion,
+		Type:    eventType,
+		Room:    room,
+		Seq:     seq,
+		Time:    time.Now().UnixMilli(),
+		Actor:   actor,
+		Payload: payload,
+	})
+}
+
+func (h *eventHub) broadcast(message []byte, seq uint64) {
+	h.subsMutex.RLock()
+	defer h.subsMutex.RUnlock()
+	for sub := range h.subscribers {
+		select {
+		case sub.ch <- socketMessage{message: message, seq: seq}:
+		default:
+			// The subscriber is too slow, it will replay what it missed from the ring
+			select {
+			case sub.lagged <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// subscribe registers a new subscriber. When replay is true, the events following since are returned to be sent
+// before anything else; resync is true when some of them are no longer in the ring. The current sequence number
+// is returned as well, it is the one of the snapshot to send on resync.
+func (h *eventHub) subscribe(addr, client string, replay bool, since uint64) (sub *subscriber, backlog []socketMessage, resync bool, seq uint64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	sub = &subscriber{
+		addr:   addr,
+		client: client,
+		ch:     make(socketChan, subscriberBuffer),
+		lagged: make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+	h.subsMutex.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.subsMutex.Unlock()
+
+	if replay {
+		backlog, resync = h.eventsSinceLocked(since)
+	}
+	return sub, backlog, resync, h.seq
+}
+
+func (h *eventHub) unsubscribe(sub *subscriber) {
+	h.subsMutex.Lock()
+	defer h.subsMutex.Unlock()
+	delete(h.subscribers, sub)
+}
+
+// closeClient ends the subscriptions of a client, they get no more events and their websockets and event streams
+// are closed
+func (h *eventHub) closeClient(client string) {
+	if h == nil || client == "" {
+		return
+	}
+	h.subsMutex.Lock()
+	defer h.subsMutex.Unlock()
+	for sub := range h.subscribers {
+		if sub.client == client {
+			delete(h.subscribers, sub)
+			close(sub.closed)
+		}
+	}
+}
+
+// eventsSince returns the events following since, see subscribe
+func (h *eventHub) eventsSince(since uint64) ([]socketMessage, bool, uint64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	backlog, resync := h.eventsSinceLocked(since)
+	return backlog, resync, h.seq
+}
+
+func (h *eventHub) eventsSinceLocked(since uint64) ([]socketMessage, bool) {
+	if since > h.seq {
+		// The client saw events that this hub never sent, probably from an older server
+		return nil, true
+	}
+	return h.ring.since(since)
+}
+
+// addrs returns the addresses of the subscribers
+func (h *eventHub) addrs() []string {
+	h.subsMutex.RLock()
+	defer h.subsMutex.RUnlock()
+	addrs := make([]string, 0, len(h.subscribers))
+	for sub := range h.subscribers {
+		addrs = append(addrs, sub.addr)
+	}
+	return addrs
+}
+
+// catchUp returns the messages to send to a subscriber that was sent the events up to lastSeq: the events of
+// the backlog it did not get yet or, on resync, the resync_required and snapshot events. Both carry the sequence
+// number the snapshot is taken at, the events following it are delivered as usual.
+func (h *eventHub) catchUp(ctx context.Context, snapshot snapshotFunc, lastSeq uint64, backlog []socketMessage, resync bool, seq uint64) ([]socketMessage, error) {
+	messages := make([]socketMessage, 0, len(backlog))
+	if !resync {
+		for _, msg := range backlog {
+			if msg.seq > lastSeq {
+				messages = append(messages, msg)
+			}
+		}
+		return messages, nil
+	}
+
+	state, err := snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range []struct {
+		eventType string
+		payload   any
+	}{
+		{EventResyncRequired, map[string]uint64{"since": lastSeq, "seq": seq}},
+		{EventSnapshot, state},
+	} {
+		if message, err := encodeEvent(h.room, seq, event.eventType, "", event.payload); err != nil {
+			return nil, err
+		} else {
+			messages = append(messages, socketMessage{message: message, seq: seq})
+		}
+	}
+	return messages, nil
+}
+
+// eventRing keeps the last encoded events of a hub
+type eventRing struct {
+	entries []socketMessage
+	start   int // index of the oldest entry
+	count   int
+}
+
+func newEventRing(size int) *eventRing {
+	if size < 1 {
+		size = 1
+	}
+	return &eventRing{entries: make([]socketMessage, size)}
+}
+
+func (er *eventRing) push(seq uint64, message []byte) {
+	size := len(er.entries)
+	if er.count < size {
+		er.entries[(er.start+er.count)%size] = socketMessage{message: message, seq: seq}
+		er.count++
+	} else {
+		er.entries[er.start] = socketMessage{message: message, seq: seq}
+		er.start = (er.start + 1) % size
+	}
+}
+
+// since returns the entries following the since sequence number, true when the entry right after since is no
+// longer in the ring
+func (er *eventRing) since(since uint64) ([]socketMessage, bool) {
+	size := len(er.entries)
+	result := make([]socketMessage, 0)
+	for i := 0; i < er.count; i++ {
+		entry := er.entries[(er.start+i)%size]
+		if i == 0 && entry.seq > since+1 {
+			return nil, true
+		}
+		if entry.seq > since {
+			result = append(result, entry)
+		}
+	}
+	return result, false
+}
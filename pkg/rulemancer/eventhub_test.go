@@ -0,0 +1,128 @@
+package rulemancer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEventRing(t *testing.T) {
+	ring := newEventRing(3)
+	seqs := func(messages []socketMessage) []uint64 {
+		result := make([]uint64, 0)
+		for _, msg := range messages {
+			result = append(result, msg.seq)
+		}
+		return result
+	}
+
+	if messages, resync := ring.since(0); resync || len(messages) != 0 {
+		t.Fatalf("empty ring: expected no messages, got %v (resync %v)", seqs(messages), resync)
+	}
+	for seq := uint64(1); seq <= 5; seq++ {
+		ring.push(seq, []byte("event"))
+	}
+
+	tests := []struct {
+		name     string
+		since    uint64
+		expected []uint64
+		resync   bool
+	}{
+		{name: "up to date", since: 5, expected: []uint64{}},
+		{name: "partial replay", since: 3, expected: []uint64{4, 5}},
+		{name: "oldest kept event", since: 2, expected: []uint64{3, 4, 5}},
+		{name: "events overwritten", since: 1, resync: true},
+		{name: "from the start", since: 0, resync: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, resync := ring.since(tt.since)
+			if resync != tt.resync {
+				t.Fatalf("expected resync %v, got %v", tt.resync, resync)
+			}
+			if !tt.resync && !reflect.DeepEqual(seqs(messages), tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, seqs(messages))
+			}
+		})
+	}
+}
+
+func TestEventHubSubscribe(t *testing.T) {
+	hub := newEventHub("room1", 8)
+	for i := 0; i < 10; i++ {
+		hub.emit(EventOutput, "", OutputLine{Channel: "t", Text: "line"})
+	}
+
+	if _, backlog, resync, seq := hub.subscribe("late", "", true, 7); resync || seq != 10 || len(backlog) != 3 {
+		t.Errorf("expected 3 events to replay at seq 10, got %d (resync %v, seq %d)", len(backlog), resync, seq)
+	}
+	if _, _, resync, _ := hub.subscribe("stale", "", true, 1); !resync {
+		t.Errorf("expected a resync for events no longer in the ring")
+	}
+	if _, _, resync, _ := hub.subscribe("future", "", true, 42); !resync {
+		t.Errorf("expected a resync for events never sent")
+	}
+	if _, backlog, resync, _ := hub.subscribe("fresh", "", false, 0); resync || len(backlog) != 0 {
+		t.Errorf("expected no replay without since")
+	}
+}
+
+func TestEventHubLagged(t *testing.T) {
+	hub := newEventHub("room1", 256)
+	sock, _, _, _ := hub.subscribe("slow", "", false, 0)
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		hub.emit(EventOutput, "", OutputLine{Channel: "t", Text: "line"})
+	}
+	select {
+	case <-sock.lagged:
+	default:
+		t.Fatalf("expected the socket to be signalled as lagged")
+	}
+	if len(sock.ch) != subscriberBuffer {
+		t.Errorf("expected %d queued events, got %d", subscriberBuffer, len(sock.ch))
+	}
+}
+
+func TestEventHubCatchUp(t *testing.T) {
+	hub := newEventHub("room1", 4)
+	for i := 0; i < 6; i++ {
+		hub.emit(EventOutput, "", OutputLine{Channel: "t", Text: "line"})
+	}
+	snapshot := func(ctx context.Context) (any, error) {
+		return map[string]bool{"ended": false}, nil
+	}
+
+	// The events already sent are skipped
+	backlog, resync, seq := hub.eventsSince(3)
+	if messages, err := hub.catchUp(context.Background(), snapshot, 4, backlog, resync, seq); err != nil || len(messages) != 2 {
+		t.Fatalf("expected 2 events, got %d (%v)", len(messages), err)
+	}
+
+	// Events no longer in the ring are replaced by resync_required and snapshot
+	backlog, resync, seq = hub.eventsSince(1)
+	messages, err := hub.catchUp(context.Background(), snapshot, 1, backlog, resync, seq)
+	if err != nil || len(messages) != 2 {
+		t.Fatalf("expected 2 messages on resync, got %d (%v)", len(messages), err)
+	}
+	for i, eventType := range []string{EventResyncRequired, EventSnapshot} {
+		var event RoomEvent
+		if err := json.Unmarshal(messages[i].message, &event); err != nil {
+			t.Fatalf("invalid event: %v", err)
+		}
+		if event.Type != eventType || event.Seq != 6 || messages[i].seq != 6 {
+			t.Errorf("expected %s at seq 6, got %s at seq %d", eventType, event.Type, event.Seq)
+		}
+	}
+
+	failing := func(ctx context.Context) (any, error) {
+		return nil, errors.New("busy")
+	}
+	if _, err := hub.catchUp(context.Background(), failing, 1, backlog, resync, seq); err == nil {
+		t.Errorf("expected the snapshot error")
+	}
+}
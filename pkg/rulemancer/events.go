@@ -0,0 +1,251 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"context"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// EventProtocolVersion is the version of the room events envelope, it changes when the envelope or the payload
+// of an existing event type changes in a way that is not backward compatible
+const EventProtocolVersion = 1
+
+// Room event types
+const (
+	EventPlayerJoined       = "player_joined"
+	EventPlayerLeft         = "player_left"
+	EventWatcherJoined      = "watcher_joined"
+	EventWatcherLeft        = "watcher_left"
+	EventActionAsserted     = "action_asserted"
+	EventResults            = "results"
+	EventOutput             = "output"
+	EventStateChanged       = "state_changed"
+	EventStateDiff          = "state_diff"
+	EventGameEnded          = "game_ended"
+	EventResyncRequired     = "resync_required"
+	EventSnapshot           = "snapshot"
+	EventPresence           = "presence"
+	EventRatingsUpdated     = "ratings_updated"
+	EventClockTimeout       = "clock_timeout"
+	EventPlayerDisconnected = "player_disconnected"
+	EventPlayerReconnected  = "player_reconnected"
+	EventSeatExpired        = "seat_expired"
+	EventGamePaused         = "game_paused"
+	EventGameResumed        = "game_resumed"
+)
+
+// RoomEvent is the envelope of every message sent on the room websockets. Seq grows by one for every event of
+// the room, Time is in milliseconds since the epoch.
+type RoomEvent struct {
+	Version int    `json:"v"`
+	Type    string `json:"type"`
+	Room    string `json:"room,omitempty"`
+	Seq     uint64 `json:"seq"`
+	Time    int64  `json:"time"`
+	Actor   string `json:"actor,omitempty"`
+	Payload any    `json:"payload,omitempty"`
+}
+
+// emitPlayerJoined sends the player_joined event, the caller holds clientsMutex
+func (r *Room) emitPlayerJoined(client *Client) {
+	r.emit(EventPlayerJoined, client.id, map[string]any{
+		"client":      client.id,
+		"name":        client.name,
+		"seat":        r.seats[client.id],
+		"team":        r.playerTeam(client.id),
+		"players":     len(r.clients),
+		"max_players": r.maxClients,
+	})
+	if len(r.clients) == r.maxClients {
+		r.announce(LobbyRoomFilled, client.id, map[string]string{"room": r.id, "game": r.game.id})
+		if r.clock != nil {
+			r.clock.start(time.Now())
+		}
+	}
+}
+
+// queryableRelations returns the sorted relations of all the game queries, but the private ones. They are the
+// relations of the state diffs and the base of the state relations, the private facts are left out of both.
+func (g *Game) queryableRelations() []string {
+	relations := make([]string, 0)
+	for _, rels := range g.queryable {
+		for _, rel := range rels {
+			if _, private := g.private[rel]; !private && !isInSlice(relations, rel) {
+				relations = append(relations, rel)
+			}
+		}
+	}
+	sort.Strings(relations)
+	return relations
+}
+
+// stateRelations returns the relations whose facts make the room state: the queryable relations and the
+// relations that end the game, with the turn relation of the game clock
+func (g *Game) stateRelations() []string {
+	relations := g.queryableRelations()
+	for _, rel := range g.endRelations {
+		if !isInSlice(relations, rel) {
+			relations = append(relations, rel)
+		}
+	}
+	if g.clock != nil && !isInSlice(relations, g.clock.TurnRelation) {
+		relations = append(relations, g.clock.TurnRelation)
+	}
+	sort.Strings(relations)
+	return relations
+}
+
+// queryStateAtomic returns the raw facts of the state relations, it must be called from a CLIPS job
+func (r *Room) queryStateAtomic() (map[string]string, error) {
+	state := make(map[string]string)
+	for _, rel := range r.game.stateRelations() {
+		if facts, err := r.clipsInstance.QueryFactsAtomic(rel); err != nil {
+			return nil, err
+		} else {
+			state[rel] = facts
+		}
+	}
+	return state, nil
+}
+
+// publishAssertion sends the events of an assertion: the asserted facts, what the rules printed and, when the
+// run succeeded, the results of the assertion, the queryable facts it changed, the new state and the end of the
+// game if it just happened
+func (e *Engine) publishAssertion(room *Room, actor, assertion string, facts []string, output []OutputLine,
+	results map[string][]map[string]string, diff map[string]factsDiff, state map[string]string) {
+
+	team := room.teamOf(actor)
+	if len(facts) > 0 {
+		payload := map[string]any{"assertion": assertion, "facts": facts}
+		if team != "" {
+			payload["team"] = team
+		}
+		room.emit(EventActionAsserted, actor, payload)
+	}
+	room.publishOutput(output)
+
+	if results != nil {
+		payload := map[string]any{"assertion": assertion, "relations": room.game.publicRelations(results)}
+		if team != "" {
+			payload["team"] = team
+		}
+		room.emit(EventResults, actor, payload)
+	}
+	e.publishDiff(room, actor, diff)
+	if state != nil {
+		e.publishState(room, actor, state)
+	}
+}
+
+// convertState converts the raw facts of the state relations, relations that cannot be converted are skipped
+func (e *Engine) convertState(room *Room, raw map[string]string) map[string][]map[string]string {
+	state := make(map[string][]map[string]string)
+	for rel, facts := range raw {
+		if factMap, err := genericFactToMap(e.Config, rel, facts); err != nil {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/convertState]")+" ", 0)
+				l.Printf("Error converting state relation %s in room %s: %v", rel, room.id, err)
+			}
+		} else {
+			if factMap == nil {
+				factMap = make([]map[string]string, 0)
+			}
+			state[rel] = factMap
+		}
+	}
+	return state
+}
+
+// publishState converts the raw state facts and sends the state_changed event, followed by game_ended the first
+// time a relation ending the game has facts. The room clock passes the turn to the seat named by the turn
+// relation, or stops when the game ends.
+func (e *Engine) publishState(room *Room, actor string, raw map[string]string) {
+	state := e.convertState(room, raw)
+
+	ending := make(map[string][]map[string]string)
+	for _, rel := range room.game.endRelations {
+		if len(state[rel]) > 0 {
+			ending[rel] = state[rel]
+		}
+	}
+
+	payload := map[string]any{"relations": state}
+	if room.clock != nil {
+		now := time.Now()
+		if len(ending) > 0 {
+			room.clock.stop(now)
+		} else {
+			room.clock.setActive(activeSeat(state[room.game.clock.TurnRelation], room.game.clock.TurnSlot, room.game.seats), now)
+		}
+		payload["clock"] = room.clock.info(now)
+	}
+	room.emit(EventStateChanged, actor, payload)
+
+	if len(ending) > 0 && room.markEnded() {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/publishState]")+" ", 0)
+			l.Printf("Game ended in room %s: %v", room.id, ending)
+		}
+		room.logAction(ActionLogEntry{Kind: "end", Actor: actor})
+		room.emit(EventGameEnded, actor, map[string]any{"relations": ending})
+		scores := room.gameScores(ending)
+		e.rateGame(room, actor, scores)
+		e.recordGame(room, scores)
+		e.endTournamentMatch(room, scores, "")
+	}
+}
+
+// roomSnapshot returns the full state of a room: the state relations, the indexed queryable facts, the players,
+// the watchers and whether the game has ended
+func (e *Engine) roomSnapshot(ctx context.Context, room *Room) (map[string]any, error) {
+	state := make(map[string][]map[string]string)
+	facts := make(map[string][]DiffFact)
+	if !e.ClipsLessMode {
+		var raw map[string]string
+		var index map[string]map[int64]string
+		if err := room.clipsInstance.Do(ctx, func() error {
+			var err error
+			if raw, err = room.queryStateAtomic(); err != nil {
+				return err
+			}
+			index, err = room.clipsInstance.indexFactsAtomic(room.game.queryableRelations())
+			return err
+		}); err != nil {
+			return nil, err
+		}
+		state = e.convertState(room, raw)
+		// The queryable facts with their index, the base the following state_diff events apply to
+		for rel, relDiff := range e.convertDiff(room, diffAll(index)) {
+			facts[rel] = relDiff.Added
+		}
+	}
+
+	room.clientsMutex.RLock()
+	players := make([]string, 0, len(room.clients))
+	for id := range room.clients {
+		players = append(players, id)
+	}
+	room.clientsMutex.RUnlock()
+	room.watchersMutex.RLock()
+	watchers := make([]string, 0, len(room.watchers))
+	for id := range room.watchers {
+		watchers = append(watchers, id)
+	}
+	room.watchersMutex.RUnlock()
+	sort.Strings(players)
+	sort.Strings(watchers)
+
+	return map[string]any{
+		"relations": state,
+		"facts":     facts,
+		"players":   players,
+		"watchers":  watchers,
+		"ended":     room.hasEnded(),
+		"clock":     room.clockInfo(),
+	}, nil
+}
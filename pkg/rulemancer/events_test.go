@@ -0,0 +1,118 @@
+package rulemancer
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// newEventsTestRoom returns a room with a single socket collecting the broadcast messages
+func newEventsTestRoom(game *Game) (*Room, socketChan) {
+	room := &Room{
+		id:       "room1",
+		game:     game,
+		clients:  make(map[string]*Client),
+		watchers: make(map[string]*Client),
+		events:   newEventHub("room1", 8),
+	}
+	sub, _, _, _ := room.events.subscribe("test", "", false, 0)
+	return room, sub.ch
+}
+
+// receivedEvents decodes the events queued on the socket channel
+func receivedEvents(t *testing.T, ch socketChan) []RoomEvent {
+	events := make([]RoomEvent, 0)
+	for {
+		select {
+		case msg := <-ch:
+			var event RoomEvent
+			if err := json.Unmarshal(msg.message, &event); err != nil {
+				t.Fatalf("invalid event %s: %v", msg.message, err)
+			}
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestRoomEmit(t *testing.T) {
+	room, ch := newEventsTestRoom(&Game{})
+
+	room.emit(EventPlayerJoined, "alice", map[string]string{"client": "alice"})
+	room.emit(EventOutput, "", OutputLine{Channel: "t", Text: "hello"})
+
+	events := receivedEvents(t, ch)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	for i, event := range events {
+		if event.Version != EventProtocolVersion {
+			t.Errorf("event %d: expected version %d, got %d", i, EventProtocolVersion, event.Version)
+		}
+		if event.Room != "room1" {
+			t.Errorf("event %d: expected room room1, got %s", i, event.Room)
+		}
+		if event.Seq != uint64(i+1) {
+			t.Errorf("event %d: expected seq %d, got %d", i, i+1, event.Seq)
+		}
+		if event.Time == 0 {
+			t.Errorf("event %d: missing timestamp", i)
+		}
+	}
+	if events[0].Type != EventPlayerJoined || events[0].Actor != "alice" {
+		t.Errorf("unexpected first event: %+v", events[0])
+	}
+	if events[1].Type != EventOutput || events[1].Actor != "" {
+		t.Errorf("unexpected second event: %+v", events[1])
+	}
+}
+
+func TestPublishState(t *testing.T) {
+	e := NewEngine("secret")
+	game := &Game{
+		queryable:    map[string][]string{"board": {"cell"}},
+		endRelations: []string{"winner"},
+	}
+	room, ch := newEventsTestRoom(game)
+
+	if relations := game.stateRelations(); !reflect.DeepEqual(relations, []string{"cell", "winner"}) {
+		t.Fatalf("unexpected state relations: %v", relations)
+	}
+
+	// The private relations are left out of the state and of the state diffs alike
+	private := &Game{queryable: map[string][]string{"board": {"cell", "hand"}}, private: map[string]string{"hand": "seat"}}
+	if relations := private.stateRelations(); !reflect.DeepEqual(relations, []string{"cell"}) {
+		t.Errorf("unexpected state relations with private facts: %v", relations)
+	}
+	if relations := private.queryableRelations(); !reflect.DeepEqual(relations, []string{"cell"}) {
+		t.Errorf("unexpected diff relations with private facts: %v", relations)
+	}
+
+	e.publishState(room, "alice", map[string]string{"cell": "(cell (x 1) (y 1) (value x))", "winner": ""})
+	e.publishState(room, "bob", map[string]string{"cell": "(cell (x 1) (y 1) (value x))", "winner": "(winner (player x))"})
+	e.publishState(room, "admin", map[string]string{"cell": "(cell (x 1) (y 1) (value x))", "winner": "(winner (player x))"})
+
+	types := make([]string, 0)
+	for _, event := range receivedEvents(t, ch) {
+		types = append(types, event.Type)
+	}
+	expected := []string{EventStateChanged, EventStateChanged, EventGameEnded, EventStateChanged}
+	if !reflect.DeepEqual(types, expected) {
+		t.Errorf("expected events %v, got %v", expected, types)
+	}
+	if !room.hasEnded() {
+		t.Errorf("room should be ended")
+	}
+}
+
+func TestParseGameEnd(t *testing.T) {
+	facts := []map[string]string{{"relations": "winner draw"}, {"relations": "winner surrender"}}
+	expected := []string{"winner", "draw", "surrender"}
+	if relations := parseGameEnd(facts); !reflect.DeepEqual(relations, expected) {
+		t.Errorf("expected %v, got %v", expected, relations)
+	}
+	if relations := parseGameEnd(nil); len(relations) != 0 {
+		t.Errorf("expected no relations, got %v", relations)
+	}
+}
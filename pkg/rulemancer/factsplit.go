@@ -44,3 +44,41 @@ func factsSplit(input string) []string {
 
 	return result
 }
+
+// factsList splits a list of facts in the single facts, each one enclosed in balanced parentheses.
+// Parentheses inside quoted strings are not counted.
+// Example: `(a (x 1)) (b "(")` returns ["(a (x 1))", "(b \"(\")"]
+func factsList(input string) []string {
+	result := []string{}
+	depth := 0
+	start := 0
+	inQuotes := false
+
+	for i := 0; i < len(input); i++ {
+		switch input[i] {
+		case '\\':
+			if inQuotes {
+				// Skip the escaped character
+				i++
+			}
+		case '"':
+			inQuotes = !inQuotes
+		case '(':
+			if !inQuotes {
+				if depth == 0 {
+					start = i
+				}
+				depth++
+			}
+		case ')':
+			if !inQuotes && depth > 0 {
+				depth--
+				if depth == 0 {
+					result = append(result, input[start:i+1])
+				}
+			}
+		}
+	}
+
+	return result
+}
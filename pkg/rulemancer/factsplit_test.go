@@ -97,3 +97,41 @@ func TestFactsSplit(t *testing.T) {
 		})
 	}
 }
+
+func TestFactsList(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "empty",
+			input:    "",
+			expected: []string{},
+		},
+		{
+			name:     "template facts",
+			input:    "(cell (x 1) (y 2) (value x))(turn (player o))",
+			expected: []string{"(cell (x 1) (y 2) (value x))", "(turn (player o))"},
+		},
+		{
+			name:     "ordered facts with spaces",
+			input:    " (initial-fact)   (numbers 1 2 3) ",
+			expected: []string{"(initial-fact)", "(numbers 1 2 3)"},
+		},
+		{
+			name:     "parentheses in strings",
+			input:    `(last-move (valid no) (reason "bad (move)")) (note "say \"(\"")`,
+			expected: []string{`(last-move (valid no) (reason "bad (move)"))`, `(note "say \"(\"")`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := factsList(tt.input)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("factsList(%q) = %q, expected %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"time"
 )
 
 type Game struct {
@@ -17,6 +18,17 @@ type Game struct {
 	assertable    map[string][]string
 	responses     map[string][]string
 	queryable     map[string][]string
+	templates     map[string]*TemplateSchema // deftemplates defined by the game rules
+	runLimits     RunLimits                  // limits of every run in the game rooms
+	endRelations  []string                   // relations whose facts end the game
+	seats         []string                   // names the rules give to the players, in seat order
+	params        []GameParam                // room creation parameters, sorted by name
+	clock         *ClockSettings             // turn clock, nil for untimed games
+	disconnect    *DisconnectSettings        // what happens to the seats of the disconnected players
+	teams         []GameTeam                 // teams and their seats, none when every player plays for itself
+	private       map[string]string          // owner slot of each relation whose facts only their owner sees
+	moves         *MoveSettings              // where the legal moves are, nil when the game does not tell them
+	botRules      []string                   // names of the bot rule files
 	runningRooms  map[string]*Room
 	partialRooms  map[string]*Room
 	roomsMutex    sync.RWMutex
@@ -31,16 +43,61 @@ func (g *Game) Info() map[string]any {
 		"assertable":    g.assertable,
 		"responses":     g.responses,
 		"queryable":     g.queryable,
+		"templates":     g.interfaceTemplates(),
+		"endRelations":  g.endRelations,
+		"seats":         g.seats,
+		"params":        g.params,
+		"clock":         g.clock,
+		"disconnect":    g.disconnect,
+		"teams":         g.teams,
+		"private":       g.private,
+		"moves":         g.moves,
+		"bots":          g.botStrategyNames(),
 		"runningRooms":  g.runningRooms,
 	}
 }
 
+// turnRelation returns the relation and the slot telling whose turn it is: those of the game clock, or the turn
+// relation with a player slot. Both are empty when the game has no turns.
+func (g *Game) turnRelation() (string, string) {
+	if g.clock != nil {
+		return g.clock.TurnRelation, g.clock.TurnSlot
+	}
+	if tmpl, ok := g.templates["turn"]; ok && tmpl.Slot("player") != nil {
+		return "turn", "player"
+	}
+	return "", ""
+}
+
+// interfaceTemplates returns the deftemplates of the relations exposed by the game interface
+func (g *Game) interfaceTemplates() map[string]*TemplateSchema {
+	result := make(map[string]*TemplateSchema)
+	for _, relMap := range []map[string][]string{g.assertable, g.responses, g.queryable} {
+		for _, relations := range relMap {
+			for _, rel := range relations {
+				if tmpl, ok := g.templates[rel]; ok {
+					result[rel] = tmpl
+				}
+			}
+		}
+	}
+	return result
+}
+
 func (e *Engine) loadGames() {
 	// Load games from the configured games list
 	for _, gameLocation := range e.Games {
 		if err := e.newGame(gameLocation); err != nil {
 			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/loadGames]")+" ", 0)
-			l.Printf("error loading game from %s: %v", gameLocation, err)
+			var loadErrors ClipsLoadErrors
+			if errors.As(err, &loadErrors) {
+				l.Printf("game from %s not registered, its rules do not load:", gameLocation)
+				for _, le := range loadErrors {
+					l.Printf("  %s", le.Error())
+				}
+			} else {
+				l.Printf("error loading game from %s: %v", gameLocation, err)
+			}
 		} else {
 			if e.Debug {
 				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/loadGames]")+" ", 0)
@@ -60,7 +117,7 @@ func (e *Engine) newGame(rulesLocation string) error {
 		return err
 	}
 	// Load knowledge base from the specified game rules location
-	if err := cli.loadGame(rulesLocation); err != nil {
+	if err := cli.loadGame(rulesLocation, nil); err != nil {
 		return err
 	}
 
@@ -124,10 +181,152 @@ func (e *Engine) newGame(rulesLocation string) error {
 		return err
 	}
 
+	// Get the run limits, the game can tighten or relax the engine defaults with a run-limits fact
+	rl, err := cli.QueryFacts("run-limits")
+	if err != nil {
+		return err
+	}
+	rlMap, err := genericFactToMap(e.Config, "run-limits", rl)
+	if err != nil {
+		return err
+	}
+	runLimits, err := parseRunLimits(e.defaultRunLimits(), rlMap)
+	if err != nil {
+		return err
+	}
+
+	// Get the relations ending the game, declared by the optional game-end facts
+	ge, err := cli.QueryFacts("game-end")
+	if err != nil {
+		return err
+	}
+	geMap, err := genericFactToMap(e.Config, "game-end", ge)
+	if err != nil {
+		return err
+	}
+	endRelations := parseGameEnd(geMap)
+
+	// Get the names of the players, declared by the optional game-seats fact
+	gs, err := cli.QueryFacts("game-seats")
+	if err != nil {
+		return err
+	}
+	gsMap, err := genericFactToMap(e.Config, "game-seats", gs)
+	if err != nil {
+		return err
+	}
+	seats, err := parseGameSeats(gsMap, numPlayers)
+	if err != nil {
+		return err
+	}
+
+	// Get the teams, declared by the optional game-team facts
+	gt, err := cli.QueryFacts("game-team")
+	if err != nil {
+		return err
+	}
+	gtMap, err := genericFactToMap(e.Config, "game-team", gt)
+	if err != nil {
+		return err
+	}
+	teams, err := parseGameTeams(gtMap, seats)
+	if err != nil {
+		return err
+	}
+
+	// Get the relations whose facts only their owner sees, declared by the optional game-private facts
+	gv, err := cli.QueryFacts("game-private")
+	if err != nil {
+		return err
+	}
+	gvMap, err := genericFactToMap(e.Config, "game-private", gv)
+	if err != nil {
+		return err
+	}
+	private, err := parseGamePrivate(gvMap)
+	if err != nil {
+		return err
+	}
+
+	// Get the room creation parameters, declared by the optional game-param facts
+	gp, err := cli.QueryFacts("game-param")
+	if err != nil {
+		return err
+	}
+	params, err := parseGameParams(e.Config, gp)
+	if err != nil {
+		return err
+	}
+
+	// Get the turn clock, declared by the optional game-clock fact
+	gk, err := cli.QueryFacts("game-clock")
+	if err != nil {
+		return err
+	}
+	gkMap, err := genericFactToMap(e.Config, "game-clock", gk)
+	if err != nil {
+		return err
+	}
+	clock, err := parseGameClock(gkMap)
+	if err != nil {
+		return err
+	}
+
+	// Get what happens to the disconnected players, declared by the optional game-disconnect fact
+	gd, err := cli.QueryFacts("game-disconnect")
+	if err != nil {
+		return err
+	}
+	gdMap, err := genericFactToMap(e.Config, "game-disconnect", gd)
+	if err != nil {
+		return err
+	}
+	disconnect, err := parseGameDisconnect(gdMap, e.DisconnectGraceMs)
+	if err != nil {
+		return err
+	}
+
+	// Get where the legal moves are, declared by the optional game-moves fact
+	gm, err := cli.QueryFacts("game-moves")
+	if err != nil {
+		return err
+	}
+	gmMap, err := genericFactToMap(e.Config, "game-moves", gm)
+	if err != nil {
+		return err
+	}
+	moves, err := parseGameMoves(gmMap)
+	if err != nil {
+		return err
+	}
+
+	// Get the deftemplates, they are used to validate the assert payloads and to build the game extras
+	templates, err := cli.Templates()
+	if err != nil {
+		return err
+	}
+	if _, ok := templates[roomParamRelation]; len(params) > 0 && !ok {
+		return errors.New("game-param facts found but no room-param deftemplate")
+	}
+	if clock != nil {
+		if err := checkClockTemplates(clock, templates); err != nil {
+			return err
+		}
+	}
+	if moves != nil {
+		if err := checkMoves(moves, assertableFacts, templates); err != nil {
+			return err
+		}
+	}
+	if err := checkDisconnectTemplates(disconnect, templates); err != nil {
+		return err
+	}
+	if err := checkPrivateTemplates(private, endRelations, templates); err != nil {
+		return err
+	}
+
 	// The game is successfully loaded, the CLIPS instance can be disposed by deferring
 
-	e.gamesMutex.Lock()
-	defer e.gamesMutex.Unlock()
 	game := &Game{
 		name:          name,
 		description:   description,
@@ -136,11 +335,29 @@ func (e *Engine) newGame(rulesLocation string) error {
 		assertable:    assertableFacts,
 		responses:     results,
 		queryable:     queryableFacts,
-		id:            e.generateGameUniqueID(),
+		templates:     templates,
+		runLimits:     runLimits,
+		endRelations:  endRelations,
+		seats:         seats,
+		params:        params,
+		clock:         clock,
+		disconnect:    disconnect,
+		teams:         teams,
+		private:       private,
+		moves:         moves,
+		botRules:      listBotRules(rulesLocation),
 		runningRooms:  make(map[string]*Room),
 		partialRooms:  make(map[string]*Room),
 		roomsMutex:    sync.RWMutex{},
 	}
+
+	if disconnect.Policy == DisconnectBot && !isInSlice(game.botStrategyNames(), disconnect.Bot) {
+		return errors.New("game-disconnect bot policy found but the game has no " + disconnect.Bot + " bot strategy")
+	}
+
+	e.gamesMutex.Lock()
+	defer e.gamesMutex.Unlock()
+	game.id = e.generateGameUniqueID()
 	e.numGames++
 	e.games[game.id] = game
 
@@ -152,6 +369,54 @@ func (e *Engine) newGame(rulesLocation string) error {
 	return nil
 }
 
+// defaultRunLimits returns the run limits configured for the engine
+func (e *Engine) defaultRunLimits() RunLimits {
+	return RunLimits{
+		MaxRulesFired: e.MaxRulesFired,
+		Timeout:       time.Duration(e.RunTimeoutMs) * time.Millisecond,
+	}
+}
+
+// parseRunLimits overrides the default run limits with the slots of the game run-limits fact, if any
+func parseRunLimits(defaults RunLimits, facts []map[string]string) (RunLimits, error) {
+	limits := defaults
+	switch len(facts) {
+	case 0:
+		return limits, nil
+	case 1:
+		if value, ok := facts[0]["max-rules-fired"]; ok {
+			maxRules, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || maxRules < 0 {
+				return limits, errors.New("run-limits max-rules-fired slot must be a non negative integer")
+			}
+			limits.MaxRulesFired = maxRules
+		}
+		if value, ok := facts[0]["timeout-ms"]; ok {
+			timeout, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || timeout < 0 {
+				return limits, errors.New("run-limits timeout-ms slot must be a non negative integer")
+			}
+			limits.Timeout = time.Duration(timeout) * time.Millisecond
+		}
+		return limits, nil
+	default:
+		return limits, errors.New("multiple run-limits facts found in the rules location")
+	}
+}
+
+// parseGameEnd returns the relations listed by the game-end facts
+func parseGameEnd(facts []map[string]string) []string {
+	relations := make([]string, 0)
+	for _, fact := range facts {
+		for _, rel := range factsSplit(fact["relations"]) {
+			if !isInSlice(relations, rel) {
+				relations = append(relations, rel)
+			}
+		}
+	}
+	return relations
+}
+
 func (e *Engine) generateGameUniqueID() string {
 	for {
 		newId := randStringBytes(16)
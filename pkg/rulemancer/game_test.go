@@ -0,0 +1,61 @@
+package rulemancer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRunLimits(t *testing.T) {
+	defaults := RunLimits{MaxRulesFired: 1000, Timeout: time.Second}
+
+	tests := []struct {
+		name     string
+		facts    []map[string]string
+		expected RunLimits
+		wantErr  bool
+	}{
+		{
+			name:     "no run-limits fact",
+			facts:    nil,
+			expected: defaults,
+		},
+		{
+			name:     "both limits overridden",
+			facts:    []map[string]string{{"max-rules-fired": "50", "timeout-ms": "200"}},
+			expected: RunLimits{MaxRulesFired: 50, Timeout: 200 * time.Millisecond},
+		},
+		{
+			name:     "only the rules limit",
+			facts:    []map[string]string{{"max-rules-fired": "0"}},
+			expected: RunLimits{MaxRulesFired: 0, Timeout: time.Second},
+		},
+		{
+			name:    "invalid timeout",
+			facts:   []map[string]string{{"timeout-ms": "soon"}},
+			wantErr: true,
+		},
+		{
+			name:    "multiple facts",
+			facts:   []map[string]string{{"timeout-ms": "1"}, {"timeout-ms": "2"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseRunLimits(defaults, tt.facts)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseRunLimits() expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseRunLimits() unexpected error: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("parseRunLimits() = %+v, want %+v", result, tt.expected)
+			}
+		})
+	}
+}
@@ -65,9 +65,6 @@ func (e *Engine) apiBridgeRequest(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		ci := brRoom.clipsInstance
-		ci.Lock()
-
 		// The request is composed of multiple facts asserted together, the "facts" field specifies a list of
 		// relations, each relation is a map of variable names to values, the values can be a single value or a
 		// list of values, for example:
@@ -125,104 +122,86 @@ func (e *Engine) apiBridgeRequest(w http.ResponseWriter, r *http.Request) {
 
 				}
 			}
+		}
 
-			for _, fact := range facts {
-				if e.Debug {
-					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/apiBridgeRequest]")+" ", 0)
-					l.Printf("Asserting fact in room %s: %s", id, fact)
-				}
-				if err := ci.AssertFactAtomic(fact); err != nil {
-					if e.Debug {
-						l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiBridgeRequest]")+" ", 0)
-						l.Printf("Error asserting fact in room %s - %s: %v", id, fact, err)
-					}
-					ci.Unlock()
-					Error(w, http.StatusInternalServerError, "failed to assert")
-					return
-				} else {
+		// The response is a list of relations to query after the run
+		queryList := make([]string, 0)
+		if queries, ok := raw["queries"]; !ok {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/apiBridgeRequest]")+" ", 0)
+				l.Printf("No queries field in request body for assertion in room %s", id)
+			}
+		} else if err := json.Unmarshal(queries, &queryList); err != nil {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiBridgeRequest]")+" ", 0)
+				l.Printf("Error decoding queries list for assertion in room %s: %v", id, err)
+			}
+			Error(w, http.StatusBadRequest, "invalid queries format")
+			return
+		}
+
+		// Everything touching CLIPS runs as a single job on the bridge room instance
+		ci := brRoom.clipsInstance
+		allFacts := make([]string, len(queryList))
+		failure := ""
+
+		if err := ci.Do(r.Context(), func() error {
+			if len(facts) > 0 {
+				for _, fact := range facts {
 					if e.Debug {
 						l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/apiBridgeRequest]")+" ", 0)
-						l.Printf("Successfully asserted fact in room %s: %s", id, fact)
+						l.Printf("Asserting fact in room %s: %s", id, fact)
+					}
+					if err := ci.AssertFactAtomic(fact); err != nil {
+						failure = "failed to assert"
+						return err
 					}
 				}
-			}
 
-			if err := ci.RunAtomic(); err != nil {
-				if e.Debug {
-					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiBridgeRequest]")+" ", 0)
-					l.Printf("Error running CLIPS in room %s: %v", id, err)
+				err := ci.RunAtomic()
+				// Bridge rooms have no event stream, the rules output is only drained to keep the buffer bounded
+				ci.TakeOutputAtomic()
+				if err != nil {
+					failure = "failed to run"
+					return err
 				}
-				ci.Unlock()
-				Error(w, http.StatusInternalServerError, "failed to run")
-				return
-			} else {
-				if e.Debug {
-					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/apiBridgeRequest]")+" ", 0)
-					l.Printf("Successfully ran CLIPS in room %s", id)
+			}
+
+			for i, rel := range queryList {
+				if factList, err := ci.QueryFactsAtomic(rel); err != nil {
+					failure = "failed to query status"
+					return err
+				} else {
+					allFacts[i] = factList
 				}
 			}
+			return nil
+		}); err != nil {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiBridgeRequest]")+" ", 0)
+				l.Printf("Error serving request in room %s: %v", id, err)
+			}
+			ClipsError(w, err, failure)
+			return
 		}
 
 		// Prepare the response
 		response := make(map[string][]map[string]string)
 
-		if queries, ok := raw["queries"]; !ok {
-			if e.Debug {
-				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/apiBridgeRequest]")+" ", 0)
-				l.Printf("No queries field in request body for assertion in room %s", id)
-			}
-		} else {
+		for i, factList := range allFacts {
 
-			var queryList []string
-			if err := json.Unmarshal(queries, &queryList); err != nil {
+			if factMap, err := genericFactToMap(e.Config, queryList[i], factList); err != nil {
 				if e.Debug {
 					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiBridgeRequest]")+" ", 0)
-					l.Printf("Error decoding queries list for assertion in room %s: %v", id, err)
+					l.Printf("Error converting fact to struct in room %s - %s: %v", id, queryList[i], err)
 				}
-				Error(w, http.StatusBadRequest, "invalid queries format")
+				Error(w, http.StatusInternalServerError, "failed to convert fact to struct")
 				return
 			} else {
-				// The response is a list of relations to query after the run
-				allFacts := make([]string, len(queryList))
-
-				for i, rel := range queryList {
-
-					if factList, err := ci.QueryFactsAtomic(rel); err != nil {
-						if e.Debug {
-							l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiBridgeRequest]")+" ", 0)
-							l.Printf("Error querying status in room %s - %s: %v", id, rel, err)
-						}
-						ci.Unlock()
-						Error(w, http.StatusInternalServerError, "failed to query status")
-						return
-					} else {
-						if e.Debug {
-							l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/apiBridgeRequest]")+" ", 0)
-							l.Printf("Status in room %s - %s: %+v", id, rel, factList)
-						}
-						allFacts[i] = factList
-					}
-				}
-
-				for i, factList := range allFacts {
-
-					if factMap, err := genericFactToMap(e.Config, queryList[i], factList); err != nil {
-						if e.Debug {
-							l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiBridgeRequest]")+" ", 0)
-							l.Printf("Error converting fact to struct in room %s - %s: %v", id, queryList[i], err)
-						}
-						ci.Unlock()
-						Error(w, http.StatusInternalServerError, "failed to convert fact to struct")
-						return
-					} else {
-						response[queryList[i]] = factMap
-					}
-				}
+				response[queryList[i]] = factMap
 			}
 		}
 
-		ci.Unlock()
-
 		JSON(w, http.StatusOK, map[string]any{
 			"asserted": facts,
 			"response": response,
@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	jwtauth "github.com/go-chi/jwtauth/v5"
@@ -20,6 +21,7 @@ func (e *Engine) clientRoutes(r chi.Router) {
 		r.Use(jwtauth.Authenticator(e.JWTAuth))
 		r.Get("/list", e.apiListClients)
 		r.Get("/{id}", e.apiGetClient)
+		r.Get("/{id}/stats", e.apiClientStats)
 		r.Get("/current", e.apiGetCurrentClient)
 		r.Delete("/{id}", e.apiDeleteClient)
 	})
@@ -63,6 +65,7 @@ func (e *Engine) apiGetClient(w http.ResponseWriter, r *http.Request) {
 			"id":          client.id,
 			"name":        client.name,
 			"description": client.description,
+			"bot":         client.bot,
 		})
 	}
 }
@@ -178,3 +181,58 @@ func (e *Engine) apiListClients(w http.ResponseWriter, r *http.Request) {
 		"clients": clientsList,
 	})
 }
+
+// apiClientStats returns the outcomes of the games a client played, by game, to any authenticated client. The
+// window query parameter restricts them to the last day, week, month or year.
+func (e *Engine) apiClientStats(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, ok := requesterID(w, r); !ok {
+		return
+	}
+	window, since, fields := parseStatsWindow(r.URL.Query(), time.Now())
+	if len(fields) > 0 {
+		ValidationError(w, fields)
+		return
+	}
+
+	// Clients are forgotten on restart, their recorded games are not
+	games, name := clientStats(e.stats.since(since), id)
+	if client, err := e.searchClient(id); err == nil {
+		name = client.name
+	} else if _, known := clientStats(e.stats.since(0), id); known == "" {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiClientStats]")+" ", 0)
+			l.Printf("Client not found: %s", id)
+		}
+		Error(w, http.StatusNotFound, "client not found")
+		return
+	} else if name == "" {
+		name = known
+	}
+
+	total := Outcomes{}
+	for i := range games {
+		if game, err := e.searchGame(games[i].Game); err == nil {
+			games[i].Rating = e.ratings.get(game.id, id, e.InitialRating).Rating
+		}
+		total.Played += games[i].Played
+		total.Wins += games[i].Wins
+		total.Draws += games[i].Draws
+		total.Losses += games[i].Losses
+		total.Points += games[i].Points
+		if games[i].LastPlayed > total.LastPlayed {
+			total.LastPlayed = games[i].LastPlayed
+		}
+	}
+	if total.Played > 0 {
+		total.WinRate = float64(total.Wins) / float64(total.Played)
+	}
+	JSON(w, http.StatusOK, map[string]any{
+		"client": id,
+		"name":   name,
+		"window": window,
+		"since":  since,
+		"total":  total,
+		"games":  games,
+	})
+}
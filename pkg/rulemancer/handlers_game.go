@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	jwtauth "github.com/go-chi/jwtauth/v5"
@@ -18,6 +19,7 @@ func (e *Engine) gameRoutes(r chi.Router) {
 	r.Route("/", func(r chi.Router) {
 		r.Get("/list", e.apiListGames)
 		r.Get("/{id}", e.apiGetGame)
+		r.Get("/{id}/leaderboard", e.apiGameLeaderboard)
 	})
 }
 
@@ -61,6 +63,7 @@ func (e *Engine) apiGetGame(w http.ResponseWriter, r *http.Request) {
 			"assertable":   game.assertable,
 			"responses":    game.responses,
 			"queryable":    game.queryable,
+			"templates":    game.interfaceTemplates(),
 			"playingRooms": game.runningRooms,
 			"waitingRooms": game.partialRooms,
 		})
@@ -97,3 +100,42 @@ func (e *Engine) apiListGames(w http.ResponseWriter, r *http.Request) {
 		"games": gamesList,
 	})
 }
+
+// apiGameLeaderboard ranks the clients that played a game, to any authenticated client. The window query
+// parameter restricts the ranking to the games of the last day, week, month or year.
+func (e *Engine) apiGameLeaderboard(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if _, ok := requesterID(w, r); !ok {
+		return
+	}
+	q, fields := parseLeaderboardQuery(r.URL.Query(), time.Now())
+	if len(fields) > 0 {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiGameLeaderboard]")+" ", 0)
+			l.Printf("Invalid leaderboard query: %v", fields)
+		}
+		ValidationError(w, fields)
+		return
+	}
+
+	if game, err := e.searchGame(id); err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiGameLeaderboard]")+" ", 0)
+			l.Printf("Game not found: %v", err)
+		}
+		Error(w, http.StatusNotFound, "game not found")
+	} else {
+		entries, total := q.leaderboard(e.stats.since(q.since), game.name, func(clientID string) float64 {
+			return e.ratings.get(game.id, clientID, e.InitialRating).Rating
+		})
+		JSON(w, http.StatusOK, map[string]any{
+			"game":        game.id,
+			"name":        game.name,
+			"window":      q.window,
+			"since":       q.since,
+			"sort":        q.sort,
+			"total":       total,
+			"leaderboard": entries,
+		})
+	}
+}
@@ -4,6 +4,7 @@ Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
 package rulemancer
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -18,6 +19,7 @@ func (e *Engine) joinRoutes(r chi.Router) {
 	r.Route("/", func(r chi.Router) {
 		r.Post("/available/{gameRef}", e.availableRoom) // Join the first available room for the specified game
 		r.Post("/room/{roomID}", e.joinRoom)            // Join a specific room by ID
+		r.Post("/invite/{code}", e.joinRoom)            // Join the room of an invite code
 		r.Post("/new/{gameRef}", e.newGameRoom)         // Create a new room for the specified game and join it
 	})
 }
@@ -68,8 +70,19 @@ func (e *Engine) availableRoom(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	var room *Room
+	var roomId string
+
 	game.roomsMutex.Lock()
-	if len(game.partialRooms) == 0 {
+	for id, r := range game.partialRooms {
+		// Strangers are only dropped in public rooms without a password
+		if r.openToStrangers() {
+			room = r
+			roomId = id
+			break
+		}
+	}
+	if room == nil {
 		game.roomsMutex.Unlock()
 		if e.Debug {
 			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/availableRoom]")+" ", 0)
@@ -81,15 +94,6 @@ func (e *Engine) availableRoom(w http.ResponseWriter, r *http.Request) {
 
 	defer game.roomsMutex.Unlock()
 
-	var room *Room
-	var roomId string
-
-	for id, r := range game.partialRooms {
-		room = r
-		roomId = id
-		break
-	}
-
 	// Start locking the room
 	room.clientsMutex.Lock()
 	defer room.clientsMutex.Unlock()
@@ -145,19 +149,20 @@ func (e *Engine) availableRoom(w http.ResponseWriter, r *http.Request) {
 	client.watchersMutex.Unlock()
 
 	// Apply the join to both the room and the client
-	room.clients[clientID] = client
+	room.addPlayer(client)
 	client.playingRooms[roomId] = room
 	if e.Debug {
 		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/availableRoom]")+" ", 0)
 		l.Printf("Client %s joined room: %s", clientID, roomId)
 	}
-	JSON(w, http.StatusOK, map[string]string{"status": "room found and joined"})
+	JSON(w, http.StatusOK, map[string]string{"status": "room found and joined", "room_id": roomId})
 
 }
 
 func (e *Engine) joinRoom(w http.ResponseWriter, r *http.Request) {
 	roomId := chi.URLParam(r, "roomID")
 	_, claims, err := jwtauth.FromContext(r.Context())
+	creds, credsErr := readRoomCredentials(r)
 
 	if err != nil {
 		if e.Debug {
@@ -173,8 +178,15 @@ func (e *Engine) joinRoom(w http.ResponseWriter, r *http.Request) {
 		}
 		Error(w, http.StatusUnauthorized, "unauthorized")
 		return
+	} else if credsErr != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/joinRoom]")+" ", 0)
+			l.Printf("Invalid JSON: %v", credsErr)
+		}
+		Error(w, http.StatusBadRequest, "invalid json")
+		return
 	} else {
-		if room, err := e.searchRoom(roomId); err != nil {
+		if room, err := e.requestedRoom(r, "roomID", &creds); err != nil {
 			// Room existence
 			if e.Debug {
 				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/joinRoom]")+" ", 0)
@@ -182,6 +194,13 @@ func (e *Engine) joinRoom(w http.ResponseWriter, r *http.Request) {
 			}
 			Error(w, http.StatusNotFound, "room not found")
 			return
+		} else if ce := room.admit(clientID, creds, false); ce != nil {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/joinRoom]")+" ", 0)
+				l.Printf("Client %s not admitted in room %s: %s", clientID, room.id, ce.Message)
+			}
+			CommandFailure(w, ce)
+			return
 		} else if client, err := e.searchClient(clientID); err != nil {
 			// Client existence
 			if e.Debug {
@@ -192,6 +211,11 @@ func (e *Engine) joinRoom(w http.ResponseWriter, r *http.Request) {
 			return
 		} else {
 			game := room.game
+			roomId = room.id
+
+			// Lock the game rooms first, then the room and the client, in the order of seatClient
+			game.roomsMutex.Lock()
+			defer game.roomsMutex.Unlock()
 
 			// Start locking the room
 			room.clientsMutex.Lock()
@@ -228,10 +252,6 @@ func (e *Engine) joinRoom(w http.ResponseWriter, r *http.Request) {
 			}
 
 			// We expect to have the room inserted into the game's partial rooms.
-			// Lock the game rooms
-			game.roomsMutex.Lock()
-			defer game.roomsMutex.Unlock()
-
 			if _, ok := game.partialRooms[roomId]; !ok {
 				if e.Debug {
 					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/joinRoom]")+" ", 0)
@@ -262,13 +282,13 @@ func (e *Engine) joinRoom(w http.ResponseWriter, r *http.Request) {
 			client.watchersMutex.Unlock()
 
 			// Apply the join to both the room and the client
-			room.clients[clientID] = client
+			room.addPlayer(client)
 			client.playingRooms[roomId] = room
 			if e.Debug {
 				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/joinRoom]")+" ", 0)
 				l.Printf("Client %s joined room: %s", clientID, roomId)
 			}
-			JSON(w, http.StatusOK, map[string]string{"status": "joined"})
+			JSON(w, http.StatusOK, map[string]string{"status": "joined", "room_id": roomId})
 			return
 		}
 	}
@@ -277,6 +297,7 @@ func (e *Engine) joinRoom(w http.ResponseWriter, r *http.Request) {
 func (e *Engine) newGameRoom(w http.ResponseWriter, r *http.Request) {
 	gameRef := chi.URLParam(r, "gameRef")
 	_, claims, err := jwtauth.FromContext(r.Context())
+	settings, settingsErr := readRoomSettings(r)
 
 	if err != nil {
 		if e.Debug {
@@ -292,6 +313,13 @@ func (e *Engine) newGameRoom(w http.ResponseWriter, r *http.Request) {
 		}
 		Error(w, http.StatusUnauthorized, "unauthorized")
 		return
+	} else if settingsErr != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/newGameRoom]")+" ", 0)
+			l.Printf("Invalid JSON: %v", settingsErr)
+		}
+		Error(w, http.StatusBadRequest, "invalid json")
+		return
 	} else {
 		if client, err := e.searchClient(clientID); err != nil {
 			// Client existence
@@ -313,7 +341,15 @@ func (e *Engine) newGameRoom(w http.ResponseWriter, r *http.Request) {
 
 			var room *Room
 
-			if newRoom, err := e.newRoom(clientID+"room", clientID+"room", gameRef); err != nil {
+			var fields FieldErrors
+			if newRoom, err := e.newRoom(clientID+"room", clientID+"room", gameRef, clientID, settings); errors.As(err, &fields) {
+				if e.Debug {
+					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/newGameRoom]")+" ", 0)
+					l.Printf("Invalid room settings: %v", fields)
+				}
+				ValidationError(w, fields)
+				return
+			} else if err != nil {
 				if e.Debug {
 					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/newGameRoom]")+" ", 0)
 					l.Printf("Failed to create new room: %v", err)
@@ -327,6 +363,10 @@ func (e *Engine) newGameRoom(w http.ResponseWriter, r *http.Request) {
 			roomId := room.id
 			game := room.game
 
+			// Lock the game rooms first, then the room and the client, in the order of seatClient
+			game.roomsMutex.Lock()
+			defer game.roomsMutex.Unlock()
+
 			// Start locking the room
 			room.clientsMutex.Lock()
 			defer room.clientsMutex.Unlock()
@@ -362,10 +402,6 @@ func (e *Engine) newGameRoom(w http.ResponseWriter, r *http.Request) {
 			}
 
 			// We expect to have the room inserted into the game's partial rooms.
-			// Lock the game rooms
-			game.roomsMutex.Lock()
-			defer game.roomsMutex.Unlock()
-
 			if _, ok := game.partialRooms[roomId]; !ok {
 				if e.Debug {
 					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/joinRoom]")+" ", 0)
@@ -396,13 +432,13 @@ func (e *Engine) newGameRoom(w http.ResponseWriter, r *http.Request) {
 			client.watchersMutex.Unlock()
 
 			// Apply the join to both the room and the client
-			room.clients[clientID] = client
+			room.addPlayer(client)
 			client.playingRooms[roomId] = room
 			if e.Debug {
 				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/joinRoom]")+" ", 0)
 				l.Printf("Client %s joined room: %s", clientID, roomId)
 			}
-			JSON(w, http.StatusOK, map[string]string{"status": "room created and joined"})
+			JSON(w, http.StatusOK, map[string]string{"status": "room created and joined", "room_id": roomId, "invite": room.invite})
 			return
 		}
 	}
@@ -0,0 +1,195 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	jwtauth "github.com/go-chi/jwtauth/v5"
+)
+
+func (e *Engine) matchRoutes(r chi.Router) {
+	r.Use(jwtauth.Verifier(e.JWTAuth))
+	r.Use(e.ticketVerifier)
+	r.Use(jwtauth.Authenticator(e.JWTAuth))
+	r.Route("/", func(r chi.Router) {
+		r.Post("/{gameRef}", e.apiEnqueueMatch) // Wait to be matched with other clients
+		r.Get("/{gameRef}", e.apiMatchStatus)   // Where the client stands in the queue, or where it was seated
+		r.Delete("/{gameRef}", e.apiLeaveMatch) // Stop waiting
+		r.HandleFunc("/{gameRef}/ws", e.matchMonitor)
+		r.Post("/{gameRef}/ticket", e.apiMatchTicket)
+	})
+}
+
+// matchRequester returns the client and the game of a matchmaking request, or writes the error
+func (e *Engine) matchRequester(w http.ResponseWriter, r *http.Request) (*Client, *Game, bool) {
+	gameRef := chi.URLParam(r, "gameRef")
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		Error(w, http.StatusUnauthorized, "unauthorized")
+		return nil, nil, false
+	} else if clientID, ok := claims["id"].(string); !ok {
+		Error(w, http.StatusUnauthorized, "unauthorized")
+		return nil, nil, false
+	} else if client, err := e.searchClient(clientID); err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/matchRequester]")+" ", 0)
+			l.Printf("Client not found: %s", clientID)
+		}
+		Error(w, http.StatusNotFound, "client not found")
+		return nil, nil, false
+	} else if game, err := e.searchGame(gameRef); err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/matchRequester]")+" ", 0)
+			l.Printf("Game not found: %s", gameRef)
+		}
+		Error(w, http.StatusNotFound, "game not found")
+		return nil, nil, false
+	} else {
+		return client, game, true
+	}
+}
+
+// matchStatus describes a ticket. A done ticket is forgotten once described, the outcome is told only once.
+func (e *Engine) matchStatus(t *matchTicket) map[string]any {
+	if t.finished() {
+		e.matchmaker.remove(t)
+		if t.result != nil {
+			return map[string]any{"status": "matched", "match": t.result}
+		}
+		return map[string]any{"status": "failed", "error": t.err}
+	}
+	return map[string]any{
+		"status":     "queued",
+		"game":       t.game.id,
+		"rating":     t.rating,
+		"position":   e.matchmaker.position(t),
+		"waiting_ms": time.Since(t.since).Milliseconds(),
+	}
+}
+
+func (e *Engine) apiEnqueueMatch(w http.ResponseWriter, r *http.Request) {
+	if client, game, ok := e.matchRequester(w, r); ok {
+		rating := e.ratings.get(game.id, client.id, e.InitialRating)
+		t, queued := e.matchmaker.enqueue(game, client, rating.Rating)
+		if e.Debug && queued {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/apiEnqueueMatch]")+" ", 0)
+			l.Printf("Client %s queued for game %s with rating %.0f", client.id, game.id, t.rating)
+		}
+		JSON(w, http.StatusAccepted, e.matchStatus(t))
+	}
+}
+
+func (e *Engine) apiMatchStatus(w http.ResponseWriter, r *http.Request) {
+	if client, game, ok := e.matchRequester(w, r); ok {
+		if t, ok := e.matchmaker.ticket(game.id, client.id); !ok {
+			Error(w, http.StatusNotFound, "not queued")
+		} else {
+			JSON(w, http.StatusOK, e.matchStatus(t))
+		}
+	}
+}
+
+func (e *Engine) apiLeaveMatch(w http.ResponseWriter, r *http.Request) {
+	if client, game, ok := e.matchRequester(w, r); ok {
+		if t, ok := e.matchmaker.ticket(game.id, client.id); !ok || t.finished() {
+			Error(w, http.StatusNotFound, "not queued")
+		} else if !e.matchmaker.remove(t) {
+			Error(w, http.StatusConflict, "already matched")
+		} else {
+			JSON(w, http.StatusOK, map[string]string{"status": "left"})
+		}
+	}
+}
+
+// apiMatchTicket issues a ticket for the matchmaking websocket of a game
+func (e *Engine) apiMatchTicket(w http.ResponseWriter, r *http.Request) {
+	if client, _, ok := e.matchRequester(w, r); ok {
+		e.issueTicket(w, client.id, []string{path.Dir(r.URL.Path) + "/ws"})
+	}
+}
+
+// matchMonitor queues the client for as long as the websocket is open and tells it where it was seated. The
+// socket is closed once the outcome is sent, closing it earlier leaves the queue.
+func (e *Engine) matchMonitor(w http.ResponseWriter, r *http.Request) {
+	client, game, ok := e.matchRequester(w, r)
+	if !ok {
+		return
+	}
+
+	conn, err := e.upgrader().Upgrade(w, r, nil)
+	if err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/matchMonitor]")+" ", 0)
+			l.Println("upgrade error:", err)
+		}
+		return
+	}
+	defer conn.Close()
+
+	rating := e.ratings.get(game.id, client.id, e.InitialRating)
+	t, _ := e.matchmaker.enqueue(game, client, rating.Rating)
+
+	e.keepAlive(conn)
+	pings, stopPings := e.pingTicker()
+	defer stopPings()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// reader async, it only sees the pongs and the connection going away
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+			e.extendReadDeadline(conn)
+		}
+	}()
+
+	send := func(eventType string, payload any) bool {
+		if message, err := encodeEvent("", 0, eventType, client.id, payload); err != nil {
+			return false
+		} else {
+			return e.writeMessage(conn, message) == nil
+		}
+	}
+
+	if !send(MatchQueued, e.matchStatus(t)) {
+		e.matchmaker.remove(t)
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// The client went away, it is not waiting anymore
+			if e.matchmaker.remove(t) && e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/matchMonitor]")+" ", 0)
+				l.Printf("Client %s left the queue of game %s", client.id, game.id)
+			}
+			return
+		case <-pings:
+			if err := e.ping(conn); err != nil {
+				e.matchmaker.remove(t)
+				return
+			}
+		case <-t.done:
+			e.matchmaker.remove(t)
+			if t.result != nil {
+				send(MatchFound, t.result)
+			} else {
+				send(MatchFailed, map[string]string{"error": t.err})
+			}
+			return
+		}
+	}
+}
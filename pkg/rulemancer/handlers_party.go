@@ -0,0 +1,232 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi/v5"
+	jwtauth "github.com/go-chi/jwtauth/v5"
+)
+
+func (e *Engine) partyRoutes(r chi.Router) {
+	r.Use(jwtauth.Verifier(e.JWTAuth))
+	r.Use(jwtauth.Authenticator(e.JWTAuth))
+	r.Route("/", func(r chi.Router) {
+		r.Post("/", e.apiNewParty)
+		r.Get("/{id}", e.apiGetParty)
+		r.Post("/{id}/join", e.apiJoinParty)
+		r.Delete("/{id}/join", e.apiLeaveParty)
+		r.Post("/{id}/available/{gameRef}", e.apiPartyAvailableRoom) // Join the first room with seats for the party
+		r.Post("/{id}/room/{roomID}", e.apiPartyJoinRoom)            // Join a specific room by ID
+		r.Post("/{id}/invite/{code}", e.apiPartyJoinRoom)            // Join the room of an invite code
+		r.Post("/{id}/new/{gameRef}", e.apiPartyNewRoom)             // Create a new room for the specified game and join it
+	})
+}
+
+// partyRequester returns the client id and the party of a request, or writes the error
+func (e *Engine) partyRequester(w http.ResponseWriter, r *http.Request) (string, *Party, bool) {
+	id := chi.URLParam(r, "id")
+	if clientID, ok := requesterID(w, r); !ok {
+		return "", nil, false
+	} else if p, err := e.searchParty(id); err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/partyRequester]")+" ", 0)
+			l.Printf("Party not found: %s", id)
+		}
+		Error(w, http.StatusNotFound, "party not found")
+		return "", nil, false
+	} else {
+		return clientID, p, true
+	}
+}
+
+// partyLeader returns the party of a request made by its leader, or writes the error
+func (e *Engine) partyLeader(w http.ResponseWriter, r *http.Request) (*Party, bool) {
+	if clientID, p, ok := e.partyRequester(w, r); !ok {
+		return nil, false
+	} else if clientID != p.leader {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/partyLeader]")+" ", 0)
+			l.Printf("Forbidden party request by %s, the leader of %s is %s", clientID, p.id, p.leader)
+		}
+		Error(w, http.StatusForbidden, "only the party leader can join rooms")
+		return nil, false
+	} else {
+		return p, true
+	}
+}
+
+func (e *Engine) apiNewParty(w http.ResponseWriter, r *http.Request) {
+	if clientID, ok := requesterID(w, r); !ok {
+		return
+	} else if _, err := e.searchClient(clientID); err != nil {
+		Error(w, http.StatusNotFound, "client not found")
+	} else if p, ce := e.newParty(clientID); ce != nil {
+		CommandFailure(w, ce)
+	} else {
+		JSON(w, http.StatusCreated, p.Info())
+	}
+}
+
+func (e *Engine) apiGetParty(w http.ResponseWriter, r *http.Request) {
+	if _, p, ok := e.partyRequester(w, r); ok {
+		JSON(w, http.StatusOK, p.Info())
+	}
+}
+
+func (e *Engine) apiJoinParty(w http.ResponseWriter, r *http.Request) {
+	if clientID, p, ok := e.partyRequester(w, r); ok {
+		if _, err := e.searchClient(clientID); err != nil {
+			Error(w, http.StatusNotFound, "client not found")
+		} else if ce := e.joinParty(p, clientID); ce != nil {
+			CommandFailure(w, ce)
+		} else {
+			JSON(w, http.StatusOK, map[string]string{"status": "joined", "party": p.id})
+		}
+	}
+}
+
+func (e *Engine) apiLeaveParty(w http.ResponseWriter, r *http.Request) {
+	if clientID, p, ok := e.partyRequester(w, r); ok {
+		if ce := e.leaveParty(p, clientID); ce != nil {
+			CommandFailure(w, ce)
+		} else if clientID == p.leader {
+			JSON(w, http.StatusOK, map[string]string{"status": "disbanded", "party": p.id})
+		} else {
+			JSON(w, http.StatusOK, map[string]string{"status": "left", "party": p.id})
+		}
+	}
+}
+
+// apiPartyJoinRoom seats the party in a room, the credentials of the leader let every member in
+func (e *Engine) apiPartyJoinRoom(w http.ResponseWriter, r *http.Request) {
+	p, ok := e.partyLeader(w, r)
+	if !ok {
+		return
+	}
+	creds, err := readRoomCredentials(r)
+	if err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiPartyJoinRoom]")+" ", 0)
+			l.Printf("Invalid JSON: %v", err)
+		}
+		Error(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	if room, err := e.requestedRoom(r, "roomID", &creds); err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiPartyJoinRoom]")+" ", 0)
+			l.Printf("Room not found: %s", chi.URLParam(r, "roomID"))
+		}
+		Error(w, http.StatusNotFound, "room not found")
+	} else if ce := room.admit(p.leader, creds, false); ce != nil {
+		CommandFailure(w, ce)
+	} else if ce := e.seatParty(room, p); ce != nil {
+		CommandFailure(w, ce)
+	} else {
+		JSON(w, http.StatusOK, e.partyJoined(room, p, "joined"))
+	}
+}
+
+// apiPartyAvailableRoom seats the party in the first room open to strangers with enough free seats, a new room
+// is created when there is none
+func (e *Engine) apiPartyAvailableRoom(w http.ResponseWriter, r *http.Request) {
+	p, ok := e.partyLeader(w, r)
+	if !ok {
+		return
+	}
+	gameRef := chi.URLParam(r, "gameRef")
+	game, err := e.searchGame(gameRef)
+	if err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiPartyAvailableRoom]")+" ", 0)
+			l.Printf("Game not found: %s", gameRef)
+		}
+		Error(w, http.StatusNotFound, "game not found")
+		return
+	}
+
+	p.mutex.Lock()
+	size := len(p.members)
+	p.mutex.Unlock()
+	if room := e.availablePartyRoom(game, size); room == nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/apiPartyAvailableRoom]")+" ", 0)
+			l.Printf("No available rooms for party %s in game: %s, creating a new one", p.id, gameRef)
+		}
+		e.apiPartyNewRoom(w, r)
+	} else if ce := e.seatParty(room, p); ce != nil {
+		CommandFailure(w, ce)
+	} else {
+		JSON(w, http.StatusOK, e.partyJoined(room, p, "room found and joined"))
+	}
+}
+
+// apiPartyNewRoom creates a room owned by the leader of the party and seats the party there
+func (e *Engine) apiPartyNewRoom(w http.ResponseWriter, r *http.Request) {
+	p, ok := e.partyLeader(w, r)
+	if !ok {
+		return
+	}
+	gameRef := chi.URLParam(r, "gameRef")
+	settings, err := readRoomSettings(r)
+	if err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiPartyNewRoom]")+" ", 0)
+			l.Printf("Invalid JSON: %v", err)
+		}
+		Error(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if _, err := e.searchGame(gameRef); err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiPartyNewRoom]")+" ", 0)
+			l.Printf("Game not found: %s", gameRef)
+		}
+		Error(w, http.StatusNotFound, "game not found")
+		return
+	}
+
+	var fields FieldErrors
+	if room, err := e.newRoom(p.leader+"room", p.leader+"room", gameRef, p.leader, settings); errors.As(err, &fields) {
+		ValidationError(w, fields)
+	} else if err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiPartyNewRoom]")+" ", 0)
+			l.Printf("Failed to create new room: %v", err)
+		}
+		Error(w, http.StatusInternalServerError, "failed to create new room")
+	} else if ce := e.seatParty(room, p); ce != nil {
+		// A room the party does not fit in is of no use
+		e.removeRoom(room.id)
+		CommandFailure(w, ce)
+	} else {
+		response := e.partyJoined(room, p, "room created and joined")
+		response["invite"] = room.invite
+		JSON(w, http.StatusOK, response)
+	}
+}
+
+// partyJoined describes the seats the members of a party took in a room
+func (e *Engine) partyJoined(room *Room, p *Party, status string) map[string]any {
+	room.clientsMutex.RLock()
+	seats := room.seatsInfo()
+	room.clientsMutex.RUnlock()
+	p.mutex.Lock()
+	taken := make(map[string]string, len(p.members))
+	for _, member := range p.members {
+		taken[member] = seats[member]
+	}
+	p.mutex.Unlock()
+	response := map[string]any{"status": status, "room_id": room.id, "party": p.id, "seats": taken}
+	if team := room.teamOf(p.leader); team != "" {
+		response["team"] = team
+	}
+	return response
+}
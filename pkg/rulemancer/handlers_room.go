@@ -5,6 +5,7 @@ package rulemancer
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -15,6 +16,7 @@ import (
 
 func (e *Engine) roomRoutes(r chi.Router) {
 	r.Use(jwtauth.Verifier(e.JWTAuth))
+	r.Use(e.ticketVerifier)
 	r.Use(jwtauth.Authenticator(e.JWTAuth))
 	r.Route("/", func(r chi.Router) {
 		r.Post("/create", e.apiCreateRoom)
@@ -29,6 +31,7 @@ type CreateRoomRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	GameRef     string `json:"game_ref"`
+	RoomSettings
 }
 
 func (e *Engine) apiCreateRoom(w http.ResponseWriter, r *http.Request) {
@@ -41,8 +44,20 @@ func (e *Engine) apiCreateRoom(w http.ResponseWriter, r *http.Request) {
 		Error(w, http.StatusBadRequest, "invalid json")
 		return
 	}
+	owner := ""
+	if _, claims, err := jwtauth.FromContext(r.Context()); err == nil {
+		owner, _ = claims["id"].(string)
+	}
 
-	if room, err := e.newRoom(req.Name, req.Description, req.GameRef); err != nil {
+	var fields FieldErrors
+	if room, err := e.newRoom(req.Name, req.Description, req.GameRef, owner, req.RoomSettings); errors.As(err, &fields) {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiCreateRoom]")+" ", 0)
+			l.Printf("Invalid room settings: %v", fields)
+		}
+		ValidationError(w, fields)
+		return
+	} else if err != nil {
 		if e.Debug {
 			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiCreateRoom]")+" ", 0)
 			l.Printf("Failed to create room: %v", err)
@@ -55,7 +70,8 @@ func (e *Engine) apiCreateRoom(w http.ResponseWriter, r *http.Request) {
 			l.Printf("Room created: %v", room)
 		}
 		JSON(w, http.StatusCreated, map[string]string{
-			"id": room.id,
+			"id":     room.id,
+			"invite": room.invite,
 		})
 	}
 }
@@ -92,17 +108,7 @@ func (e *Engine) apiGetRoom(w http.ResponseWriter, r *http.Request) {
 			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/apiGetRoom]")+" ", 0)
 			l.Printf("Room info provided to client: %v", room)
 		}
-		JSON(w, http.StatusOK, map[string]any{
-			"id":                room.id,
-			"name":              room.name,
-			"description":       room.description,
-			"clips_instance":    room.clipsInstance.Info(),
-			"running_game":      room.game.name,
-			"num_clients":       room.maxClients,
-			"playing_clients":   room.clients,
-			"watching_clients":  room.watchers,
-			"connected_sockets": room.socketsInfo(),
-		})
+		JSON(w, http.StatusOK, room.Info())
 	}
 }
 
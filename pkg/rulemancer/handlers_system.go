@@ -16,11 +16,13 @@ import (
 
 func (e *Engine) systemRoutes(r chi.Router) {
 	r.Use(jwtauth.Verifier(e.JWTAuth))
+	r.Use(e.ticketVerifier)
 	r.Use(jwtauth.Authenticator(e.JWTAuth))
 	r.Route("/", func(r chi.Router) {
 		r.Get("/health", e.health)
 		r.Post("/quit", e.quit)
 		r.HandleFunc("/ws", e.systemMonitor)
+		r.Post("/ticket", e.apiSystemTicket)
 	})
 }
 
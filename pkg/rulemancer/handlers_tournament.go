@@ -0,0 +1,173 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/go-chi/chi/v5"
+	jwtauth "github.com/go-chi/jwtauth/v5"
+)
+
+func (e *Engine) tournamentRoutes(r chi.Router) {
+	r.Use(jwtauth.Verifier(e.JWTAuth))
+	r.Use(e.ticketVerifier)
+	r.Use(jwtauth.Authenticator(e.JWTAuth))
+	r.Route("/", func(r chi.Router) {
+		r.Get("/", e.apiListTournaments)
+		r.Post("/", e.apiNewTournament)
+		r.Get("/{id}", e.apiGetTournament)
+		r.Get("/{id}/standings", e.apiTournamentStandings)
+		r.Post("/{id}/register", e.apiRegisterTournament)
+		r.Delete("/{id}/register", e.apiUnregisterTournament)
+		r.Post("/{id}/start", e.apiStartTournament)
+		r.Get("/{id}/events", e.apiTournamentEvents)
+		r.HandleFunc("/{id}/ws", e.tournamentMonitor)
+		r.Post("/{id}/ticket", e.apiTournamentTicket)
+	})
+}
+
+// requesterID returns the client id of the token of a request, or writes the error
+func requesterID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		Error(w, http.StatusUnauthorized, "unauthorized")
+		return "", false
+	} else if clientID, ok := claims["id"].(string); !ok {
+		Error(w, http.StatusUnauthorized, "unauthorized")
+		return "", false
+	} else {
+		return clientID, true
+	}
+}
+
+// tournamentRequester returns the client id and the tournament of a request, or writes the error
+func (e *Engine) tournamentRequester(w http.ResponseWriter, r *http.Request) (string, *Tournament, bool) {
+	id := chi.URLParam(r, "id")
+	if clientID, ok := requesterID(w, r); !ok {
+		return "", nil, false
+	} else if t, err := e.searchTournament(id); err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/tournamentRequester]")+" ", 0)
+			l.Printf("Tournament not found: %s", id)
+		}
+		Error(w, http.StatusNotFound, "tournament not found")
+		return "", nil, false
+	} else {
+		return clientID, t, true
+	}
+}
+
+func (e *Engine) apiListTournaments(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requesterID(w, r); ok {
+		JSON(w, http.StatusOK, map[string]any{"tournaments": e.listTournaments()})
+	}
+}
+
+func (e *Engine) apiNewTournament(w http.ResponseWriter, r *http.Request) {
+	clientID, ok := requesterID(w, r)
+	if !ok {
+		return
+	}
+	var req NewTournamentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiNewTournament]")+" ", 0)
+			l.Printf("Invalid JSON: %v", err)
+		}
+		Error(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	if t, ce := e.newTournament(clientID, req); ce != nil {
+		CommandFailure(w, ce)
+	} else {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/apiNewTournament]")+" ", 0)
+			l.Printf("Tournament %s (%s) created by %s", t.id, t.format, clientID)
+		}
+		JSON(w, http.StatusCreated, t.Info())
+	}
+}
+
+func (e *Engine) apiGetTournament(w http.ResponseWriter, r *http.Request) {
+	if _, t, ok := e.tournamentRequester(w, r); ok {
+		JSON(w, http.StatusOK, t.Info())
+	}
+}
+
+func (e *Engine) apiTournamentStandings(w http.ResponseWriter, r *http.Request) {
+	if _, t, ok := e.tournamentRequester(w, r); ok {
+		t.mutex.Lock()
+		standings := t.standings()
+		round, state := len(t.rounds), t.state
+		t.mutex.Unlock()
+		JSON(w, http.StatusOK, map[string]any{"tournament": t.id, "state": state, "round": round, "standings": standings})
+	}
+}
+
+func (e *Engine) apiRegisterTournament(w http.ResponseWriter, r *http.Request) {
+	if clientID, t, ok := e.tournamentRequester(w, r); ok {
+		if client, err := e.searchClient(clientID); err != nil {
+			Error(w, http.StatusNotFound, "client not found")
+		} else if ce := e.registerPlayer(t, client); ce != nil {
+			CommandFailure(w, ce)
+		} else {
+			JSON(w, http.StatusOK, map[string]string{"status": "registered", "tournament": t.id})
+		}
+	}
+}
+
+func (e *Engine) apiUnregisterTournament(w http.ResponseWriter, r *http.Request) {
+	if clientID, t, ok := e.tournamentRequester(w, r); ok {
+		if ce := e.unregisterPlayer(t, clientID); ce != nil {
+			CommandFailure(w, ce)
+		} else {
+			JSON(w, http.StatusOK, map[string]string{"status": "unregistered", "tournament": t.id})
+		}
+	}
+}
+
+// apiStartTournament closes the registration and starts the first round, on behalf of the creator or the admin
+func (e *Engine) apiStartTournament(w http.ResponseWriter, r *http.Request) {
+	if clientID, t, ok := e.tournamentRequester(w, r); ok {
+		if clientID != "admin" && clientID != t.owner {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiStartTournament]")+" ", 0)
+				l.Printf("Forbidden start of tournament %s by %s", t.id, clientID)
+			}
+			Error(w, http.StatusForbidden, "forbidden")
+		} else if ce := e.startTournament(t); ce != nil {
+			CommandFailure(w, ce)
+		} else {
+			JSON(w, http.StatusOK, t.Info())
+		}
+	}
+}
+
+// apiTournamentEvents streams the tournament events to any authenticated client
+func (e *Engine) apiTournamentEvents(w http.ResponseWriter, r *http.Request) {
+	if clientID, t, ok := e.tournamentRequester(w, r); ok {
+		e.serveEventStream(w, r, t.events, clientID, t.snapshot)
+	}
+}
+
+// tournamentMonitor sends the tournament events on a websocket to any authenticated client
+func (e *Engine) tournamentMonitor(w http.ResponseWriter, r *http.Request) {
+	if clientID, t, ok := e.tournamentRequester(w, r); ok {
+		e.serveEventSocket(w, r, t.events, clientID, t.snapshot)
+	}
+}
+
+// apiTournamentTicket issues a ticket for the tournament websocket and event stream
+func (e *Engine) apiTournamentTicket(w http.ResponseWriter, r *http.Request) {
+	if clientID, _, ok := e.tournamentRequester(w, r); ok {
+		base := path.Dir(r.URL.Path)
+		e.issueTicket(w, clientID, []string{base + "/ws", base + "/events"})
+	}
+}
@@ -17,6 +17,7 @@ func (e *Engine) watchRoutes(r chi.Router) {
 	r.Use(jwtauth.Authenticator(e.JWTAuth))
 	r.Route("/", func(r chi.Router) {
 		r.Post("/room/{roomId}", e.watchRoom)   // Watch a specific room by ID (read-only)
+		r.Post("/invite/{code}", e.watchRoom)   // Watch the room of an invite code
 		r.Post("/stop/{roomId}", e.unwatchRoom) // Unwatch a specific room by ID
 	})
 }
@@ -24,6 +25,7 @@ func (e *Engine) watchRoutes(r chi.Router) {
 func (e *Engine) watchRoom(w http.ResponseWriter, r *http.Request) {
 	roomId := chi.URLParam(r, "roomId")
 	_, claims, err := jwtauth.FromContext(r.Context())
+	creds, credsErr := readRoomCredentials(r)
 
 	if err != nil {
 		if e.Debug {
@@ -39,8 +41,15 @@ func (e *Engine) watchRoom(w http.ResponseWriter, r *http.Request) {
 		}
 		Error(w, http.StatusUnauthorized, "unauthorized")
 		return
+	} else if credsErr != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/watchRoom]")+" ", 0)
+			l.Printf("Invalid JSON: %v", credsErr)
+		}
+		Error(w, http.StatusBadRequest, "invalid json")
+		return
 	} else {
-		if room, err := e.searchRoom(roomId); err != nil {
+		if room, err := e.requestedRoom(r, "roomId", &creds); err != nil {
 			// Room existence
 			if e.Debug {
 				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/watchRoom]")+" ", 0)
@@ -48,6 +57,13 @@ func (e *Engine) watchRoom(w http.ResponseWriter, r *http.Request) {
 			}
 			Error(w, http.StatusNotFound, "room not found")
 			return
+		} else if ce := room.admit(clientID, creds, true); ce != nil {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/watchRoom]")+" ", 0)
+				l.Printf("Client %s not admitted as a watcher in room %s: %s", clientID, room.id, ce.Message)
+			}
+			CommandFailure(w, ce)
+			return
 		} else if client, err := e.searchClient(clientID); err != nil {
 			// Client existence
 			if e.Debug {
@@ -57,6 +73,8 @@ func (e *Engine) watchRoom(w http.ResponseWriter, r *http.Request) {
 			Error(w, http.StatusNotFound, "client not found")
 			return
 		} else {
+			roomId = room.id
+
 			// Start locking the room
 			room.clientsMutex.RLock()
 
@@ -100,11 +118,12 @@ func (e *Engine) watchRoom(w http.ResponseWriter, r *http.Request) {
 			// Apply the join to both the room and the client
 			room.watchers[clientID] = client
 			client.watchingRooms[roomId] = room
+			room.emit(EventWatcherJoined, clientID, map[string]string{"client": clientID, "name": client.name})
 			if e.Debug {
 				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/watchRoom]")+" ", 0)
 				l.Printf("Client started watching room: %s", roomId)
 			}
-			JSON(w, http.StatusOK, map[string]string{"status": "watching"})
+			JSON(w, http.StatusOK, map[string]string{"status": "watching", "room_id": roomId})
 			return
 		}
 	}
@@ -175,6 +194,7 @@ func (e *Engine) unwatchRoom(w http.ResponseWriter, r *http.Request) {
 			// Apply the join to both the room and the client
 			delete(room.watchers, clientID)
 			delete(client.watchingRooms, roomId)
+			room.emit(EventWatcherLeft, clientID, map[string]string{"client": clientID, "reason": "unwatched"})
 			if e.Debug {
 				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/unwatchRoom]")+" ", 0)
 				l.Printf("Client stopped watching room: %s", roomId)
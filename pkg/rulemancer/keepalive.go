@@ -0,0 +1,64 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// keepAlive arms the read deadline of a websocket and pushes it forward on each pong, a peer that stops answering
+// the pings makes the reader fail and the monitor close the connection
+func (e *Engine) keepAlive(conn *websocket.Conn) {
+	if e.WSPongTimeoutMs <= 0 {
+		return
+	}
+	e.extendReadDeadline(conn)
+	conn.SetPongHandler(func(string) error {
+		e.extendReadDeadline(conn)
+		return nil
+	})
+}
+
+// extendReadDeadline gives the peer another pong timeout to send something
+func (e *Engine) extendReadDeadline(conn *websocket.Conn) {
+	if e.WSPongTimeoutMs > 0 {
+		conn.SetReadDeadline(time.Now().Add(time.Duration(e.WSPongTimeoutMs) * time.Millisecond))
+	}
+}
+
+// writeDeadline is the time a write started now has to complete, zero when writes have no limit
+func (e *Engine) writeDeadline() time.Time {
+	if e.WSWriteTimeoutMs <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(e.WSWriteTimeoutMs) * time.Millisecond)
+}
+
+// writeMessage sends a text message, a peer that does not read it in time gets the connection closed
+func (e *Engine) writeMessage(conn *websocket.Conn, msg []byte) error {
+	conn.SetWriteDeadline(e.writeDeadline())
+	return conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+// pingTicker returns the channel the monitors ping their websocket on and the function to stop it. The channel is
+// nil when the pings are disabled, so it never fires.
+func (e *Engine) pingTicker() (<-chan time.Time, func()) {
+	if e.WSPingIntervalMs <= 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(time.Duration(e.WSPingIntervalMs) * time.Millisecond)
+	return ticker.C, ticker.Stop
+}
+
+// ping sends a ping, control messages may be written concurrently with the writer goroutine
+func (e *Engine) ping(conn *websocket.Conn) error {
+	deadline := e.writeDeadline()
+	if deadline.IsZero() {
+		// WriteControl needs a deadline
+		deadline = time.Now().Add(time.Minute)
+	}
+	return conn.WriteControl(websocket.PingMessage, nil, deadline)
+}
@@ -0,0 +1,79 @@
+package rulemancer
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ClipsLoadError describes an error reported by CLIPS while loading a rules file
+type ClipsLoadError struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Construct string `json:"construct,omitempty"`
+	Message   string `json:"message"`
+}
+
+func (le ClipsLoadError) Error() string {
+	location := filepath.Base(le.File)
+	if le.Line > 0 {
+		location += ":" + strconv.Itoa(le.Line)
+	}
+	if le.Construct != "" {
+		location += " (" + le.Construct + ")"
+	}
+	return location + ": " + le.Message
+}
+
+// ClipsLoadErrors collects the errors found while loading the rules of a game
+type ClipsLoadErrors []ClipsLoadError
+
+func (les ClipsLoadErrors) Error() string {
+	msgs := make([]string, len(les))
+	for i, le := range les {
+		msgs[i] = le.Error()
+	}
+	return fmt.Sprintf("%d error(s) loading rules: %s", len(les), strings.Join(msgs, "; "))
+}
+
+var constructPattern = regexp.MustCompile(`\((def[a-z-]+)\s+(?:[^\s()]+::)?([^\s()]+)`)
+
+// parseLoadErrors decodes the errors produced by clips_load. Every error is a record terminated by \x1e with
+// fields separated by \x1f: file, line and the error text printed by CLIPS. The text usually ends with the
+// offending construct, which is used to find the construct name.
+func parseLoadErrors(raw string) ClipsLoadErrors {
+	result := make(ClipsLoadErrors, 0)
+
+	for _, record := range strings.Split(raw, "\x1e") {
+		if strings.TrimSpace(record) == "" {
+			continue
+		}
+		fields := strings.SplitN(record, "\x1f", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		le := ClipsLoadError{File: fields[0]}
+		le.Line, _ = strconv.Atoi(fields[1])
+
+		text := strings.TrimSpace(fields[2])
+		if m := constructPattern.FindStringSubmatch(text); m != nil {
+			le.Construct = m[1] + " " + m[2]
+		}
+
+		// The message is the first meaningful line of the error text, the rest echoes the construct
+		for _, line := range strings.Split(text, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && line != "ERROR:" {
+				le.Message = line
+				break
+			}
+		}
+
+		result = append(result, le)
+	}
+
+	return result
+}
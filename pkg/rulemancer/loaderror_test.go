@@ -0,0 +1,51 @@
+package rulemancer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLoadErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected ClipsLoadErrors
+	}{
+		{
+			name:     "no errors",
+			input:    "",
+			expected: ClipsLoadErrors{},
+		},
+		{
+			name:  "syntax error in a deftemplate",
+			input: "rules/common.clp\x1f12\x1f\n[PRNTUTIL2] Syntax Error:  Check appropriate syntax for deftemplate.\n\nERROR:\n(deftemplate MAIN::move\n   (slot x (type INTEGR\x1e",
+			expected: ClipsLoadErrors{
+				{File: "rules/common.clp", Line: 12, Construct: "deftemplate move", Message: "[PRNTUTIL2] Syntax Error:  Check appropriate syntax for deftemplate."},
+			},
+		},
+		{
+			name:  "multiple errors and unknown construct",
+			input: "a.clp\x1f3\x1f[EXPRNPSR3] Missing function declaration for foo.\nERROR:\n(defrule check-win\n   =>\n   (foo\x1e" + "b.clp\x1f0\x1funable to open file\x1e",
+			expected: ClipsLoadErrors{
+				{File: "a.clp", Line: 3, Construct: "defrule check-win", Message: "[EXPRNPSR3] Missing function declaration for foo."},
+				{File: "b.clp", Line: 0, Message: "unable to open file"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseLoadErrors(tt.input)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("parseLoadErrors() = %+v, want %+v", result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestClipsLoadErrorString(t *testing.T) {
+	le := ClipsLoadError{File: "rulepool/tictactoe/common.clp", Line: 7, Construct: "deftemplate move", Message: "syntax error"}
+	if got, want := le.Error(), "common.clp:7 (deftemplate move): syntax error"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,428 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	jwtauth "github.com/go-chi/jwtauth/v5"
+)
+
+// Lobby event types, they use the room events envelope with the room field omitted
+const (
+	LobbyRoomCreated  = "room_created"
+	LobbyRoomDeleted  = "room_deleted"
+	LobbyRoomUpdated  = "room_updated"
+	LobbyRoomFilled   = "room_filled"
+	LobbyRoomFinished = "room_finished"
+)
+
+// Room states as shown in the lobby
+const (
+	RoomStateWaiting = "waiting" // there are free seats
+	RoomStatePlaying = "playing" // every seat is taken
+	RoomStateEnded   = "ended"   // the game is over
+)
+
+// Room visibilities. Only public rooms are shown in the lobby and filled by the join of the first available room,
+// unlisted rooms are joined by id and private rooms only with their invite code or password.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// Room directory limits
+const (
+	directoryDefaultLimit = 50
+	directoryMaxLimit     = 200
+)
+
+func (e *Engine) lobbyRoutes(r chi.Router) {
+	r.Use(jwtauth.Verifier(e.JWTAuth))
+	r.Use(e.ticketVerifier)
+	r.Use(jwtauth.Authenticator(e.JWTAuth))
+	r.Route("/", func(r chi.Router) {
+		r.Get("/rooms", e.apiRoomDirectory)
+		r.Get("/events", e.apiLobbyEvents)
+		r.HandleFunc("/ws", e.lobbyMonitor)
+		r.Post("/ticket", e.apiLobbyTicket)
+	})
+}
+
+// LobbyRoom is what the lobby tells about a room
+type LobbyRoom struct {
+	Room       string `json:"room"`
+	Name       string `json:"name"`
+	Game       string `json:"game"`
+	Players    int    `json:"players"`
+	MaxPlayers int    `json:"max_players"`
+	FreeSeats  int    `json:"free_seats"`
+	Watchers   int    `json:"watchers"`
+	State      string `json:"state"`
+	Visibility string `json:"visibility"`
+	Ended      bool   `json:"ended"`
+	Created    int64  `json:"created"`
+
+	gameName string // the directory filters on the game name as well
+}
+
+// lobbyInfo returns what the lobby tells about a room
+func (r *Room) lobbyInfo() LobbyRoom {
+	r.clientsMutex.RLock()
+	players := len(r.clients)
+	r.clientsMutex.RUnlock()
+	r.watchersMutex.RLock()
+	watchers := len(r.watchers)
+	r.watchersMutex.RUnlock()
+
+	ended := r.hasEnded()
+	freeSeats := r.maxClients - players
+	if freeSeats < 0 {
+		freeSeats = 0
+	}
+	state := RoomStateWaiting
+	if ended {
+		state = RoomStateEnded
+	} else if freeSeats == 0 {
+		state = RoomStatePlaying
+	}
+
+	return LobbyRoom{
+		Room:       r.id,
+		Name:       r.name,
+		Game:       r.game.id,
+		Players:    players,
+		MaxPlayers: r.maxClients,
+		FreeSeats:  freeSeats,
+		Watchers:   watchers,
+		State:      state,
+		Visibility: r.roomVisibility(),
+		Ended:      ended,
+		Created:    r.created,
+		gameName:   r.game.name,
+	}
+}
+
+// announce sends a lobby event about the room, the rooms that are not public are kept out of the lobby
+func (r *Room) announce(eventType, actor string, payload any) {
+	if r.listed() {
+		r.lobby.emit(eventType, actor, payload)
+	}
+}
+
+// lobbyRooms returns the rooms as the lobby tells about them, sorted by id: the public rooms and those the
+// client created, plays or watches
+func (e *Engine) lobbyRooms(clientID string) []LobbyRoom {
+	e.roomsMutex.RLock()
+	rooms := make([]*Room, 0, len(e.rooms))
+	for _, room := range e.rooms {
+		if room.listed() || (clientID != "" && room.member(clientID)) {
+			rooms = append(rooms, room)
+		}
+	}
+	e.roomsMutex.RUnlock()
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].id < rooms[j].id })
+
+	infos := make([]LobbyRoom, 0, len(rooms))
+	for _, room := range rooms {
+		infos = append(infos, room.lobbyInfo())
+	}
+	return infos
+}
+
+// lobbySnapshot returns the public rooms as the lobby tells about them, sorted by id
+func (e *Engine) lobbySnapshot(ctx context.Context) (any, error) {
+	return map[string]any{"rooms": e.lobbyRooms("")}, nil
+}
+
+// directoryQuery selects, sorts and pages the rooms of the directory
+type directoryQuery struct {
+	game       string
+	state      string
+	visibility string
+	freeSeats  int // minimum number of free seats
+	sort       string
+	desc       bool
+	limit      int
+	offset     int
+}
+
+// parseDirectoryQuery reads the directory query parameters, the invalid ones are reported as field errors
+func parseDirectoryQuery(values url.Values) (directoryQuery, []FieldError) {
+	q := directoryQuery{
+		game:       values.Get("game"),
+		state:      values.Get("state"),
+		visibility: values.Get("visibility"),
+		sort:       "created",
+		limit:      directoryDefaultLimit,
+	}
+	fields := make([]FieldError, 0)
+
+	switch q.state {
+	case "", RoomStateWaiting, RoomStatePlaying, RoomStateEnded:
+	default:
+		fields = append(fields, FieldError{Path: "state", Message: "must be one of waiting, playing, ended"})
+	}
+	switch q.visibility {
+	case "", VisibilityPublic, VisibilityUnlisted, VisibilityPrivate:
+	default:
+		fields = append(fields, FieldError{Path: "visibility", Message: "must be one of public, unlisted, private"})
+	}
+
+	integers := []struct {
+		name  string
+		value *int
+		min   int
+		max   int
+	}{
+		{"free_seats", &q.freeSeats, 0, -1},
+		{"limit", &q.limit, 1, directoryMaxLimit},
+		{"offset", &q.offset, 0, -1},
+	}
+	for _, p := range integers {
+		raw := values.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(raw); err != nil || n < p.min || (p.max >= 0 && n > p.max) {
+			message := "must be an integer not lower than " + strconv.Itoa(p.min)
+			if p.max >= 0 {
+				message = "must be an integer between " + strconv.Itoa(p.min) + " and " + strconv.Itoa(p.max)
+			}
+			fields = append(fields, FieldError{Path: p.name, Message: message})
+		} else {
+			*p.value = n
+		}
+	}
+
+	if raw := values.Get("sort"); raw != "" {
+		key := strings.TrimPrefix(raw, "-")
+		switch key {
+		case "created", "name", "players", "free_seats":
+			q.sort = key
+			q.desc = strings.HasPrefix(raw, "-")
+		default:
+			fields = append(fields, FieldError{Path: "sort", Message: "must be one of created, name, players, free_seats, optionally prefixed by -"})
+		}
+	}
+
+	return q, fields
+}
+
+// matches tells whether a room passes the filters of the query
+func (q directoryQuery) matches(room LobbyRoom) bool {
+	if q.game != "" && room.Game != q.game && room.gameName != q.game {
+		return false
+	}
+	if q.state != "" && room.State != q.state {
+		return false
+	}
+	if q.visibility != "" && room.Visibility != q.visibility {
+		return false
+	}
+	return room.FreeSeats >= q.freeSeats
+}
+
+// apply filters and sorts the rooms, it returns the requested page and the number of rooms passing the filters.
+// The rooms are expected sorted by id, which breaks the ties.
+func (q directoryQuery) apply(rooms []LobbyRoom) ([]LobbyRoom, int) {
+	selected := make([]LobbyRoom, 0, len(rooms))
+	for _, room := range rooms {
+		if q.matches(room) {
+			selected = append(selected, room)
+		}
+	}
+
+	less := map[string]func(a, b LobbyRoom) bool{
+		"created":    func(a, b LobbyRoom) bool { return a.Created < b.Created },
+		"name":       func(a, b LobbyRoom) bool { return a.Name < b.Name },
+		"players":    func(a, b LobbyRoom) bool { return a.Players < b.Players },
+		"free_seats": func(a, b LobbyRoom) bool { return a.FreeSeats < b.FreeSeats },
+	}[q.sort]
+	sort.SliceStable(selected, func(i, j int) bool {
+		if q.desc {
+			return less(selected[j], selected[i])
+		}
+		return less(selected[i], selected[j])
+	})
+
+	total := len(selected)
+	if q.offset >= total {
+		return []LobbyRoom{}, total
+	}
+	end := q.offset + q.limit
+	if end > total {
+		end = total
+	}
+	return selected[q.offset:end], total
+}
+
+// apiRoomDirectory lists the rooms to any authenticated client, filtered, sorted and paged
+func (e *Engine) apiRoomDirectory(w http.ResponseWriter, r *http.Request) {
+	clientID := ""
+	if _, claims, err := jwtauth.FromContext(r.Context()); err == nil {
+		clientID, _ = claims["id"].(string)
+	}
+	q, fields := parseDirectoryQuery(r.URL.Query())
+	if len(fields) > 0 {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiRoomDirectory]")+" ", 0)
+			l.Printf("Invalid directory query: %v", fields)
+		}
+		ValidationError(w, fields)
+		return
+	}
+
+	rooms, total := q.apply(e.lobbyRooms(clientID))
+	JSON(w, http.StatusOK, map[string]any{
+		"rooms":  rooms,
+		"total":  total,
+		"offset": q.offset,
+		"limit":  q.limit,
+	})
+}
+
+// apiLobbyEvents streams the lobby events to any authenticated client
+func (e *Engine) apiLobbyEvents(w http.ResponseWriter, r *http.Request) {
+	if clientID, ok := requesterID(w, r); ok {
+		e.serveEventStream(w, r, e.lobby, clientID, e.lobbySnapshot)
+	}
+}
+
+// apiLobbyTicket issues a ticket for the lobby websocket and event stream to any authenticated client
+func (e *Engine) apiLobbyTicket(w http.ResponseWriter, r *http.Request) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	} else if clientID, ok := claims["id"].(string); !ok {
+		Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	} else {
+		base := path.Dir(r.URL.Path)
+		e.issueTicket(w, clientID, []string{base + "/ws", base + "/events"})
+	}
+}
+
+// lobbyMonitor sends the lobby events on a websocket, what the client sends is ignored
+func (e *Engine) lobbyMonitor(w http.ResponseWriter, r *http.Request) {
+	if clientID, ok := requesterID(w, r); ok {
+		e.serveEventSocket(w, r, e.lobby, clientID, e.lobbySnapshot)
+	}
+}
+
+// serveEventSocket sends the events of a hub on a websocket until the client goes away or its subscription is
+// ended, what the client sends is ignored. A client reconnecting with the since parameter gets the events it
+// missed first.
+func (e *Engine) serveEventSocket(w http.ResponseWriter, r *http.Request, hub *eventHub, client string, snapshot snapshotFunc) {
+	replay, since, err := parseSince(r)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "invalid since parameter")
+		return
+	}
+
+	conn, err := e.upgrader().Upgrade(w, r, nil)
+	if err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/serveEventSocket]")+" ", 0)
+			l.Println("upgrade error:", err)
+		}
+		return
+	}
+
+	sub, backlog, resync, seq := hub.subscribe(conn.RemoteAddr().String(), client, replay, since)
+	defer func() {
+		hub.unsubscribe(sub)
+		conn.Close()
+	}()
+
+	e.keepAlive(conn)
+	pings, stopPings := e.pingTicker()
+	defer stopPings()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// reader async, it only sees the pongs and the connection going away
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				if e.Debug {
+					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/serveEventSocket]")+" ", 0)
+					l.Println("read error:", err)
+				}
+				return
+			}
+			e.extendReadDeadline(conn)
+		}
+	}()
+
+	lastSeq := seq
+	if replay {
+		lastSeq = since
+	}
+
+	// send writes the messages, false when the connection is gone
+	send := func(messages []socketMessage) bool {
+		for _, msg := range messages {
+			if err := e.writeMessage(conn, msg.message); err != nil {
+				if e.Debug {
+					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/serveEventSocket]")+" ", 0)
+					l.Println("write error:", err)
+				}
+				return false
+			}
+			lastSeq = msg.seq
+		}
+		return true
+	}
+
+	catchUp := func(backlog []socketMessage, resync bool, seq uint64) bool {
+		if messages, err := hub.catchUp(ctx, snapshot, lastSeq, backlog, resync, seq); err != nil {
+			return false
+		} else {
+			return send(messages)
+		}
+	}
+
+	if replay && !catchUp(backlog, resync, seq) {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sub.closed:
+			return
+		case <-pings:
+			if err := e.ping(conn); err != nil {
+				return
+			}
+		case <-sub.lagged:
+			if !catchUp(hub.eventsSince(lastSeq)) {
+				return
+			}
+		case msg := <-sub.ch:
+			if msg.seq <= lastSeq {
+				// Already sent by a replay
+				continue
+			}
+			if !send([]socketMessage{msg}) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package rulemancer
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRoomEmitLobby(t *testing.T) {
+	room, _ := newEventsTestRoom(&Game{id: "tictactoe"})
+	room.lobby = newEventHub("", 8)
+	lobby, _, _, _ := room.lobby.subscribe("lobby", "", false, 0)
+
+	room.emit(EventPlayerJoined, "alice", map[string]string{"client": "alice"})
+	room.emit(EventOutput, "", OutputLine{Channel: "t", Text: "hello"})
+	room.emit(EventGameEnded, "alice", nil)
+
+	events := receivedEvents(t, lobby.ch)
+	if len(events) != 3 {
+		t.Fatalf("expected 3 lobby events, got %d", len(events))
+	}
+	for i, change := range []string{EventPlayerJoined, EventGameEnded} {
+		payload, _ := events[i].Payload.(map[string]any)
+		if events[i].Type != LobbyRoomUpdated || events[i].Room != "" || payload["room"] != "room1" || payload["change"] != change {
+			t.Errorf("unexpected lobby event %d: %+v", i, events[i])
+		}
+	}
+	if payload, _ := events[2].Payload.(map[string]any); events[2].Type != LobbyRoomFinished || payload["game"] != "tictactoe" {
+		t.Errorf("unexpected lobby event 2: %+v", events[2])
+	}
+}
+
+func TestEmitPlayerJoinedFilled(t *testing.T) {
+	room, _ := newEventsTestRoom(&Game{id: "tictactoe"})
+	room.maxClients = 2
+	room.lobby = newEventHub("", 8)
+	lobby, _, _, _ := room.lobby.subscribe("lobby", "", false, 0)
+
+	for _, id := range []string{"alice", "bob"} {
+		room.clients[id] = &Client{id: id}
+		room.emitPlayerJoined(room.clients[id])
+	}
+
+	types := make([]string, 0)
+	for _, event := range receivedEvents(t, lobby.ch) {
+		types = append(types, event.Type)
+	}
+	expected := []string{LobbyRoomUpdated, LobbyRoomUpdated, LobbyRoomFilled}
+	if strings.Join(types, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, got %v", expected, types)
+	}
+}
+
+func TestLobbySnapshot(t *testing.T) {
+	e := NewEngine("secret")
+	for _, id := range []string{"b", "a"} {
+		room, _ := newEventsTestRoom(&Game{id: "tictactoe"})
+		room.id = id
+		room.maxClients = 2
+		e.rooms[id] = room
+	}
+	e.rooms["a"].clients["alice"] = &Client{id: "alice"}
+
+	snapshot, err := e.lobbySnapshot(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rooms := snapshot.(map[string]any)["rooms"].([]LobbyRoom)
+	if len(rooms) != 2 || rooms[0].Room != "a" || rooms[1].Room != "b" {
+		t.Fatalf("unexpected rooms: %v", rooms)
+	}
+	if rooms[0].Players != 1 || rooms[0].MaxPlayers != 2 || rooms[0].FreeSeats != 1 || rooms[0].Game != "tictactoe" || rooms[0].State != RoomStateWaiting {
+		t.Errorf("unexpected room info: %+v", rooms[0])
+	}
+}
+
+func TestRoomDirectory(t *testing.T) {
+	rooms := []LobbyRoom{
+		{Room: "a", Name: "zeta", Game: "tictactoe", gameName: "TicTacToe", Players: 1, MaxPlayers: 2, FreeSeats: 1, State: RoomStateWaiting, Visibility: VisibilityPublic, Created: 30},
+		{Room: "b", Name: "alpha", Game: "tictactoe", gameName: "TicTacToe", Players: 2, MaxPlayers: 2, FreeSeats: 0, State: RoomStatePlaying, Visibility: VisibilityPublic, Created: 10},
+		{Room: "c", Name: "mid", Game: "chess", gameName: "Chess", Players: 0, MaxPlayers: 2, FreeSeats: 2, State: RoomStateWaiting, Visibility: VisibilityPublic, Created: 20},
+		{Room: "d", Name: "done", Game: "chess", gameName: "Chess", Players: 2, MaxPlayers: 2, FreeSeats: 0, State: RoomStateEnded, Visibility: VisibilityPublic, Ended: true, Created: 40},
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		rooms  string
+		total  int
+		fields []string
+	}{
+		{name: "oldest first", query: "", rooms: "b,c,a,d", total: 4},
+		{name: "newest first", query: "sort=-created", rooms: "d,a,c,b", total: 4},
+		{name: "by name", query: "sort=name", rooms: "b,d,c,a", total: 4},
+		{name: "by game id", query: "game=tictactoe", rooms: "b,a", total: 2},
+		{name: "by game name", query: "game=Chess", rooms: "c,d", total: 2},
+		{name: "open tables with a free seat", query: "game=tictactoe&state=waiting&free_seats=1", rooms: "a", total: 1},
+		{name: "two free seats", query: "free_seats=2", rooms: "c", total: 1},
+		{name: "ended", query: "state=ended", rooms: "d", total: 1},
+		{name: "private", query: "visibility=private", rooms: "", total: 0},
+		{name: "first page", query: "sort=-free_seats&limit=2", rooms: "c,a", total: 4},
+		{name: "second page", query: "sort=-free_seats&limit=2&offset=2", rooms: "b,d", total: 4},
+		{name: "past the end", query: "offset=10", rooms: "", total: 4},
+		{name: "invalid values", query: "state=open&visibility=hidden&free_seats=-1&limit=0&offset=x&sort=size", fields: []string{"state", "visibility", "free_seats", "limit", "offset", "sort"}},
+		{name: "limit too high", query: "limit=1000", fields: []string{"limit"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			q, fields := parseDirectoryQuery(values)
+			paths := make([]string, 0, len(fields))
+			for _, field := range fields {
+				paths = append(paths, field.Path)
+			}
+			if strings.Join(paths, ",") != strings.Join(tt.fields, ",") {
+				t.Fatalf("expected field errors %v, got %v", tt.fields, fields)
+			}
+			if len(fields) > 0 {
+				return
+			}
+			page, total := q.apply(rooms)
+			ids := make([]string, 0, len(page))
+			for _, room := range page {
+				ids = append(ids, room.Room)
+			}
+			if strings.Join(ids, ",") != tt.rooms || total != tt.total {
+				t.Errorf("expected %q (%d), got %q (%d)", tt.rooms, tt.total, strings.Join(ids, ","), total)
+			}
+		})
+	}
+}
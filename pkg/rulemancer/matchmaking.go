@@ -0,0 +1,321 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Matchmaking event types, sent on the matchmaking websocket with the room events envelope
+const (
+	MatchQueued = "match_queued"
+	MatchFound  = "match_found"
+	MatchFailed = "match_failed"
+)
+
+// MatchPlayer is a client seated by the matchmaker
+type MatchPlayer struct {
+	Client string  `json:"client"`
+	Name   string  `json:"name"`
+	Seat   string  `json:"seat"`
+	Rating float64 `json:"rating"`
+}
+
+// MatchResult tells a matched client where it was seated
+type MatchResult struct {
+	Room    string        `json:"room"`
+	Game    string        `json:"game"`
+	Seat    string        `json:"seat"`
+	Players []MatchPlayer `json:"players"`
+}
+
+// matchTicket is a client waiting in a matchmaking queue. It is done when the client is matched or when the
+// match could not be set up, the outcome stays until the client collects it.
+type matchTicket struct {
+	client  *Client
+	game    *Game
+	rating  float64
+	since   time.Time
+	matched bool // taken from the queue, being seated
+	done    chan struct{}
+	result  *MatchResult
+	err     string
+}
+
+func (t *matchTicket) finished() bool {
+	select {
+	case <-t.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// matchmaker keeps the matchmaking tickets by game and by client
+type matchmaker struct {
+	tickets map[string]map[string]*matchTicket
+	mutex   sync.Mutex
+}
+
+func newMatchmaker() *matchmaker {
+	return &matchmaker{
+		tickets: make(map[string]map[string]*matchTicket),
+		mutex:   sync.Mutex{},
+	}
+}
+
+// enqueue puts a client in the queue of a game. A client already waiting keeps its ticket and its place,
+// false is returned in that case.
+func (m *matchmaker) enqueue(game *Game, client *Client, rating float64) (*matchTicket, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.tickets[game.id] == nil {
+		m.tickets[game.id] = make(map[string]*matchTicket)
+	}
+	if t, ok := m.tickets[game.id][client.id]; ok && !t.finished() {
+		// Waiting or being seated
+		return t, false
+	}
+	t := &matchTicket{
+		client: client,
+		game:   game,
+		rating: rating,
+		since:  time.Now(),
+		done:   make(chan struct{}),
+	}
+	m.tickets[game.id][client.id] = t
+	return t, true
+}
+
+// ticket returns the ticket of a client in the queue of a game, waiting or done
+func (m *matchmaker) ticket(gameID, clientID string) (*matchTicket, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	t, ok := m.tickets[gameID][clientID]
+	return t, ok
+}
+
+// remove forgets a ticket, a waiting one leaves the queue. False is returned for a ticket being seated, it
+// can only be forgotten once done.
+func (m *matchmaker) remove(t *matchTicket) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if t.matched && !t.finished() {
+		return false
+	}
+	if m.tickets[t.game.id][t.client.id] == t {
+		delete(m.tickets[t.game.id], t.client.id)
+	}
+	return true
+}
+
+// position returns how many clients have been waiting longer than the ticket in the same queue
+func (m *matchmaker) position(t *matchTicket) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	position := 0
+	for _, other := range m.tickets[t.game.id] {
+		if other != t && !other.matched && other.since.Before(t.since) {
+			position++
+		}
+	}
+	return position
+}
+
+// takeMatches takes from the queues the groups of clients to seat together
+func (m *matchmaker) takeMatches(now time.Time, window func(wait time.Duration) float64) [][]*matchTicket {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	groups := make([][]*matchTicket, 0)
+	for _, queue := range m.tickets {
+		waiting := make([]*matchTicket, 0, len(queue))
+		var game *Game
+		for _, t := range queue {
+			if !t.matched {
+				waiting = append(waiting, t)
+				game = t.game
+			}
+		}
+		if game == nil {
+			continue
+		}
+		for _, group := range formMatches(waiting, game.numPlayers, now, window) {
+			for _, t := range group {
+				t.matched = true
+			}
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// formMatches groups the waiting clients by rating. The clients that waited longest are served first: each one
+// is grouped with the clients closest to its rating, as long as the rating spread of the group fits its window,
+// which widens with the wait.
+func formMatches(waiting []*matchTicket, size int, now time.Time, window func(wait time.Duration) float64) [][]*matchTicket {
+	queue := make([]*matchTicket, len(waiting))
+	copy(queue, waiting)
+	sort.Slice(queue, func(i, j int) bool {
+		if !queue[i].since.Equal(queue[j].since) {
+			return queue[i].since.Before(queue[j].since)
+		}
+		return queue[i].client.id < queue[j].client.id
+	})
+
+	groups := make([][]*matchTicket, 0)
+	if size < 1 {
+		return groups
+	}
+	taken := make(map[*matchTicket]bool)
+	for _, anchor := range queue {
+		if taken[anchor] {
+			continue
+		}
+		candidates := make([]*matchTicket, 0, len(queue))
+		for _, t := range queue {
+			if t != anchor && !taken[t] {
+				candidates = append(candidates, t)
+			}
+		}
+		if len(candidates) < size-1 {
+			break
+		}
+		// The sort is stable, among equally distant clients those waiting longer come first
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return math.Abs(candidates[i].rating-anchor.rating) < math.Abs(candidates[j].rating-anchor.rating)
+		})
+		group := append([]*matchTicket{anchor}, candidates[:size-1]...)
+		low, high := anchor.rating, anchor.rating
+		for _, t := range group {
+			low = math.Min(low, t.rating)
+			high = math.Max(high, t.rating)
+		}
+		if high-low <= window(now.Sub(anchor.since)) {
+			for _, t := range group {
+				taken[t] = true
+			}
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// matchWindow returns the largest rating spread accepted in a group after a wait
+func (e *Engine) matchWindow(wait time.Duration) float64 {
+	return e.MatchRatingWindow + e.MatchWindowGrowth*wait.Seconds()
+}
+
+// runMatchmaker periodically seats together the clients waiting in the matchmaking queues
+func (e *Engine) runMatchmaker() {
+	if e.MatchIntervalMs <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(e.MatchIntervalMs) * time.Millisecond)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		for _, group := range e.matchmaker.takeMatches(now, e.matchWindow) {
+			e.startMatch(group)
+		}
+	}
+}
+
+// seatedRoomSettings are the settings of the rooms the server creates for the players it seats itself: private, so
+// that no other client can take their seats, and watched by anyone who knows the room
+func seatedRoomSettings() RoomSettings {
+	return RoomSettings{RoomAccess: RoomAccess{Visibility: VisibilityPrivate, Watch: WatchAnyone}}
+}
+
+// startMatch creates a room for a group of matched clients, seats them and tells them where
+func (e *Engine) startMatch(group []*matchTicket) {
+	game := group[0].game
+	room, err := e.newRoom("match "+game.name, "Room created by the matchmaker", game.id, "", seatedRoomSettings())
+	if err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/startMatch]")+" ", 0)
+			l.Printf("Failed to create a room for game %s: %v", game.id, err)
+		}
+		for _, t := range group {
+			t.err = "failed to create the room"
+			close(t.done)
+		}
+		return
+	}
+
+	seated := make([]*matchTicket, 0, len(group))
+	for _, t := range group {
+		if ce := e.seatClient(room, t.client); ce != nil {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/startMatch]")+" ", 0)
+				l.Printf("Failed to seat %s in room %s: %s", t.client.id, room.id, ce.Message)
+			}
+			t.err = ce.Message
+			close(t.done)
+		} else {
+			seated = append(seated, t)
+		}
+	}
+
+	room.clientsMutex.RLock()
+	seats := room.seatsInfo()
+	room.clientsMutex.RUnlock()
+	players := make([]MatchPlayer, 0, len(seated))
+	for _, t := range seated {
+		players = append(players, MatchPlayer{Client: t.client.id, Name: t.client.name, Seat: seats[t.client.id], Rating: t.rating})
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].Client < players[j].Client })
+
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/startMatch]")+" ", 0)
+		l.Printf("Match for game %s in room %s: %v", game.id, room.id, players)
+	}
+	for _, t := range seated {
+		t.result = &MatchResult{Room: room.id, Game: game.id, Seat: seats[t.client.id], Players: players}
+		close(t.done)
+	}
+}
+
+// seatClient makes a client a player of a room, as joining it does. The locks are taken in the order of the join
+// of the first available room: the game rooms, the room clients, then the client rooms.
+func (e *Engine) seatClient(room *Room, client *Client) *CommandError {
+	game := room.game
+	game.roomsMutex.Lock()
+	defer game.roomsMutex.Unlock()
+
+	room.clientsMutex.Lock()
+	defer room.clientsMutex.Unlock()
+	if len(room.clients) >= room.maxClients {
+		return &CommandError{Status: http.StatusForbidden, Message: "room is full"}
+	}
+	if _, exists := room.clients[client.id]; exists {
+		return &CommandError{Status: http.StatusConflict, Message: "client already in room"}
+	}
+
+	client.roomsMutex.Lock()
+	defer client.roomsMutex.Unlock()
+
+	if _, ok := game.partialRooms[room.id]; !ok {
+		return &CommandError{Status: http.StatusNotFound, Message: "room not found in game's partial rooms"}
+	}
+	if len(room.clients) == room.maxClients-1 {
+		delete(game.partialRooms, room.id)
+		game.runningRooms[room.id] = room
+	}
+
+	room.watchersMutex.Lock()
+	delete(room.watchers, client.id)
+	room.watchersMutex.Unlock()
+	client.watchersMutex.Lock()
+	delete(client.watchingRooms, room.id)
+	client.watchersMutex.Unlock()
+
+	room.addPlayer(client)
+	client.playingRooms[room.id] = room
+	return nil
+}
@@ -0,0 +1,169 @@
+package rulemancer
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormMatches(t *testing.T) {
+	now := time.Now()
+	window := func(wait time.Duration) float64 { return 100 + 10*wait.Seconds() }
+	ticket := func(id string, rating float64, waited time.Duration) *matchTicket {
+		return &matchTicket{client: &Client{id: id}, rating: rating, since: now.Add(-waited)}
+	}
+
+	tests := []struct {
+		name    string
+		waiting []*matchTicket
+		size    int
+		groups  []string
+	}{
+		{name: "empty queue", waiting: nil, size: 2, groups: []string{}},
+		{name: "alone", waiting: []*matchTicket{ticket("a", 1500, 0)}, size: 2, groups: []string{}},
+		{name: "close ratings", waiting: []*matchTicket{ticket("a", 1500, 0), ticket("b", 1550, 0)}, size: 2, groups: []string{"a+b"}},
+		{name: "too far apart", waiting: []*matchTicket{ticket("a", 1500, 0), ticket("b", 1800, 0)}, size: 2, groups: []string{}},
+		{name: "window widened by the wait", waiting: []*matchTicket{ticket("a", 1500, 30*time.Second), ticket("b", 1800, 0)}, size: 2, groups: []string{"a+b"}},
+		{name: "closest opponent", waiting: []*matchTicket{ticket("a", 1500, 10*time.Second), ticket("b", 1590, 0), ticket("c", 1510, 0)}, size: 2, groups: []string{"a+c"}},
+		{name: "longest waiter first", waiting: []*matchTicket{ticket("c", 1520, 0), ticket("b", 1510, 5*time.Second), ticket("a", 1500, 10*time.Second), ticket("d", 1530, 0)}, size: 2, groups: []string{"a+b", "c+d"}},
+		{name: "three players", waiting: []*matchTicket{ticket("a", 1500, time.Second), ticket("b", 1520, 0), ticket("c", 1480, 0), ticket("d", 1900, 0)}, size: 3, groups: []string{"a+b+c"}},
+		{name: "solo game", waiting: []*matchTicket{ticket("a", 1500, time.Second), ticket("b", 2000, 0)}, size: 1, groups: []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := make([]string, 0)
+			for _, group := range formMatches(tt.waiting, tt.size, now, window) {
+				ids := make([]string, 0, len(group))
+				for _, ticket := range group {
+					ids = append(ids, ticket.client.id)
+				}
+				groups = append(groups, strings.Join(ids, "+"))
+			}
+			if strings.Join(groups, ",") != strings.Join(tt.groups, ",") {
+				t.Errorf("expected %v, got %v", tt.groups, groups)
+			}
+		})
+	}
+}
+
+func TestMatchmakerQueue(t *testing.T) {
+	m := newMatchmaker()
+	game := &Game{id: "tictactoe", numPlayers: 2}
+	alice, bob := &Client{id: "alice"}, &Client{id: "bob"}
+
+	first, queued := m.enqueue(game, alice, 1500)
+	if !queued {
+		t.Fatalf("alice should be queued")
+	}
+	if again, queued := m.enqueue(game, alice, 1500); queued || again != first {
+		t.Errorf("a waiting client must keep its ticket")
+	}
+	second, _ := m.enqueue(game, bob, 1500)
+	second.since = first.since.Add(time.Second)
+	if m.position(first) != 0 || m.position(second) != 1 {
+		t.Errorf("unexpected positions %d and %d", m.position(first), m.position(second))
+	}
+
+	groups := m.takeMatches(time.Now(), func(time.Duration) float64 { return 100 })
+	if len(groups) != 1 || len(groups[0]) != 2 {
+		t.Fatalf("expected one pair, got %v", groups)
+	}
+	if m.remove(first) {
+		t.Errorf("a ticket being seated cannot leave the queue")
+	}
+	if len(m.takeMatches(time.Now(), func(time.Duration) float64 { return 100 })) != 0 {
+		t.Errorf("a ticket must not be matched twice")
+	}
+
+	first.result = &MatchResult{Room: "r1"}
+	close(first.done)
+	if again, queued := m.enqueue(game, alice, 1500); !queued || again == first {
+		t.Errorf("a client whose match is done can queue again")
+	}
+	carol := &Client{id: "carol"}
+	third, _ := m.enqueue(game, carol, 1500)
+	if !m.remove(third) {
+		t.Errorf("a waiting ticket can leave the queue")
+	}
+	if _, ok := m.ticket(game.id, carol.id); ok {
+		t.Errorf("carol should have left the queue")
+	}
+	if m.remove(second) {
+		t.Errorf("bob is still being seated")
+	}
+}
+
+func TestSeatClient(t *testing.T) {
+	e := NewEngine("secret")
+	game := &Game{id: "tictactoe", numPlayers: 2, seats: []string{"x", "o"}, partialRooms: map[string]*Room{}, runningRooms: map[string]*Room{}}
+	room, _ := newEventsTestRoom(game)
+	room.maxClients = 2
+	game.partialRooms[room.id] = room
+
+	clients := []*Client{
+		{id: "alice", playingRooms: map[string]*Room{}, watchingRooms: map[string]*Room{}},
+		{id: "bob", playingRooms: map[string]*Room{}, watchingRooms: map[string]*Room{}},
+		{id: "carol", playingRooms: map[string]*Room{}, watchingRooms: map[string]*Room{}},
+	}
+	room.watchers["bob"] = clients[1]
+	clients[1].watchingRooms[room.id] = room
+
+	if ce := e.seatClient(room, clients[0]); ce != nil {
+		t.Fatalf("unexpected error: %s", ce.Message)
+	}
+	if ce := e.seatClient(room, clients[0]); ce == nil || ce.Status != http.StatusConflict {
+		t.Errorf("a player cannot be seated twice")
+	}
+	if ce := e.seatClient(room, clients[1]); ce != nil {
+		t.Fatalf("unexpected error: %s", ce.Message)
+	}
+	if ce := e.seatClient(room, clients[2]); ce == nil || ce.Status != http.StatusForbidden {
+		t.Errorf("a full room cannot seat more players")
+	}
+
+	if room.seats["alice"] != "x" || room.seats["bob"] != "o" {
+		t.Errorf("unexpected seats %v", room.seats)
+	}
+	if _, watching := room.watchers["bob"]; watching {
+		t.Errorf("a seated watcher must stop watching")
+	}
+	if _, ok := game.runningRooms[room.id]; !ok {
+		t.Errorf("a full room must be running")
+	}
+	if _, ok := clients[0].playingRooms[room.id]; !ok {
+		t.Errorf("the room must be among the playing rooms of the client")
+	}
+}
+
+func TestStartMatch(t *testing.T) {
+	game := duelTestGame()
+	e := newTestEngine(game)
+	alice, bob := e.newClient("alice", ""), e.newClient("bob", "")
+	group := []*matchTicket{
+		{client: alice, game: game, done: make(chan struct{})},
+		{client: bob, game: game, done: make(chan struct{})},
+	}
+
+	e.startMatch(group)
+	for _, ticket := range group {
+		if ticket.result == nil {
+			t.Fatalf("%s was not seated: %s", ticket.client.id, ticket.err)
+		}
+	}
+	room, err := e.searchRoom(group[0].result.Room)
+	if err != nil {
+		t.Fatalf("the match room was not created: %v", err)
+	}
+	// Strangers can neither be dropped in the room nor join it, they can watch it
+	if room.openToStrangers() {
+		t.Errorf("the match room must not be open to strangers")
+	}
+	if ce := room.admit("carol", RoomCredentials{}, false); ce == nil || ce.Status != http.StatusForbidden {
+		t.Errorf("expected a stranger to be refused, got %v", ce)
+	}
+	if ce := room.admit("carol", RoomCredentials{}, true); ce != nil {
+		t.Errorf("expected a stranger to watch, got %v", ce)
+	}
+}
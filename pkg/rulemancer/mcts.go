@@ -0,0 +1,232 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// mctsExploration weighs the exploration of the less visited moves against the best scoring ones in the UCT
+// formula
+const mctsExploration = 1.4
+
+// mctsNode is a position of the search tree, reached playing move from the parent position. The reward sums the
+// scores the seat of the move got in the playouts through the node.
+type mctsNode struct {
+	move     BotMove
+	facts    []string // the facts the move asserts
+	parent   *mctsNode
+	children []*mctsNode
+	untried  []BotMove // the moves not expanded yet, nil until the position is first reached
+	terminal bool
+	visits   int
+	reward   float64
+}
+
+// uct is the value of the node in the selection, the children of a node are all visited before any is selected
+func (n *mctsNode) uct() float64 {
+	return n.reward/float64(n.visits) + mctsExploration*math.Sqrt(math.Log(float64(n.parent.visits))/float64(n.visits))
+}
+
+// selectChild returns the child with the highest UCT value
+func (n *mctsNode) selectChild() *mctsNode {
+	var best *mctsNode
+	for _, child := range n.children {
+		if best == nil || child.uct() > best.uct() {
+			best = child
+		}
+	}
+	return best
+}
+
+// mostVisited returns the child played in the most playouts, the move chosen by the search
+func (n *mctsNode) mostVisited() *mctsNode {
+	var best *mctsNode
+	for _, child := range n.children {
+		if best == nil || child.visits > best.visits {
+			best = child
+		}
+	}
+	return best
+}
+
+// backpropagate adds the scores of a playout to the node and to its ancestors
+func (n *mctsNode) backpropagate(scores map[string]float64) {
+	for ; n != nil; n = n.parent {
+		n.visits++
+		if n.parent != nil {
+			n.reward += scores[n.move.Seat]
+		}
+	}
+}
+
+// mctsStrategy searches the moves with a Monte Carlo tree search, playing out random games in a clone of the room
+// game. The playouts are scored with the relations ending the game: a win for the seats they name.
+type mctsStrategy struct {
+	clone *gameClone
+	rnd   *rand.Rand
+}
+
+func newMCTSStrategy(e *Engine, room *Room) (BotStrategy, error) {
+	if len(room.game.endRelations) == 0 {
+		return nil, fmt.Errorf("game %s does not declare how it ends", room.game.id)
+	}
+	clone, err := e.newGameClone(room)
+	if err != nil {
+		return nil, err
+	}
+	return &mctsStrategy{clone: clone, rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}, nil
+}
+
+func (s *mctsStrategy) Move(ctx context.Context, room *Room, seat string) (BotMove, bool, error) {
+	facts, err := roomFacts(ctx, room)
+	if err != nil {
+		return BotMove{}, false, err
+	}
+
+	e := s.clone.e
+	root := &mctsNode{}
+	start := time.Now()
+	playouts := 0
+	if err := s.clone.ci.Do(ctx, func() error {
+		for playouts == 0 || playouts < e.MCTSPlayouts {
+			if e.MCTSTimeMs > 0 && playouts > 0 && time.Since(start) > time.Duration(e.MCTSTimeMs)*time.Millisecond {
+				break
+			}
+			if err := s.playoutAtomic(room, root, facts, seat); err != nil {
+				return err
+			}
+			playouts++
+			// A single legal move needs no search
+			if len(root.children)+len(root.untried) <= 1 {
+				break
+			}
+		}
+		return nil
+	}); err != nil {
+		return BotMove{}, false, err
+	}
+
+	best := root.mostVisited()
+	if best == nil {
+		return BotMove{}, false, nil
+	}
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/mctsStrategy]")+" ", 0)
+		l.Printf("Searched %d playouts in %v for %s in room %s, best move won %.1f of %d", playouts,
+			time.Since(start).Round(time.Millisecond), seat, room.id, best.reward, best.visits)
+	}
+	return best.move, true, nil
+}
+
+// playoutAtomic runs an iteration of the search: from the room facts it selects a position of the tree, expands it
+// with an untried move, plays random moves until the game ends or the depth limit and scores the result
+func (s *mctsStrategy) playoutAtomic(room *Room, root *mctsNode, facts []string, seat string) error {
+	if err := s.clone.restoreAtomic(facts); err != nil {
+		return err
+	}
+
+	// Selection
+	node := root
+	for {
+		if node.untried == nil {
+			if err := s.reachAtomic(node, seat, node == root); err != nil {
+				return err
+			}
+		}
+		if node.terminal || len(node.untried) > 0 || len(node.children) == 0 {
+			break
+		}
+		node = node.selectChild()
+		if err := s.clone.applyAtomic(node.facts); err != nil {
+			return err
+		}
+	}
+
+	// Expansion
+	if len(node.untried) > 0 {
+		i := s.rnd.Intn(len(node.untried))
+		move := node.untried[i]
+		node.untried = append(node.untried[:i], node.untried[i+1:]...)
+		moveFacts, ce := s.clone.e.prepareAssertion(room, "", move.Assertion, move.Payload)
+		if ce != nil {
+			return fmt.Errorf("invalid legal move: %s", ce.Message)
+		}
+		child := &mctsNode{move: move, facts: moveFacts, parent: node}
+		node.children = append(node.children, child)
+		node = child
+		if err := s.clone.applyAtomic(child.facts); err != nil {
+			return err
+		}
+	}
+
+	// Simulation, a game stopped by the depth limit or with nothing to play is a draw
+	ending, err := s.clone.endingAtomic()
+	if err != nil {
+		return err
+	}
+	for depth := 0; len(ending) == 0 && depth < s.clone.e.MCTSMaxDepth; depth++ {
+		mover, err := s.clone.turnAtomic()
+		if err != nil {
+			return err
+		}
+		moves, err := s.clone.movesAtomic(mover)
+		if err != nil {
+			return err
+		}
+		if len(moves) == 0 {
+			break
+		}
+		move := moves[s.rnd.Intn(len(moves))]
+		moveFacts, ce := s.clone.e.prepareAssertion(room, "", move.Assertion, move.Payload)
+		if ce != nil {
+			return fmt.Errorf("invalid legal move: %s", ce.Message)
+		}
+		if err := s.clone.applyAtomic(moveFacts); err != nil {
+			return err
+		}
+		if ending, err = s.clone.endingAtomic(); err != nil {
+			return err
+		}
+	}
+
+	// Backpropagation
+	node.backpropagate(seatScores(room.game.seats, room.game.teams, ending))
+	return nil
+}
+
+// reachAtomic reads the moves of a position the first time the search reaches it. At the root the moves are those
+// of the bot seat, elsewhere those of the seat to move.
+func (s *mctsStrategy) reachAtomic(node *mctsNode, seat string, root bool) error {
+	ending, err := s.clone.endingAtomic()
+	if err != nil {
+		return err
+	}
+	if len(ending) > 0 {
+		node.terminal = true
+		node.untried = []BotMove{}
+		return nil
+	}
+	if !root {
+		if seat, err = s.clone.turnAtomic(); err != nil {
+			return err
+		}
+	}
+	moves, err := s.clone.movesAtomic(seat)
+	if err != nil {
+		return err
+	}
+	node.untried = moves
+	return nil
+}
+
+func (s *mctsStrategy) Close() {
+	s.clone.Close()
+}
@@ -0,0 +1,69 @@
+package rulemancer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFactRelation(t *testing.T) {
+	tests := map[string]string{
+		"(legal-move (x 1) (y 2))": "legal-move",
+		"(initial-fact)":           "initial-fact",
+		" (turn (player x))":       "turn",
+	}
+	for fact, rel := range tests {
+		if got := factRelation(fact); got != rel {
+			t.Errorf("%s: expected %s, got %s", fact, rel, got)
+		}
+	}
+}
+
+func TestSeatScores(t *testing.T) {
+	seats := []string{"x", "o", "y"}
+	tests := []struct {
+		name   string
+		ending map[string][]map[string]string
+		scores map[string]float64
+	}{
+		{name: "unfinished", ending: nil, scores: map[string]float64{"x": 0.5, "o": 0.5, "y": 0.5}},
+		{name: "one winner", ending: map[string][]map[string]string{"winner": {{"player": "o"}}}, scores: map[string]float64{"x": 0, "o": 1, "y": 0}},
+		{name: "two winners", ending: map[string][]map[string]string{"winners": {{"players": "x y"}}}, scores: map[string]float64{"x": 1, "o": 0, "y": 1}},
+		{name: "everybody named", ending: map[string][]map[string]string{"tie": {{"players": "x o y"}}}, scores: map[string]float64{"x": 0.5, "o": 0.5, "y": 0.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if scores := seatScores(seats, nil, tt.ending); !reflect.DeepEqual(scores, tt.scores) {
+				t.Errorf("expected %v, got %v", tt.scores, scores)
+			}
+		})
+	}
+}
+
+func TestMCTSNode(t *testing.T) {
+	root := &mctsNode{}
+	good := &mctsNode{move: BotMove{Seat: "x"}, parent: root}
+	bad := &mctsNode{move: BotMove{Seat: "x"}, parent: root}
+	root.children = []*mctsNode{good, bad}
+
+	for i := 0; i < 10; i++ {
+		good.backpropagate(map[string]float64{"x": 1, "o": 0})
+		bad.backpropagate(map[string]float64{"x": 0, "o": 1})
+	}
+	if root.visits != 20 || good.visits != 10 || good.reward != 10 || bad.reward != 0 {
+		t.Fatalf("unexpected statistics: root %d, good %d/%v, bad %d/%v", root.visits, good.visits, good.reward, bad.visits, bad.reward)
+	}
+	if child := root.selectChild(); child != good {
+		t.Errorf("expected the winning move to be selected with equal visits")
+	}
+
+	// A move visited far less is explored even if it scored worse
+	for i := 0; i < 200; i++ {
+		good.backpropagate(map[string]float64{"x": 0.6})
+	}
+	if child := root.selectChild(); child != bad {
+		t.Errorf("expected the less visited move to be explored")
+	}
+	if child := root.mostVisited(); child != good {
+		t.Errorf("expected the most visited move to be played")
+	}
+}
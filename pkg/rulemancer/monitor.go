@@ -2,8 +2,10 @@ package rulemancer
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -57,7 +59,13 @@ func (e *Engine) Monitor(url string) error {
 				}
 				return
 			}
-			fmt.Println("server:", string(msg))
+			// Command results are JSON, indent them for the terminal
+			var out bytes.Buffer
+			if err := json.Indent(&out, msg, "", "  "); err == nil {
+				fmt.Println(out.String())
+			} else {
+				fmt.Println("server:", string(msg))
+			}
 		}
 	}()
 
@@ -83,27 +91,24 @@ func (e *Engine) Monitor(url string) error {
 
 func (e *Engine) systemMonitor(w http.ResponseWriter, r *http.Request) {
 
-	var upgrader = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			_, claims, err := jwtauth.FromContext(r.Context())
-			if err != nil {
-				if e.Debug {
-					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/systemMonitor]")+" ", 0)
-					l.Println("JWT error:", err)
-				}
-				return false
-			} else if clientID, ok := claims["id"].(string); !ok || clientID != "admin" {
-				if e.Debug {
-					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/systemMonitor]")+" ", 0)
-					l.Println("Unauthorized client ID:", clientID)
-				}
-				return false
-			}
-			return true
-		},
+	// Only the admin gets the system console, the origin of the upgrade is checked apart
+	if _, claims, err := jwtauth.FromContext(r.Context()); err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/systemMonitor]")+" ", 0)
+			l.Println("JWT error:", err)
+		}
+		Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	} else if clientID, ok := claims["id"].(string); !ok || clientID != "admin" {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/systemMonitor]")+" ", 0)
+			l.Println("Unauthorized client ID:", clientID)
+		}
+		Error(w, http.StatusUnauthorized, "unauthorized")
+		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := e.upgrader().Upgrade(w, r, nil)
 	if err != nil {
 		if e.Debug {
 			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/systemMonitor]")+" ", 0)
@@ -118,7 +123,13 @@ func (e *Engine) systemMonitor(w http.ResponseWriter, r *http.Request) {
 		l.Println("client connected")
 	}
 
+	// A peer that stops answering the pings is dropped by the reader
+	e.keepAlive(conn)
+	pings, stopPings := e.pingTicker()
+	defer stopPings()
+
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	wsIn := make(chan []byte)
 	wsOut := make(chan []byte)
@@ -145,6 +156,7 @@ func (e *Engine) systemMonitor(w http.ResponseWriter, r *http.Request) {
 				}
 				break loop
 			}
+			e.extendReadDeadline(conn)
 			select {
 			case <-ctx.Done():
 				break loop
@@ -169,7 +181,7 @@ func (e *Engine) systemMonitor(w http.ResponseWriter, r *http.Request) {
 			case <-ctx.Done():
 				break loop
 			case msg := <-wsOut:
-				err := conn.WriteMessage(websocket.TextMessage, msg)
+				err := e.writeMessage(conn, msg)
 				if err != nil {
 					if e.Debug {
 						l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/systemMonitor]")+" ", 0)
@@ -192,7 +204,11 @@ func (e *Engine) systemMonitor(w http.ResponseWriter, r *http.Request) {
 
 	// error handler
 	go func() {
-		<-wsErr
+		select {
+		case <-ctx.Done():
+			return
+		case <-wsErr:
+		}
 		if e.Debug {
 			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/systemMonitor]")+" ", 0)
 			l.Println("connection error, closing monitor")
@@ -204,12 +220,33 @@ func (e *Engine) systemMonitor(w http.ResponseWriter, r *http.Request) {
 		select {
 		case <-ctx.Done():
 			return
+		case <-pings:
+			if err := e.ping(conn); err != nil {
+				if e.Debug {
+					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/systemMonitor]")+" ", 0)
+					l.Println("ping error:", err)
+				}
+				return
+			}
 		case msg := <-wsIn:
 			if e.Debug {
 				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/systemMonitor]")+" ", 0)
 				l.Printf("websocket message received: %s\n", msg)
 			}
-			wsOut <- []byte("Message received, but repl not implemented")
+			result := e.replExec(ctx, string(msg))
+			answer, err := json.Marshal(result)
+			if err != nil {
+				if e.Debug {
+					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/systemMonitor]")+" ", 0)
+					l.Println("error encoding command result:", err)
+				}
+				answer = []byte(`{"status":"error","error":"failed to encode result"}`)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case wsOut <- answer:
+			}
 		}
 	}
 }
@@ -229,42 +266,34 @@ func (e *Engine) roomMonitor(w http.ResponseWriter, r *http.Request) {
 		room = r
 	}
 
-	var upgrader = websocket.Upgrader{
-		CheckOrigin: func(r *http.Request) bool {
-			_, claims, err := jwtauth.FromContext(r.Context())
-			if err != nil {
-				if e.Debug {
-					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/roomMonitor]")+" ", 0)
-					l.Println("JWT error:", err)
-				}
-				return false
-			} else if clientID, ok := claims["id"].(string); !ok {
-				if e.Debug {
-					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/roomMonitor]")+" ", 0)
-					l.Println("Unauthorized client ID:", clientID)
-				}
-				return false
-
-			} else {
-
-				canQuery := false
-				room.clientsMutex.RLock()
-				room.watchersMutex.RLock()
-				if _, ok := room.clients[clientID]; ok {
-					canQuery = true
-				}
-				if _, ok := room.watchers[clientID]; ok {
-					canQuery = true
-				}
-				room.watchersMutex.RUnlock()
-				room.clientsMutex.RUnlock()
+	// Only the players and the watchers get the room events, the commands are run on their behalf
+	requester := ""
+	if _, claims, err := jwtauth.FromContext(r.Context()); err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/roomMonitor]")+" ", 0)
+			l.Println("JWT error:", err)
+		}
+		Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	} else if clientID, ok := claims["id"].(string); !ok {
+		Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	} else {
+		requester = clientID
+	}
+	if ce := e.canQuery(room, requester); ce != nil {
+		CommandFailure(w, ce)
+		return
+	}
 
-				return canQuery
-			}
-		},
+	// A reconnecting client asks for the events following the last one it received
+	replay, since, err := parseSince(r)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "invalid since parameter")
+		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := e.upgrader().Upgrade(w, r, nil)
 	if err != nil {
 		if e.Debug {
 			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/roomMonitor]")+" ", 0)
@@ -273,15 +302,11 @@ func (e *Engine) roomMonitor(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	recvChan := make(socketChan)
-
-	room.socketsMutex.Lock()
-	room.sockets[conn] = recvChan
-	room.socketsMutex.Unlock()
+	sock, backlog, resync, seq := room.events.subscribe(conn.RemoteAddr().String(), requester, replay, since)
+	e.roomConnected(room, requester)
 	defer func() {
-		room.socketsMutex.Lock()
-		delete(room.sockets, conn)
-		room.socketsMutex.Unlock()
+		room.events.unsubscribe(sock)
+		e.roomDisconnected(room, requester)
 		conn.Close()
 	}()
 
@@ -290,7 +315,13 @@ func (e *Engine) roomMonitor(w http.ResponseWriter, r *http.Request) {
 		l.Println("client connected")
 	}
 
+	// A peer that stops answering the pings is dropped by the reader
+	e.keepAlive(conn)
+	pings, stopPings := e.pingTicker()
+	defer stopPings()
+
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	wsIn := make(chan []byte)
 	wsOut := make(chan []byte)
@@ -317,6 +348,7 @@ func (e *Engine) roomMonitor(w http.ResponseWriter, r *http.Request) {
 				}
 				break loop
 			}
+			e.extendReadDeadline(conn)
 			select {
 			case <-ctx.Done():
 				break loop
@@ -341,7 +373,7 @@ func (e *Engine) roomMonitor(w http.ResponseWriter, r *http.Request) {
 			case <-ctx.Done():
 				break loop
 			case msg := <-wsOut:
-				err := conn.WriteMessage(websocket.TextMessage, msg)
+				err := e.writeMessage(conn, msg)
 				if err != nil {
 					if e.Debug {
 						l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/roomMonitor]")+" ", 0)
@@ -364,7 +396,11 @@ func (e *Engine) roomMonitor(w http.ResponseWriter, r *http.Request) {
 
 	// error handler
 	go func() {
-		<-wsErr
+		select {
+		case <-ctx.Done():
+			return
+		case <-wsErr:
+		}
 		if e.Debug {
 			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/roomMonitor]")+" ", 0)
 			l.Println("connection error, closing monitor for room", id)
@@ -372,22 +408,106 @@ func (e *Engine) roomMonitor(w http.ResponseWriter, r *http.Request) {
 		cancel()
 	}()
 
+	// lastSeq is the sequence number of the last event sent, an event is never sent twice
+	lastSeq := seq
+	if replay {
+		lastSeq = since
+	}
+
+	snapshot := func(ctx context.Context) (any, error) {
+		return e.roomSnapshot(ctx, room)
+	}
+
+	// catchUp sends the missed events, or a snapshot of the room when they are no longer in the event ring
+	catchUp := func(backlog []socketMessage, resync bool, seq uint64) bool {
+		messages, err := room.events.catchUp(ctx, snapshot, lastSeq, backlog, resync, seq)
+		if err != nil {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/roomMonitor]")+" ", 0)
+				l.Printf("snapshot of room %s failed: %v\n", id, err)
+			}
+			return false
+		}
+		for _, msg := range messages {
+			select {
+			case <-ctx.Done():
+				return false
+			case wsOut <- msg.message:
+			}
+			lastSeq = msg.seq
+		}
+		return true
+	}
+
+	if replay {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/roomMonitor]")+" ", 0)
+			l.Printf("replaying room %s events since %d (resync %v)\n", id, since, resync)
+		}
+		if !catchUp(backlog, resync, seq) {
+			cancel()
+			return
+		}
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-sock.closed:
+			// The client was kicked
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/roomMonitor]")+" ", 0)
+				l.Printf("closing the websocket of %s on room %s\n", requester, id)
+			}
+			return
+		case <-pings:
+			if err := e.ping(conn); err != nil {
+				if e.Debug {
+					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/roomMonitor]")+" ", 0)
+					l.Println("ping error:", err)
+				}
+				return
+			}
+		case <-sock.lagged:
+			// The socket channel was full and some events were not queued, they are taken from the event ring
+			backlog, resync, seq := room.events.eventsSince(lastSeq)
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/roomMonitor]")+" ", 0)
+				l.Printf("socket of room %s lagged after event %d (resync %v)\n", id, lastSeq, resync)
+			}
+			if !catchUp(backlog, resync, seq) {
+				cancel()
+				return
+			}
 		case msg := <-wsIn:
 			if e.Debug {
 				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/roomMonitor]")+" ", 0)
 				l.Printf("websocket message received for room %s: %s\n", id, msg)
 			}
-			wsOut <- []byte("Message received, but repl not implemented")
-		case msg := <-recvChan:
+			room.touch(requester)
+			// Commands are run one at a time, in the order they are received
+			response := e.execRoomCommand(ctx, room, requester, msg)
+			select {
+			case <-ctx.Done():
+				return
+			case wsOut <- response:
+			}
+		case msg := <-sock.ch:
+			if msg.seq <= lastSeq {
+				// Already sent by a replay
+				continue
+			}
 			if e.Debug {
 				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/roomMonitor]")+" ", 0)
-				l.Printf("message received from room %s: %s\n", id, msg)
+				l.Printf("message received from room %s: %s\n", id, msg.message)
+			}
+			lastSeq = msg.seq
+			select {
+			case <-ctx.Done():
+				return
+			case wsOut <- msg.message:
 			}
-			wsOut <- msg.message
 		}
 	}
 }
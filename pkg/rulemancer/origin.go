@@ -0,0 +1,40 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// checkOrigin accepts the websocket upgrades from the server own origin, from the configured allowed origins and
+// from the clients that send no origin, which are not browsers. It does not authenticate anything.
+func (e *Engine) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range e.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/checkOrigin]")+" ", 0)
+		l.Printf("Websocket upgrade refused to origin %s", origin)
+	}
+	return false
+}
+
+func (e *Engine) upgrader() *websocket.Upgrader {
+	return &websocket.Upgrader{CheckOrigin: e.checkOrigin}
+}
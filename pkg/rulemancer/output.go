@@ -0,0 +1,55 @@
+package rulemancer
+
+import "strings"
+
+// OutputLine is a line printed by the game rules on a CLIPS logical name, the standard output logical name
+// (t or stdout) is reported as "t"
+type OutputLine struct {
+	Channel string `json:"channel"`
+	Text    string `json:"text"`
+}
+
+// parseOutput decodes the output captured by the CLIPS output router. Every write is a record terminated by
+// \x1e with the logical name and the text separated by \x1f. Writes are joined per logical name and split
+// into lines, a trailing line without newline is reported as well.
+func parseOutput(raw string) []OutputLine {
+	lines := make([]OutputLine, 0)
+	pending := make(map[string]*strings.Builder)
+	order := make([]string, 0)
+
+	for _, record := range strings.Split(raw, "\x1e") {
+		fields := strings.SplitN(record, "\x1f", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		channel := fields[0]
+		if channel == "stdout" {
+			channel = "t"
+		}
+
+		buf, ok := pending[channel]
+		if !ok {
+			buf = &strings.Builder{}
+			pending[channel] = buf
+			order = append(order, channel)
+		}
+		buf.WriteString(fields[1])
+
+		text := buf.String()
+		if idx := strings.LastIndex(text, "\n"); idx >= 0 {
+			for _, line := range strings.Split(text[:idx], "\n") {
+				lines = append(lines, OutputLine{Channel: channel, Text: line})
+			}
+			buf.Reset()
+			buf.WriteString(text[idx+1:])
+		}
+	}
+
+	for _, channel := range order {
+		if text := pending[channel].String(); text != "" {
+			lines = append(lines, OutputLine{Channel: channel, Text: text})
+		}
+	}
+
+	return lines
+}
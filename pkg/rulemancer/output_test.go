@@ -0,0 +1,55 @@
+package rulemancer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []OutputLine
+	}{
+		{
+			name:     "empty",
+			input:    "",
+			expected: []OutputLine{},
+		},
+		{
+			name:  "printout split across writes",
+			input: "stdout\x1fPlayer \x1e" + "stdout\x1fx\x1e" + "stdout\x1f wins!\x1e" + "stdout\x1f\n\x1e",
+			expected: []OutputLine{
+				{Channel: "t", Text: "Player x wins!"},
+			},
+		},
+		{
+			name:  "custom logical names and trailing text",
+			input: "narration\x1fThe dragon wakes up\n\x1e" + "stdout\x1fturn 1\nturn 2\n\x1e" + "narration\x1fno newline\x1e",
+			expected: []OutputLine{
+				{Channel: "narration", Text: "The dragon wakes up"},
+				{Channel: "t", Text: "turn 1"},
+				{Channel: "t", Text: "turn 2"},
+				{Channel: "narration", Text: "no newline"},
+			},
+		},
+		{
+			name:  "empty lines are kept",
+			input: "t\x1fa\n\nb\n\x1e",
+			expected: []OutputLine{
+				{Channel: "t", Text: "a"},
+				{Channel: "t", Text: ""},
+				{Channel: "t", Text: "b"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := parseOutput(tt.input)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("parseOutput() = %+v, want %+v", result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Relation of the facts telling the rules the parameters of a room, asserted before the first run
+const roomParamRelation = "room-param"
+
+// GameParam is a room creation parameter declared by a game with a game-param fact. Default and Allowed hold
+// CLIPS values, strings with their quotes.
+type GameParam struct {
+	Name        string   `json:"name"`
+	Type        string   `json:"type"` // INTEGER, FLOAT, SYMBOL or STRING
+	Default     string   `json:"default"`
+	Allowed     []string `json:"allowed,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// RoomSettings are the optional settings of a room chosen by the client creating it
+type RoomSettings struct {
+	RoomAccess
+	Params map[string]json.RawMessage `json:"params"` // values of the game parameters, by name
+}
+
+// readRoomSettings decodes the optional settings of a request creating a room, an empty body gives the defaults
+func readRoomSettings(r *http.Request) (RoomSettings, error) {
+	var settings RoomSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil && !errors.Is(err, io.EOF) {
+		return settings, err
+	}
+	return settings, nil
+}
+
+// FieldErrors are the problems found in a request, reported to the client field by field
+type FieldErrors []FieldError
+
+func (fe FieldErrors) Error() string {
+	messages := make([]string, 0, len(fe))
+	for _, f := range fe {
+		messages = append(messages, f.Path+": "+f.Message)
+	}
+	return "invalid settings: " + strings.Join(messages, ", ")
+}
+
+// parseGameParams reads the game-param facts, one per parameter. Each fact is parsed on its own, an empty
+// allowed multislot is not printed with a value.
+func parseGameParams(c *Config, raw string) ([]GameParam, error) {
+	params := make([]GameParam, 0)
+	for _, fact := range factsList(raw) {
+		facts, err := genericFactToMap(c, "game-param", fact)
+		if err != nil {
+			return nil, err
+		}
+		for _, slots := range facts {
+			param := GameParam{
+				Name:        slots["name"],
+				Type:        slots["type"],
+				Default:     slots["default"],
+				Allowed:     quotedSplit(slots["allowed"]),
+				Description: strings.Join(factsSplit(slots["description"]), " "),
+			}
+			if param.Name == "" || param.Name == "nil" {
+				return nil, errors.New("game-param missing name slot")
+			}
+			for _, other := range params {
+				if other.Name == param.Name {
+					return nil, fmt.Errorf("game-param %s declared twice", param.Name)
+				}
+			}
+			if param.Type == "" || param.Type == "nil" {
+				param.Type = "SYMBOL"
+			}
+			switch param.Type {
+			case "INTEGER", "FLOAT", "SYMBOL", "STRING":
+			default:
+				return nil, fmt.Errorf("game-param %s type must be one of INTEGER, FLOAT, SYMBOL, STRING", param.Name)
+			}
+			if param.Default == "" || param.Default == "nil" {
+				return nil, fmt.Errorf("game-param %s missing default slot", param.Name)
+			}
+			if msg := param.check(param.Default); msg != "" {
+				return nil, fmt.Errorf("game-param %s default %s", param.Name, msg)
+			}
+			for _, allowed := range param.Allowed {
+				if allowedType := clipsValueType(allowed); allowedType != param.Type && !(allowedType == "INTEGER" && param.Type == "FLOAT") {
+					return nil, fmt.Errorf("game-param %s allowed value %s is not a %s", param.Name, allowed, param.Type)
+				}
+			}
+			params = append(params, param)
+		}
+	}
+	sort.Slice(params, func(i, j int) bool { return params[i].Name < params[j].Name })
+	return params, nil
+}
+
+// check verifies a CLIPS value against the parameter, it returns an empty string when the value is acceptable
+func (p GameParam) check(value string) string {
+	valueType := clipsValueType(value)
+	if valueType == "INTEGER" && p.Type == "FLOAT" {
+		valueType = "FLOAT"
+	}
+	if valueType != p.Type {
+		return "must be a " + strings.ToLower(p.Type)
+	}
+	if len(p.Allowed) > 0 && !isInSlice(p.Allowed, value) {
+		return "must be one of " + strings.Join(p.Allowed, " ")
+	}
+	return ""
+}
+
+// clipsLiteral turns the JSON value given for the parameter into a CLIPS value, numbers can be given as
+// numbers or strings, strings are quoted for STRING parameters
+func (p GameParam) clipsLiteral(raw json.RawMessage) (string, string) {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", "must be a JSON number or string"
+	}
+	switch p.Type {
+	case "INTEGER", "FLOAT":
+		var text string
+		switch v := value.(type) {
+		case float64:
+			text = strconv.FormatFloat(v, 'f', -1, 64)
+		case string:
+			text = strings.TrimSpace(v)
+		default:
+			return "", "must be a " + strings.ToLower(p.Type)
+		}
+		if p.Type == "INTEGER" {
+			if n, err := strconv.ParseInt(text, 10, 64); err != nil {
+				return "", "must be an integer"
+			} else {
+				return strconv.FormatInt(n, 10), ""
+			}
+		}
+		if f, err := strconv.ParseFloat(text, 64); err != nil {
+			return "", "must be a float"
+		} else {
+			literal := strconv.FormatFloat(f, 'f', -1, 64)
+			if !strings.ContainsAny(literal, ".eE") {
+				literal += ".0"
+			}
+			return literal, ""
+		}
+	case "STRING":
+		if s, ok := value.(string); !ok {
+			return "", "must be a string"
+		} else {
+			return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`, ""
+		}
+	default:
+		if s, ok := value.(string); !ok || s == "" || strings.ContainsAny(s, " \t\r\n()\"&|~;<>?$") || clipsValueType(s) != "SYMBOL" {
+			return "", "must be a symbol"
+		} else {
+			return s, ""
+		}
+	}
+}
+
+// resolveParams checks the values given for the game parameters and returns the value of every parameter, the
+// default for those not given
+func (g *Game) resolveParams(values map[string]json.RawMessage) (map[string]string, FieldErrors) {
+	fields := make(FieldErrors, 0)
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		found := false
+		for _, param := range g.params {
+			found = found || param.Name == name
+		}
+		if !found {
+			fields = append(fields, FieldError{Path: "params." + name, Message: "unknown parameter"})
+		}
+	}
+
+	resolved := make(map[string]string, len(g.params))
+	for _, param := range g.params {
+		raw, given := values[param.Name]
+		if !given {
+			resolved[param.Name] = param.Default
+			continue
+		}
+		if literal, msg := param.clipsLiteral(raw); msg != "" {
+			fields = append(fields, FieldError{Path: "params." + param.Name, Message: msg})
+		} else if msg := param.check(literal); msg != "" {
+			fields = append(fields, FieldError{Path: "params." + param.Name, Message: msg})
+		} else {
+			resolved[param.Name] = literal
+		}
+	}
+	if len(fields) > 0 {
+		return nil, fields
+	}
+	return resolved, nil
+}
+
+// paramFacts returns the facts telling the rules the parameters of a room, sorted by name
+func paramFacts(params map[string]string) []string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	facts := make([]string, 0, len(names))
+	for _, name := range names {
+		facts = append(facts, "("+roomParamRelation+" (name "+name+") (value "+params[name]+"))")
+	}
+	return facts
+}
@@ -0,0 +1,98 @@
+package rulemancer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseGameParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		facts  string
+		params []GameParam
+		failed bool
+	}{
+		{name: "none", facts: "", params: []GameParam{}},
+		{
+			name:  "typed with allowed values",
+			facts: `(game-param (name starting-life) (type INTEGER) (default 20) (description "Life total") (allowed 10 20 30)) (game-param (name house-rules) (type SYMBOL) (default none) (description "") (allowed))`,
+			params: []GameParam{
+				{Name: "house-rules", Type: "SYMBOL", Default: "none"},
+				{Name: "starting-life", Type: "INTEGER", Default: "20", Allowed: []string{"10", "20", "30"}, Description: "Life total"},
+			},
+		},
+		{name: "string default", facts: `(game-param (name title) (type STRING) (default "Friday night") (description "") (allowed))`, params: []GameParam{{Name: "title", Type: "STRING", Default: `"Friday night"`}}},
+		{name: "float accepts integers", facts: `(game-param (name speed) (type FLOAT) (default 1) (description "") (allowed 1 1.5))`, params: []GameParam{{Name: "speed", Type: "FLOAT", Default: "1", Allowed: []string{"1", "1.5"}}}},
+		{name: "missing default", facts: `(game-param (name size) (type INTEGER) (default nil) (description "") (allowed))`, failed: true},
+		{name: "default not allowed", facts: `(game-param (name size) (type INTEGER) (default 5) (description "") (allowed 3 4))`, failed: true},
+		{name: "default of the wrong type", facts: `(game-param (name size) (type INTEGER) (default big) (description "") (allowed))`, failed: true},
+		{name: "unknown type", facts: `(game-param (name size) (type BOOLEAN) (default yes) (description "") (allowed))`, failed: true},
+		{name: "declared twice", facts: `(game-param (name size) (type INTEGER) (default 3) (description "") (allowed)) (game-param (name size) (type INTEGER) (default 4) (description "") (allowed))`, failed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := parseGameParams(NewConfig(), tt.facts)
+			if tt.failed {
+				if err == nil {
+					t.Errorf("expected an error, got %v", params)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, _ := json.Marshal(params)
+			want, _ := json.Marshal(tt.params)
+			if string(got) != string(want) {
+				t.Errorf("expected %s, got %s", want, got)
+			}
+		})
+	}
+}
+
+func TestResolveParams(t *testing.T) {
+	game := &Game{params: []GameParam{
+		{Name: "label", Type: "STRING", Default: `"none"`},
+		{Name: "speed", Type: "FLOAT", Default: "1.0"},
+		{Name: "starting-life", Type: "INTEGER", Default: "20", Allowed: []string{"10", "20", "30"}},
+		{Name: "variant", Type: "SYMBOL", Default: "classic"},
+	}}
+
+	tests := []struct {
+		name   string
+		values string
+		facts  string
+		fields []string
+	}{
+		{name: "defaults", values: `{}`, facts: `(room-param (name label) (value "none"))|(room-param (name speed) (value 1.0))|(room-param (name starting-life) (value 20))|(room-param (name variant) (value classic))`},
+		{name: "given", values: `{"label": "say \"hi\"", "speed": 2, "starting-life": "30", "variant": "chaos"}`, facts: `(room-param (name label) (value "say \"hi\""))|(room-param (name speed) (value 2.0))|(room-param (name starting-life) (value 30))|(room-param (name variant) (value chaos))`},
+		{name: "not allowed", values: `{"starting-life": 25}`, fields: []string{"params.starting-life"}},
+		{name: "not an integer", values: `{"starting-life": 2.5}`, fields: []string{"params.starting-life"}},
+		{name: "not a symbol", values: `{"variant": "two words"}`, fields: []string{"params.variant"}},
+		{name: "symbol as number", values: `{"variant": "42"}`, fields: []string{"params.variant"}},
+		{name: "not a string", values: `{"label": 4}`, fields: []string{"params.label"}},
+		{name: "unknown", values: `{"board": 4, "speed": "fast"}`, fields: []string{"params.board", "params.speed"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var values map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(tt.values), &values); err != nil {
+				t.Fatalf("invalid test values: %v", err)
+			}
+			params, fields := game.resolveParams(values)
+			paths := make([]string, 0)
+			for _, f := range fields {
+				paths = append(paths, f.Path)
+			}
+			if strings.Join(paths, ",") != strings.Join(tt.fields, ",") {
+				t.Fatalf("expected fields %v, got %v", tt.fields, fields)
+			}
+			if got := strings.Join(paramFacts(params), "|"); len(tt.fields) == 0 && got != tt.facts {
+				t.Errorf("expected %s, got %s", tt.facts, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Party is a group of clients joining the rooms together, its leader takes the seats for everybody. In team
+// games the party plays for a single team.
+type Party struct {
+	id      string
+	leader  string
+	members []string // clients in joining order, the leader first
+	created int64
+	mutex   sync.Mutex
+}
+
+func (p *Party) Info() map[string]any {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	members := make([]string, len(p.members))
+	copy(members, p.members)
+	return map[string]any{
+		"id":      p.id,
+		"leader":  p.leader,
+		"members": members,
+		"created": p.created,
+	}
+}
+
+// newParty creates a party led by a client, a client is a member of a single party at a time
+func (e *Engine) newParty(leader string) (*Party, *CommandError) {
+	e.partiesMutex.Lock()
+	defer e.partiesMutex.Unlock()
+	if p := e.clientParty(leader); p != nil {
+		return nil, &CommandError{Status: http.StatusConflict, Message: "client already in party " + p.id}
+	}
+	p := &Party{
+		id:      e.generatePartyUniqueID(),
+		leader:  leader,
+		members: []string{leader},
+		created: time.Now().Unix(),
+		mutex:   sync.Mutex{},
+	}
+	e.parties[p.id] = p
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/newParty]")+" ", 0)
+		l.Printf("Party %s created by %s", p.id, leader)
+	}
+	return p, nil
+}
+
+func (e *Engine) generatePartyUniqueID() string {
+	for {
+		newId := randStringBytes(16)
+		if _, exists := e.parties[newId]; !exists {
+			return newId
+		}
+	}
+}
+
+func (e *Engine) searchParty(id string) (*Party, error) {
+	e.partiesMutex.RLock()
+	defer e.partiesMutex.RUnlock()
+	if p, exists := e.parties[id]; exists {
+		return p, nil
+	}
+	return nil, errors.New("party not found")
+}
+
+// clientParty returns the party of a client, nil when it is in none. The caller holds partiesMutex.
+func (e *Engine) clientParty(clientID string) *Party {
+	for _, p := range e.parties {
+		p.mutex.Lock()
+		member := isInSlice(p.members, clientID)
+		p.mutex.Unlock()
+		if member {
+			return p
+		}
+	}
+	return nil
+}
+
+// joinParty adds a client to the members of a party
+func (e *Engine) joinParty(p *Party, clientID string) *CommandError {
+	e.partiesMutex.Lock()
+	defer e.partiesMutex.Unlock()
+	if other := e.clientParty(clientID); other == p {
+		return &CommandError{Status: http.StatusConflict, Message: "client already in party"}
+	} else if other != nil {
+		return &CommandError{Status: http.StatusConflict, Message: "client already in party " + other.id}
+	}
+	if _, exists := e.parties[p.id]; !exists {
+		return &CommandError{Status: http.StatusNotFound, Message: "party not found"}
+	}
+	p.mutex.Lock()
+	p.members = append(p.members, clientID)
+	p.mutex.Unlock()
+	return nil
+}
+
+// leaveParty removes a client from the members of a party, the party is disbanded when its leader leaves
+func (e *Engine) leaveParty(p *Party, clientID string) *CommandError {
+	e.partiesMutex.Lock()
+	defer e.partiesMutex.Unlock()
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !isInSlice(p.members, clientID) {
+		return &CommandError{Status: http.StatusNotFound, Message: "client not in party"}
+	}
+	if clientID == p.leader {
+		delete(e.parties, p.id)
+		p.members = nil
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/leaveParty]")+" ", 0)
+			l.Printf("Party %s disbanded by its leader %s", p.id, clientID)
+		}
+		return nil
+	}
+	members := make([]string, 0, len(p.members)-1)
+	for _, member := range p.members {
+		if member != clientID {
+			members = append(members, member)
+		}
+	}
+	p.members = members
+	return nil
+}
+
+// partySeats chooses the seats of the members of a party in a room, all on the free seats of a single team in
+// team games. The caller holds clientsMutex.
+func (r *Room) partySeats(size int) ([]string, *CommandError) {
+	free := r.freeSeats()
+	if len(r.game.teams) == 0 {
+		if len(free) < size {
+			return nil, &CommandError{Status: http.StatusForbidden, Message: "not enough free seats for the party"}
+		}
+		return free[:size], nil
+	}
+	for _, team := range r.game.teams {
+		seats := make([]string, 0, len(team.Seats))
+		for _, seat := range free {
+			if isInSlice(team.Seats, seat) {
+				seats = append(seats, seat)
+			}
+		}
+		if len(seats) >= size {
+			return seats[:size], nil
+		}
+	}
+	return nil, &CommandError{Status: http.StatusForbidden, Message: "no team has enough free seats for the party"}
+}
+
+// seatParty makes every member of a party a player of a room, or none of them
+func (e *Engine) seatParty(room *Room, p *Party) *CommandError {
+	p.mutex.Lock()
+	members := make([]string, len(p.members))
+	copy(members, p.members)
+	p.mutex.Unlock()
+	if len(members) == 0 {
+		return &CommandError{Status: http.StatusNotFound, Message: "party not found"}
+	}
+
+	clients := make([]*Client, 0, len(members))
+	for _, clientID := range members {
+		if client, err := e.searchClient(clientID); err != nil {
+			return &CommandError{Status: http.StatusNotFound, Message: "client not found: " + clientID}
+		} else {
+			clients = append(clients, client)
+		}
+	}
+
+	// Locks in the order of seatClient
+	game := room.game
+	game.roomsMutex.Lock()
+	defer game.roomsMutex.Unlock()
+	room.clientsMutex.Lock()
+	defer room.clientsMutex.Unlock()
+	for _, client := range clients {
+		if _, exists := room.clients[client.id]; exists {
+			return &CommandError{Status: http.StatusConflict, Message: "client already in room: " + client.id}
+		}
+	}
+	seats, ce := room.partySeats(len(clients))
+	if ce != nil {
+		return ce
+	}
+	if _, ok := game.partialRooms[room.id]; !ok {
+		return &CommandError{Status: http.StatusNotFound, Message: "room not found in game's partial rooms"}
+	}
+
+	for i, client := range clients {
+		client.roomsMutex.Lock()
+		if len(room.clients) == room.maxClients-1 {
+			delete(game.partialRooms, room.id)
+			game.runningRooms[room.id] = room
+		}
+
+		room.watchersMutex.Lock()
+		delete(room.watchers, client.id)
+		room.watchersMutex.Unlock()
+		client.watchersMutex.Lock()
+		delete(client.watchingRooms, room.id)
+		client.watchersMutex.Unlock()
+
+		room.seatPlayer(client, seats[i])
+		client.playingRooms[room.id] = room
+		client.roomsMutex.Unlock()
+	}
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/seatParty]")+" ", 0)
+		l.Printf("Party %s joined room %s on seats %v", p.id, room.id, seats)
+	}
+	return nil
+}
+
+// availablePartyRoom returns the first room of a game open to strangers with seats for a party, nil if none
+func (e *Engine) availablePartyRoom(game *Game, size int) *Room {
+	game.roomsMutex.Lock()
+	rooms := make([]*Room, 0, len(game.partialRooms))
+	for _, r := range game.partialRooms {
+		if r.openToStrangers() {
+			rooms = append(rooms, r)
+		}
+	}
+	game.roomsMutex.Unlock()
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].id < rooms[j].id })
+
+	for _, r := range rooms {
+		r.clientsMutex.RLock()
+		_, ce := r.partySeats(size)
+		r.clientsMutex.RUnlock()
+		if ce == nil {
+			return r
+		}
+	}
+	return nil
+}
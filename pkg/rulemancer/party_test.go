@@ -0,0 +1,108 @@
+package rulemancer
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPartyMembers(t *testing.T) {
+	e := NewEngine("secret")
+	p, ce := e.newParty("alice")
+	if ce != nil {
+		t.Fatal(ce)
+	}
+	if _, ce := e.newParty("alice"); ce == nil || ce.Status != http.StatusConflict {
+		t.Errorf("a client leads a single party, got %v", ce)
+	}
+	if ce := e.joinParty(p, "bob"); ce != nil {
+		t.Fatal(ce)
+	}
+	if ce := e.joinParty(p, "bob"); ce == nil || ce.Status != http.StatusConflict {
+		t.Errorf("a client joins a party once, got %v", ce)
+	}
+	if ce := e.leaveParty(p, "carol"); ce == nil || ce.Status != http.StatusNotFound {
+		t.Errorf("expected carol not to be found, got %v", ce)
+	}
+	if ce := e.leaveParty(p, "bob"); ce != nil {
+		t.Fatal(ce)
+	}
+	if members := p.Info()["members"].([]string); len(members) != 1 || members[0] != "alice" {
+		t.Errorf("expected alice alone, got %v", members)
+	}
+
+	// The leader leaving disbands the party
+	if ce := e.leaveParty(p, "alice"); ce != nil {
+		t.Fatal(ce)
+	}
+	if _, err := e.searchParty(p.id); err == nil {
+		t.Errorf("expected the party to be disbanded")
+	}
+}
+
+func TestSeatParty(t *testing.T) {
+	tests := []struct {
+		name    string
+		seated  []string // players already in the room
+		members []string
+		seats   map[string]string
+		status  int
+	}{
+		{
+			name:    "first team",
+			members: []string{"north", "south"},
+			seats:   map[string]string{"north": "n", "south": "s"},
+		},
+		{
+			name:    "team with free seats",
+			seated:  []string{"solo"},
+			members: []string{"east", "west"},
+			seats:   map[string]string{"east": "e", "west": "w"},
+		},
+		{name: "no team has room", seated: []string{"solo", "other"}, members: []string{"east", "west"}, status: http.StatusForbidden},
+		{name: "too large", members: []string{"a", "b", "c"}, status: http.StatusForbidden},
+		{name: "already in room", seated: []string{"north"}, members: []string{"north"}, status: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, room, seated := newTeamTestRoom(t, tt.seated...)
+			clients := make(map[string]*Client)
+			for _, client := range seated {
+				clients[client.name] = client
+			}
+			var p *Party
+			for _, name := range tt.members {
+				client, ok := clients[name]
+				if !ok {
+					client = e.newClient(name, "")
+					clients[name] = client
+				}
+				if p == nil {
+					p, _ = e.newParty(client.id)
+				} else if ce := e.joinParty(p, client.id); ce != nil {
+					t.Fatal(ce)
+				}
+			}
+
+			ce := e.seatParty(room, p)
+			if tt.status != 0 {
+				if ce == nil || ce.Status != tt.status {
+					t.Errorf("expected status %d, got %v", tt.status, ce)
+				}
+				if len(room.clients) != len(tt.seated) {
+					t.Errorf("expected nobody of the party to be seated, got %v", room.seatsInfo())
+				}
+				return
+			}
+			if ce != nil {
+				t.Fatalf("unexpected error: %v", ce)
+			}
+			seats := room.seatsInfo()
+			for name, seat := range tt.seats {
+				if seats[clients[name].id] != seat {
+					t.Errorf("expected %s on seat %s, got %v", name, seat, seats)
+				}
+			}
+		})
+	}
+}
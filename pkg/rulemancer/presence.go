@@ -0,0 +1,135 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// Presence states of the players and the watchers of a room
+const (
+	PresenceOnline  = "online"  // connected and active
+	PresenceAway    = "away"    // connected but inactive for longer than presence_away_ms
+	PresenceOffline = "offline" // no websocket or event stream open on the room
+)
+
+// presenceCheckInterval is how often the connected clients are checked for inactivity
+const presenceCheckInterval = 5 * time.Second
+
+type presence struct {
+	connections int       // websockets and event streams open by the client on the room
+	lastSeen    time.Time // last command or action sent by the client
+	state       string
+}
+
+// setPresence changes the state of a client, the other clients are told with a presence event. The caller holds
+// presenceMutex, so that the events are sent in the order the state changes.
+func (r *Room) setPresence(clientID string, p *presence, state string) {
+	if p.state == state {
+		return
+	}
+	p.state = state
+	r.emit(EventPresence, clientID, map[string]string{"client": clientID, "state": state})
+}
+
+// connected records a websocket or an event stream opened by a client on the room, it tells whether the client
+// was offline
+func (r *Room) connected(clientID string) bool {
+	if clientID == "" {
+		return false
+	}
+	r.presenceMutex.Lock()
+	defer r.presenceMutex.Unlock()
+	if r.presence == nil {
+		r.presence = make(map[string]*presence)
+	}
+	p, ok := r.presence[clientID]
+	if !ok {
+		p = &presence{state: PresenceOffline}
+		r.presence[clientID] = p
+	}
+	p.connections++
+	p.lastSeen = time.Now()
+	r.setPresence(clientID, p, PresenceOnline)
+	return p.connections == 1
+}
+
+// disconnected records a websocket or an event stream closed, the client is offline when it was the last one,
+// and then it returns true
+func (r *Room) disconnected(clientID string) bool {
+	r.presenceMutex.Lock()
+	defer r.presenceMutex.Unlock()
+	if p, ok := r.presence[clientID]; ok {
+		p.connections--
+		if p.connections <= 0 {
+			delete(r.presence, clientID)
+			r.setPresence(clientID, p, PresenceOffline)
+			return true
+		}
+	}
+	return false
+}
+
+// touch records an activity of a client, an away client is online again
+func (r *Room) touch(clientID string) {
+	r.presenceMutex.Lock()
+	defer r.presenceMutex.Unlock()
+	if p, ok := r.presence[clientID]; ok {
+		p.lastSeen = time.Now()
+		r.setPresence(clientID, p, PresenceOnline)
+	}
+}
+
+// checkAway marks as away the connected clients that have been inactive since before the given time
+func (r *Room) checkAway(before time.Time) {
+	r.presenceMutex.Lock()
+	defer r.presenceMutex.Unlock()
+	for clientID, p := range r.presence {
+		if p.state == PresenceOnline && p.lastSeen.Before(before) {
+			r.setPresence(clientID, p, PresenceAway)
+		}
+	}
+}
+
+// presenceStates returns the state of each of the given clients
+func (r *Room) presenceStates(clientIDs []string) map[string]string {
+	r.presenceMutex.Lock()
+	defer r.presenceMutex.Unlock()
+	states := make(map[string]string, len(clientIDs))
+	for _, clientID := range clientIDs {
+		if p, ok := r.presence[clientID]; ok {
+			states[clientID] = p.state
+		} else {
+			states[clientID] = PresenceOffline
+		}
+	}
+	return states
+}
+
+// watchPresence periodically marks as away the inactive clients of all the rooms
+func (e *Engine) watchPresence() {
+	if e.PresenceAwayMs <= 0 {
+		return
+	}
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/watchPresence]")+" ", 0)
+		l.Printf("clients inactive for %d ms are shown as away", e.PresenceAwayMs)
+	}
+	ticker := time.NewTicker(presenceCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		before := time.Now().Add(-time.Duration(e.PresenceAwayMs) * time.Millisecond)
+		e.roomsMutex.RLock()
+		rooms := make([]*Room, 0, len(e.rooms))
+		for _, room := range e.rooms {
+			rooms = append(rooms, room)
+		}
+		e.roomsMutex.RUnlock()
+		for _, room := range rooms {
+			room.checkAway(before)
+		}
+	}
+}
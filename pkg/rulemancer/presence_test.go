@@ -0,0 +1,53 @@
+package rulemancer
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRoomPresence(t *testing.T) {
+	room, ch := newEventsTestRoom(&Game{})
+	room.clients["alice"] = &Client{id: "alice"}
+	room.watchers["bob"] = &Client{id: "bob"}
+
+	steps := []struct {
+		name   string
+		action func()
+		events []string // states carried by the presence events, in order
+		alice  string
+		bob    string
+	}{
+		{name: "nobody connected", action: func() {}, alice: PresenceOffline, bob: PresenceOffline},
+		{name: "alice connects", action: func() { room.connected("alice") }, events: []string{PresenceOnline}, alice: PresenceOnline, bob: PresenceOffline},
+		{name: "second connection", action: func() { room.connected("alice") }, alice: PresenceOnline, bob: PresenceOffline},
+		{name: "alice idles", action: func() { room.checkAway(time.Now().Add(time.Second)) }, events: []string{PresenceAway}, alice: PresenceAway, bob: PresenceOffline},
+		{name: "still idle", action: func() { room.checkAway(time.Now().Add(time.Second)) }, alice: PresenceAway, bob: PresenceOffline},
+		{name: "alice acts", action: func() { room.touch("alice") }, events: []string{PresenceOnline}, alice: PresenceOnline, bob: PresenceOffline},
+		{name: "recent activity", action: func() { room.checkAway(time.Now().Add(-time.Minute)) }, alice: PresenceOnline, bob: PresenceOffline},
+		{name: "offline client acts", action: func() { room.touch("bob") }, alice: PresenceOnline, bob: PresenceOffline},
+		{name: "one connection closed", action: func() { room.disconnected("alice") }, alice: PresenceOnline, bob: PresenceOffline},
+		{name: "last connection closed", action: func() { room.disconnected("alice") }, events: []string{PresenceOffline}, alice: PresenceOffline, bob: PresenceOffline},
+		{name: "unknown disconnection", action: func() { room.disconnected("bob") }, alice: PresenceOffline, bob: PresenceOffline},
+	}
+
+	for _, step := range steps {
+		step.action()
+		events := receivedEvents(t, ch)
+		if len(events) != len(step.events) {
+			t.Fatalf("%s: expected %d events, got %d", step.name, len(step.events), len(events))
+		}
+		for i, event := range events {
+			var payload map[string]string
+			raw, _ := json.Marshal(event.Payload)
+			json.Unmarshal(raw, &payload)
+			if event.Type != EventPresence || payload["client"] != "alice" || payload["state"] != step.events[i] {
+				t.Errorf("%s: unexpected event %+v", step.name, event)
+			}
+		}
+		states := room.presenceStates([]string{"alice", "bob"})
+		if states["alice"] != step.alice || states["bob"] != step.bob {
+			t.Errorf("%s: unexpected states %v", step.name, states)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"log"
+	"math"
+	"os"
+	"sync"
+)
+
+// Rating is the Elo rating of a client in a game
+type Rating struct {
+	Rating float64 `json:"rating"`
+	Games  int     `json:"games"`
+}
+
+// RatingChange is the new rating of a player after a game and how much it moved
+type RatingChange struct {
+	Rating float64 `json:"rating"`
+	Delta  float64 `json:"delta"`
+}
+
+// ratingStore keeps the ratings of the clients, by game
+type ratingStore struct {
+	ratings map[string]map[string]*Rating
+	mutex   sync.RWMutex
+}
+
+func newRatingStore() *ratingStore {
+	return &ratingStore{
+		ratings: make(map[string]map[string]*Rating),
+		mutex:   sync.RWMutex{},
+	}
+}
+
+// get returns the rating of a client in a game, the initial one for the clients that never played it
+func (rs *ratingStore) get(gameID, clientID string, initial float64) Rating {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+	if rating, ok := rs.ratings[gameID][clientID]; ok {
+		return *rating
+	}
+	return Rating{Rating: initial}
+}
+
+// update applies the scores of a game to the ratings of its players and returns the changes
+func (rs *ratingStore) update(gameID string, scores map[string]float64, k, initial float64) map[string]RatingChange {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	if rs.ratings[gameID] == nil {
+		rs.ratings[gameID] = make(map[string]*Rating)
+	}
+	ratings := make(map[string]float64, len(scores))
+	for clientID := range scores {
+		if rating, ok := rs.ratings[gameID][clientID]; ok {
+			ratings[clientID] = rating.Rating
+		} else {
+			ratings[clientID] = initial
+		}
+	}
+
+	changes := make(map[string]RatingChange, len(scores))
+	for clientID, delta := range eloDeltas(ratings, scores, k) {
+		rating, ok := rs.ratings[gameID][clientID]
+		if !ok {
+			rating = &Rating{Rating: initial}
+			rs.ratings[gameID][clientID] = rating
+		}
+		rating.Rating += delta
+		rating.Games++
+		changes[clientID] = RatingChange{Rating: rating.Rating, Delta: delta}
+	}
+	return changes
+}
+
+// eloDeltas returns the rating change of each player. A game between several players counts as a game between
+// each pair of them, the player with the higher score winning the pair, and the changes are averaged over the
+// opponents.
+func eloDeltas(ratings map[string]float64, scores map[string]float64, k float64) map[string]float64 {
+	deltas := make(map[string]float64, len(ratings))
+	if len(ratings) < 2 {
+		return deltas
+	}
+	for a, ra := range ratings {
+		sum := 0.0
+		for b, rb := range ratings {
+			if a == b {
+				continue
+			}
+			expected := 1 / (1 + math.Pow(10, (rb-ra)/400))
+			actual := 0.5
+			if scores[a] > scores[b] {
+				actual = 1
+			} else if scores[a] < scores[b] {
+				actual = 0
+			}
+			sum += actual - expected
+		}
+		deltas[a] = k * sum / float64(len(ratings)-1)
+	}
+	return deltas
+}
+
+// seatScores returns the score of each seat from the facts that ended the game: the seats appearing in a slot of
+// those facts, or playing for a team appearing there, won and score 1, the others lost and score 0. When no seat
+// or every seat appears the game is a draw and every seat scores 0.5.
+func seatScores(seats []string, teams []GameTeam, ending map[string][]map[string]string) map[string]float64 {
+	named := make(map[string]bool)
+	for _, facts := range ending {
+		for _, fact := range facts {
+			for _, value := range fact {
+				for _, item := range factsSplit(value) {
+					named[item] = true
+				}
+			}
+		}
+	}
+	for _, team := range teams {
+		if named[team.Name] {
+			for _, seat := range team.Seats {
+				named[seat] = true
+			}
+		}
+	}
+
+	winners := 0
+	for _, seat := range seats {
+		if named[seat] {
+			winners++
+		}
+	}
+	scores := make(map[string]float64, len(seats))
+	for _, seat := range seats {
+		switch {
+		case winners == 0 || winners == len(seats):
+			scores[seat] = 0.5
+		case named[seat]:
+			scores[seat] = 1
+		default:
+			scores[seat] = 0
+		}
+	}
+	return scores
+}
+
+// gameScores returns the score of each player from the facts that ended the game, the score of its seat. Nil is
+// returned when the room has fewer than two players.
+func (r *Room) gameScores(ending map[string][]map[string]string) map[string]float64 {
+	r.clientsMutex.RLock()
+	defer r.clientsMutex.RUnlock()
+	if len(r.clients) < 2 {
+		return nil
+	}
+
+	seats := make([]string, 0, len(r.clients))
+	for clientID := range r.clients {
+		seats = append(seats, r.seats[clientID])
+	}
+	bySeat := seatScores(seats, r.game.teams, ending)
+	scores := make(map[string]float64, len(r.clients))
+	for clientID := range r.clients {
+		scores[clientID] = bySeat[r.seats[clientID]]
+	}
+	return scores
+}
+
+// rateGame updates the ratings of the players of a room whose game just ended with the given scores, the changes
+// are sent to the room
+func (e *Engine) rateGame(room *Room, actor string, scores map[string]float64) {
+	if scores == nil {
+		return
+	}
+	changes := e.ratings.update(room.game.id, scores, e.EloKFactor, e.InitialRating)
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/rateGame]")+" ", 0)
+		l.Printf("Ratings updated in room %s: %v", room.id, changes)
+	}
+	room.emit(EventRatingsUpdated, actor, map[string]any{"ratings": changes})
+}
@@ -0,0 +1,119 @@
+package rulemancer
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEloDeltas(t *testing.T) {
+	tests := []struct {
+		name    string
+		ratings map[string]float64
+		scores  map[string]float64
+		deltas  map[string]float64
+	}{
+		{name: "even win", ratings: map[string]float64{"a": 1500, "b": 1500}, scores: map[string]float64{"a": 1, "b": 0}, deltas: map[string]float64{"a": 16, "b": -16}},
+		{name: "even draw", ratings: map[string]float64{"a": 1500, "b": 1500}, scores: map[string]float64{"a": 0.5, "b": 0.5}, deltas: map[string]float64{"a": 0, "b": 0}},
+		{name: "upset", ratings: map[string]float64{"a": 1100, "b": 1500}, scores: map[string]float64{"a": 1, "b": 0}, deltas: map[string]float64{"a": 29.09, "b": -29.09}},
+		{name: "expected win", ratings: map[string]float64{"a": 1900, "b": 1500}, scores: map[string]float64{"a": 1, "b": 0}, deltas: map[string]float64{"a": 2.91, "b": -2.91}},
+		{name: "three players", ratings: map[string]float64{"a": 1500, "b": 1500, "c": 1500}, scores: map[string]float64{"a": 1, "b": 0, "c": 0}, deltas: map[string]float64{"a": 16, "b": -8, "c": -8}},
+		{name: "single player", ratings: map[string]float64{"a": 1500}, scores: map[string]float64{"a": 1}, deltas: map[string]float64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deltas := eloDeltas(tt.ratings, tt.scores, 32)
+			if len(deltas) != len(tt.deltas) {
+				t.Fatalf("expected %v, got %v", tt.deltas, deltas)
+			}
+			for id, expected := range tt.deltas {
+				if math.Abs(deltas[id]-expected) > 0.01 {
+					t.Errorf("%s: expected %.2f, got %.2f", id, expected, deltas[id])
+				}
+			}
+		})
+	}
+}
+
+func TestRatingStore(t *testing.T) {
+	rs := newRatingStore()
+	if rating := rs.get("tictactoe", "alice", 1500); rating.Rating != 1500 || rating.Games != 0 {
+		t.Errorf("unexpected initial rating %+v", rating)
+	}
+	changes := rs.update("tictactoe", map[string]float64{"alice": 1, "bob": 0}, 32, 1500)
+	if changes["alice"].Rating != 1516 || changes["alice"].Delta != 16 || changes["bob"].Rating != 1484 {
+		t.Errorf("unexpected changes %v", changes)
+	}
+	if rating := rs.get("tictactoe", "alice", 1500); rating.Rating != 1516 || rating.Games != 1 {
+		t.Errorf("unexpected rating %+v", rating)
+	}
+	if rating := rs.get("chess", "alice", 1500); rating.Rating != 1500 {
+		t.Errorf("ratings are by game, got %+v", rating)
+	}
+}
+
+func TestGameScores(t *testing.T) {
+	room, _ := newEventsTestRoom(&Game{seats: []string{"x", "o"}})
+	room.maxClients = 2
+
+	if scores := room.gameScores(nil); scores != nil {
+		t.Errorf("an empty room has no scores, got %v", scores)
+	}
+	room.addPlayer(&Client{id: "alice"})
+	room.addPlayer(&Client{id: "bob"})
+
+	tests := []struct {
+		name   string
+		ending map[string][]map[string]string
+		alice  float64
+		bob    float64
+	}{
+		{name: "x wins", ending: map[string][]map[string]string{"winner": {{"player": "x"}}}, alice: 1, bob: 0},
+		{name: "o wins", ending: map[string][]map[string]string{"winner": {{"player": "o"}}}, alice: 0, bob: 1},
+		{name: "draw", ending: map[string][]map[string]string{"draw": {{}}}, alice: 0.5, bob: 0.5},
+		{name: "everybody named", ending: map[string][]map[string]string{"tie": {{"players": "x o"}}}, alice: 0.5, bob: 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores := room.gameScores(tt.ending)
+			if scores["alice"] != tt.alice || scores["bob"] != tt.bob {
+				t.Errorf("unexpected scores %v", scores)
+			}
+		})
+	}
+}
+
+func TestParseGameSeats(t *testing.T) {
+	tests := []struct {
+		name   string
+		facts  []map[string]string
+		seats  string
+		failed bool
+	}{
+		{name: "numbered", facts: nil, seats: "1 2 3"},
+		{name: "named", facts: []map[string]string{{"players": "x o y"}}, seats: "x o y"},
+		{name: "too few", facts: []map[string]string{{"players": "x o"}}, failed: true},
+		{name: "twice", facts: []map[string]string{{"players": "x x o"}}, failed: true},
+		{name: "multiple facts", facts: []map[string]string{{"players": "x o y"}, {"players": "a b c"}}, failed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seats, err := parseGameSeats(tt.facts, 3)
+			if tt.failed {
+				if err == nil {
+					t.Errorf("expected an error, got %v", seats)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := strings.Join(seats, " "); got != tt.seats {
+				t.Errorf("expected %q, got %q", tt.seats, got)
+			}
+		})
+	}
+}
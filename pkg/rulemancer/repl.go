@@ -0,0 +1,422 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ReplResult is the answer of the system websocket to a command, Status is "ok" or "error"
+type ReplResult struct {
+	Command string `json:"command"`
+	Status  string `json:"status"`
+	Result  any    `json:"result,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// replCommand is a command of the admin REPL. The command takes args arguments, the last of which takes the
+// rest of the line, and the last optional ones may be omitted.
+type replCommand struct {
+	usage    string
+	help     string
+	args     int
+	optional int
+	run      func(e *Engine, ctx context.Context, args []string) (any, error)
+}
+
+func replCommands() map[string]replCommand {
+	return map[string]replCommand{
+		"games": {
+			usage: "games",
+			help:  "list the served games",
+			run: func(e *Engine, ctx context.Context, args []string) (any, error) {
+				return e.listGames(), nil
+			},
+		},
+		"game": {
+			usage: "game <id>",
+			help:  "inspect a game",
+			args:  1,
+			run: func(e *Engine, ctx context.Context, args []string) (any, error) {
+				if game, err := e.searchGame(args[0]); err != nil {
+					return nil, err
+				} else {
+					return game.Info(), nil
+				}
+			},
+		},
+		"rooms": {
+			usage: "rooms",
+			help:  "list the running rooms",
+			run: func(e *Engine, ctx context.Context, args []string) (any, error) {
+				return e.listRooms(), nil
+			},
+		},
+		"room": {
+			usage: "room <id>",
+			help:  "inspect a room",
+			args:  1,
+			run: func(e *Engine, ctx context.Context, args []string) (any, error) {
+				if room, err := e.searchRoom(args[0]); err != nil {
+					return nil, err
+				} else {
+					return room.Info(), nil
+				}
+			},
+		},
+		"clients": {
+			usage: "clients",
+			help:  "list the registered clients",
+			run: func(e *Engine, ctx context.Context, args []string) (any, error) {
+				return e.listClients(), nil
+			},
+		},
+		"client": {
+			usage: "client <id>",
+			help:  "inspect a client",
+			args:  1,
+			run: func(e *Engine, ctx context.Context, args []string) (any, error) {
+				if client, err := e.searchClient(args[0]); err != nil {
+					return nil, err
+				} else {
+					return client.Info(), nil
+				}
+			},
+		},
+		"bridges": {
+			usage: "bridges",
+			help:  "list the bridges and their running bridge rooms",
+			run: func(e *Engine, ctx context.Context, args []string) (any, error) {
+				return map[string][]string{
+					"bridges":  e.listBridges(),
+					"br_rooms": e.listBrRooms(),
+				}, nil
+			},
+		},
+		"bridge": {
+			usage: "bridge <id>",
+			help:  "inspect a bridge",
+			args:  1,
+			run: func(e *Engine, ctx context.Context, args []string) (any, error) {
+				if bridge, err := e.searchBridge(args[0]); err != nil {
+					return nil, err
+				} else {
+					return bridge.Info(), nil
+				}
+			},
+		},
+		"kick": {
+			usage:    "kick <client> [room]",
+			help:     "remove a client from a room, or from every room and the engine when no room is given",
+			args:     2,
+			optional: 1,
+			run: func(e *Engine, ctx context.Context, args []string) (any, error) {
+				roomID := ""
+				if len(args) > 1 {
+					roomID = args[1]
+				}
+				if rooms, err := e.kickClient(args[0], roomID); err != nil {
+					return nil, err
+				} else {
+					return map[string]any{"kicked": args[0], "rooms": rooms}, nil
+				}
+			},
+		},
+		"delete-room": {
+			usage: "delete-room <id>",
+			help:  "delete a room and dispose its CLIPS environment",
+			args:  1,
+			run: func(e *Engine, ctx context.Context, args []string) (any, error) {
+				if _, err := e.removeRoom(args[0]); err != nil {
+					return nil, err
+				}
+				return map[string]string{"deleted": args[0]}, nil
+			},
+		},
+		"facts": {
+			usage:    "facts <room> [relation]",
+			help:     "dump the facts of a room or bridge room, optionally only those of a relation",
+			args:     2,
+			optional: 1,
+			run: func(e *Engine, ctx context.Context, args []string) (any, error) {
+				ci, _, err := e.replClipsInstance(args[0])
+				if err != nil {
+					return nil, err
+				}
+				var facts string
+				if err := ci.Do(ctx, func() error {
+					var err error
+					if len(args) > 1 {
+						facts, err = ci.QueryFactsAtomic(strings.TrimSpace(args[1]))
+					} else {
+						facts, err = ci.QueryFactsAllFactsAtomic()
+					}
+					return err
+				}); err != nil {
+					return nil, err
+				}
+				return factsList(facts), nil
+			},
+		},
+		"assert": {
+			usage: "assert <room> <fact>",
+			help:  "assert a fact in a room or bridge room and run the rules",
+			args:  2,
+			run: func(e *Engine, ctx context.Context, args []string) (any, error) {
+				return e.replAssert(ctx, args[0], args[1])
+			},
+		},
+		"eval": {
+			usage: "eval <room> <expression>",
+			help:  "evaluate a CLIPS expression in a room or bridge room",
+			args:  2,
+			run: func(e *Engine, ctx context.Context, args []string) (any, error) {
+				return e.replEval(ctx, args[0], args[1])
+			},
+		},
+	}
+}
+
+// splitReplLine splits a command line in at most n fields separated by white spaces, the last field takes the
+// rest of the line
+func splitReplLine(line string, n int) []string {
+	fields := make([]string, 0, n)
+	line = strings.TrimSpace(line)
+	for line != "" && len(fields) < n-1 {
+		if i := strings.IndexAny(line, " \t"); i < 0 {
+			fields = append(fields, line)
+			line = ""
+		} else {
+			fields = append(fields, line[:i])
+			line = strings.TrimSpace(line[i:])
+		}
+	}
+	if line != "" {
+		fields = append(fields, line)
+	}
+	return fields
+}
+
+// replExec executes a command line of the admin REPL
+func (e *Engine) replExec(ctx context.Context, line string) ReplResult {
+	commands := replCommands()
+
+	fields := splitReplLine(line, 2)
+	if len(fields) == 0 {
+		return ReplResult{Status: "error", Error: "empty command, try help"}
+	}
+	name := fields[0]
+	result := ReplResult{Command: name, Status: "ok"}
+
+	if name == "help" {
+		usages := make([]map[string]string, 0, len(commands))
+		for _, cmd := range commands {
+			usages = append(usages, map[string]string{"usage": cmd.usage, "help": cmd.help})
+		}
+		sort.Slice(usages, func(i, j int) bool { return usages[i]["usage"] < usages[j]["usage"] })
+		result.Result = usages
+		return result
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		result.Status = "error"
+		result.Error = "unknown command " + name + ", try help"
+		return result
+	}
+
+	args := []string{}
+	if len(fields) > 1 {
+		args = splitReplLine(fields[1], cmd.args)
+	}
+	if len(args) < cmd.args-cmd.optional || len(args) > cmd.args {
+		result.Status = "error"
+		result.Error = "usage: " + cmd.usage
+		return result
+	}
+
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/replExec]")+" ", 0)
+		l.Printf("Executing command %s with arguments %v", name, args)
+	}
+
+	if res, err := cmd.run(e, ctx, args); err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/replExec]")+" ", 0)
+			l.Printf("Command %s failed: %v", name, err)
+		}
+		result.Status = "error"
+		result.Error = err.Error()
+	} else {
+		result.Result = res
+	}
+	return result
+}
+
+// replClipsInstance returns the CLIPS instance of a room or a bridge room, the room is nil for bridge rooms
+func (e *Engine) replClipsInstance(id string) (*ClipsInstance, *Room, error) {
+	if e.ClipsLessMode {
+		return nil, nil, errors.New("CLIPS is disabled")
+	}
+	if room, err := e.searchRoom(id); err == nil {
+		return room.clipsInstance, room, nil
+	}
+	if brRoom, err := e.searchBrRoom(id); err == nil {
+		return brRoom.clipsInstance, nil, nil
+	}
+	return nil, nil, errors.New("room or bridge room not found")
+}
+
+// replAssert asserts a fact on behalf of the admin and runs the rules, in game rooms the fact and the rules
+// output reach the room sockets as for any other assertion
+func (e *Engine) replAssert(ctx context.Context, id, fact string) (any, error) {
+	ci, room, err := e.replClipsInstance(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var output []OutputLine
+	var state map[string]string
+	var diff map[string]factsDiff
+	err = ci.Do(ctx, func() error {
+		if err := ci.AssertFactAtomic(fact); err != nil {
+			return err
+		}
+		runErr := ci.RunAtomic()
+		output, _ = ci.TakeOutputAtomic()
+		if runErr == nil && room != nil {
+			state, runErr = room.queryStateAtomic()
+		}
+		if runErr == nil && room != nil {
+			diff, runErr = room.stateDiffAtomic()
+		}
+		return runErr
+	})
+	if errors.Is(err, ErrClipsBusy) || errors.Is(err, ErrClipsDisposed) {
+		return nil, err
+	}
+
+	if room != nil {
+		room.logAction(ActionLogEntry{Kind: "assert", Actor: "admin", Text: fact})
+		e.publishAssertion(room, "admin", "", []string{fact}, output, nil, diff, state)
+		if err != nil && e.CorruptOnRunLimit && (errors.Is(err, ErrRulesLimit) || errors.Is(err, ErrRunTimeout)) {
+			room.markCorrupted(err.Error())
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"asserted": fact, "output": output}, nil
+}
+
+// replEval evaluates a CLIPS expression, the result and what CLIPS printed are returned to the admin only
+func (e *Engine) replEval(ctx context.Context, id, expr string) (any, error) {
+	ci, room, err := e.replClipsInstance(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var value string
+	var output []OutputLine
+	var evalErr error
+	if err := ci.Do(ctx, func() error {
+		value, evalErr = ci.EvalAtomic(expr)
+		output, _ = ci.TakeOutputAtomic()
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if room != nil {
+		room.logAction(ActionLogEntry{Kind: "eval", Actor: "admin", Text: expr})
+	}
+	if evalErr != nil {
+		// CLIPS explains what went wrong in its error output
+		msgs := make([]string, 0, len(output))
+		for _, line := range output {
+			msgs = append(msgs, strings.TrimSpace(line.Text))
+		}
+		return nil, fmt.Errorf("%w: %s", evalErr, strings.Join(msgs, " "))
+	}
+	return map[string]any{"value": value, "output": output}, nil
+}
+
+// kickClient removes a client from a room, or from every room it plays or watches and from the engine when
+// roomID is empty. Its websockets and event streams on the rooms are closed first, so that it does not get the
+// events of its own kick, and so are the lobby and tournament ones when it is removed from the engine. It
+// returns the rooms the client was removed from.
+func (e *Engine) kickClient(clientID, roomID string) ([]string, error) {
+	client, err := e.searchClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	rooms := make(map[string]*Room)
+	client.roomsMutex.RLock()
+	for id, room := range client.playingRooms {
+		rooms[id] = room
+	}
+	client.roomsMutex.RUnlock()
+	client.watchersMutex.RLock()
+	for id, room := range client.watchingRooms {
+		rooms[id] = room
+	}
+	client.watchersMutex.RUnlock()
+
+	if roomID != "" {
+		if room, ok := rooms[roomID]; !ok {
+			return nil, errors.New("client not in room")
+		} else {
+			rooms = map[string]*Room{roomID: room}
+		}
+	}
+
+	kicked := make([]string, 0, len(rooms))
+	for id, room := range rooms {
+		room.events.closeClient(clientID)
+		room.logAction(ActionLogEntry{Kind: "kick", Actor: "admin", Text: clientID})
+		e.leaveRoom(room, client, "admin", "kicked")
+
+		room.watchersMutex.Lock()
+		_, watching := room.watchers[clientID]
+		delete(room.watchers, clientID)
+		room.watchersMutex.Unlock()
+		client.watchersMutex.Lock()
+		delete(client.watchingRooms, id)
+		client.watchersMutex.Unlock()
+		if watching {
+			room.emit(EventWatcherLeft, "admin", map[string]string{"client": clientID, "reason": "kicked"})
+		}
+		kicked = append(kicked, id)
+	}
+	sort.Strings(kicked)
+
+	if roomID == "" {
+		e.lobby.closeClient(clientID)
+		e.tournamentsMutex.RLock()
+		for _, t := range e.tournaments {
+			t.events.closeClient(clientID)
+		}
+		e.tournamentsMutex.RUnlock()
+
+		// A kicked bot removes its client itself once it stopped
+		if !client.bot {
+			if _, err := e.removeClient(clientID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/kickClient]")+" ", 0)
+		l.Printf("Client %s kicked from rooms %v", clientID, kicked)
+	}
+	return kicked, nil
+}
@@ -0,0 +1,163 @@
+package rulemancer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSplitReplLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		n        int
+		expected []string
+	}{
+		{
+			name:     "empty line",
+			line:     "   ",
+			n:        2,
+			expected: []string{},
+		},
+		{
+			name:     "single field",
+			line:     "rooms",
+			n:        2,
+			expected: []string{"rooms"},
+		},
+		{
+			name:     "rest of the line",
+			line:     "assert  abc   (move (x 1) (y 2))",
+			n:        3,
+			expected: []string{"assert", "abc", "(move (x 1) (y 2))"},
+		},
+		{
+			name:     "fewer fields than allowed",
+			line:     "facts abc",
+			n:        3,
+			expected: []string{"facts", "abc"},
+		},
+		{
+			name:     "tabs as separators",
+			line:     "kick\tbob\troom1",
+			n:        3,
+			expected: []string{"kick", "bob", "room1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := splitReplLine(tt.line, tt.n)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestReplExec(t *testing.T) {
+	e := NewEngine("secret")
+	client := e.newClient("alice", "")
+
+	tests := []struct {
+		name    string
+		line    string
+		status  string
+		command string
+	}{
+		{name: "empty command", line: "", status: "error"},
+		{name: "help", line: "help", status: "ok", command: "help"},
+		{name: "unknown command", line: "frobnicate", status: "error", command: "frobnicate"},
+		{name: "list rooms", line: "rooms", status: "ok", command: "rooms"},
+		{name: "extra arguments", line: "rooms all", status: "error", command: "rooms"},
+		{name: "missing argument", line: "room", status: "error", command: "room"},
+		{name: "room not found", line: "room nowhere", status: "error", command: "room"},
+		{name: "inspect client", line: "client " + client.id, status: "ok", command: "client"},
+		{name: "kick from a room not joined", line: "kick " + client.id + " nowhere", status: "error", command: "kick"},
+		{name: "kick client", line: "kick " + client.id, status: "ok", command: "kick"},
+		{name: "kicked client is gone", line: "client " + client.id, status: "error", command: "client"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := e.replExec(context.Background(), tt.line)
+			if result.Status != tt.status {
+				t.Errorf("expected status %s, got %s (%s)", tt.status, result.Status, result.Error)
+			}
+			if result.Command != tt.command {
+				t.Errorf("expected command %q, got %q", tt.command, result.Command)
+			}
+		})
+	}
+}
+
+func TestKickClient(t *testing.T) {
+	game := duelTestGame()
+	e, room, clients := newTestRoom(t, game, "alice")
+	alice := clients[0]
+	bot := &Bot{client: e.newBotClient("bot-random", ""), room: room, strategy: "random", stop: make(chan struct{})}
+	if ce := e.seatClient(room, bot.client); ce != nil {
+		t.Fatal(ce)
+	}
+	room.bots = map[string]*Bot{bot.client.id: bot}
+	sub, _, _, _ := room.events.subscribe("test", "", false, 0)
+	aliceSub, _, _, _ := room.events.subscribe("alice", alice.id, false, 0)
+	aliceLobby, _, _, _ := e.lobby.subscribe("alice", alice.id, false, 0)
+
+	if kicked, err := e.kickClient(alice.id, room.id); err != nil || !reflect.DeepEqual(kicked, []string{room.id}) {
+		t.Fatalf("unexpected kick result %v, %v", kicked, err)
+	}
+	if event := waitEvent(t, sub.ch, EventPlayerLeft); event.Actor != "admin" {
+		t.Errorf("expected the admin to kick, got %+v", event)
+	}
+	select {
+	case <-aliceSub.closed:
+	default:
+		t.Errorf("the room streams of the kicked client must be closed")
+	}
+	if len(aliceSub.ch) != 0 {
+		t.Errorf("the kicked client must not get the events of its kick, got %d", len(aliceSub.ch))
+	}
+	select {
+	case <-aliceLobby.closed:
+		t.Errorf("a kick from a single room must leave the lobby stream open")
+	default:
+	}
+	if _, ok := game.partialRooms[room.id]; !ok {
+		t.Errorf("the room must be joinable again after a kick")
+	}
+	if _, playing := alice.playingRooms[room.id]; playing {
+		t.Errorf("the room must be forgotten by the kicked client")
+	}
+	carol := e.newClient("carol", "")
+	if ce := e.seatClient(room, carol); ce != nil {
+		t.Fatalf("the freed seat cannot be taken: %v", ce)
+	}
+	if room.seats[carol.id] != "x" {
+		t.Errorf("expected carol on the freed seat, got %v", room.seats)
+	}
+
+	// A kicked bot stops playing
+	if _, err := e.kickClient(bot.client.id, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case <-bot.stop:
+	default:
+		t.Errorf("the kicked bot must be stopped")
+	}
+	if _, ok := room.bots[bot.client.id]; ok {
+		t.Errorf("the kicked bot must leave the room bots")
+	}
+
+	// A client kicked from the engine loses the lobby stream too
+	carolLobby, _, _, _ := e.lobby.subscribe("carol", carol.id, false, 0)
+	if _, err := e.kickClient(carol.id, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	select {
+	case <-carolLobby.closed:
+	default:
+		t.Errorf("the lobby stream of a client kicked from the engine must be closed")
+	}
+}
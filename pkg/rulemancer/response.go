@@ -17,3 +17,26 @@ func JSON(w http.ResponseWriter, code int, v any) {
 func Error(w http.ResponseWriter, code int, msg string) {
 	JSON(w, code, map[string]string{"error": msg})
 }
+
+func ValidationError(w http.ResponseWriter, fields []FieldError) {
+	JSON(w, http.StatusBadRequest, map[string]any{"error": "invalid payload", "fields": fields})
+}
+
+// ClipsError maps an error returned by a CLIPS job to a response: 503 when the instance is busy, 422 when the
+// rules exceed their run limits and 500 with the failure message otherwise
+func ClipsError(w http.ResponseWriter, err error, failure string) {
+	CommandFailure(w, clipsCommandError(err, failure))
+}
+
+// CommandFailure writes the response of a failed room command
+func CommandFailure(w http.ResponseWriter, ce *CommandError) {
+	switch {
+	case len(ce.Fields) > 0:
+		ValidationError(w, ce.Fields)
+	case ce.Status == http.StatusServiceUnavailable:
+		w.Header().Set("Retry-After", "1")
+		Error(w, ce.Status, ce.Message)
+	default:
+		Error(w, ce.Status, ce.Message)
+	}
+}
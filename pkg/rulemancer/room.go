@@ -4,73 +4,226 @@ Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
 package rulemancer
 
 import (
+	"context"
 	"errors"
 	"sync"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
-type socketMessage struct {
-	message []byte
+// ActionLogEntry is an entry of the room action log, Kind is "assert" for facts asserted by clients, "output"
+// for lines printed by the game rules, "end" when the game ends, "eval" or "kick" for the admin interventions
+// and "handoff" when a client or a bot takes the seat of a disconnected player
+type ActionLogEntry struct {
+	Time    int64  `json:"time"`
+	Kind    string `json:"kind"`
+	Actor   string `json:"actor,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	Text    string `json:"text"`
 }
 
-type socketChan chan socketMessage
-
 type Room struct {
-	name          string
-	description   string
-	id            string
-	game          *Game
-	clients       map[string]*Client
-	maxClients    int
-	clientsMutex  sync.RWMutex
-	watchers      map[string]*Client
-	watchersMutex sync.RWMutex
-	sockets       map[*websocket.Conn]socketChan
-	socketsMutex  sync.RWMutex
-	clipsInstance *ClipsInstance
-	lastActive    int64
-}
-
-func (r *Room) socketsInfo() []string {
-	r.socketsMutex.RLock()
-	defer r.socketsMutex.RUnlock()
-	sockets := make([]string, 0, len(r.sockets))
-	for conn := range r.sockets {
-		sockets = append(sockets, conn.RemoteAddr().String())
-	}
-	return sockets
-}
-
-func (r *Room) broadcast(message []byte) {
-	r.socketsMutex.RLock()
-	defer r.socketsMutex.RUnlock()
-	for conn := range r.sockets {
-		select {
-		case r.sockets[conn] <- socketMessage{message: message}:
-		default:
-			// TODO If the channel is blocked, we can choose to drop the message or handle it differently
-		}
+	name            string
+	description     string
+	id              string
+	game            *Game
+	clients         map[string]*Client
+	maxClients      int
+	clientsMutex    sync.RWMutex
+	watchers        map[string]*Client
+	watchersMutex   sync.RWMutex
+	clipsInstance   *ClipsInstance
+	lastActive      int64
+	created         int64             // unix time of the creation
+	seats           map[string]string // seat of each player, guarded by clientsMutex
+	actionLog       []ActionLogEntry
+	actionLogSize   int
+	actionLogMutex  sync.RWMutex
+	corrupted       string // why the room state can no longer be trusted, empty for healthy rooms
+	corruptedMutex  sync.RWMutex
+	events          *eventHub                   // room events and their subscribers
+	lobby           *eventHub                   // where the changes of the room are announced
+	factIndex       map[string]map[int64]string // queryable facts by fact index as of the last state diff, CLIPS jobs only
+	ended           bool                        // a relation ending the game has facts
+	endedMutex      sync.RWMutex
+	presence        map[string]*presence // clients with a websocket or an event stream open on the room
+	presenceMutex   sync.Mutex
+	visibility      string // public, unlisted or private, the access settings never change after the creation
+	watch           string // who can watch the room
+	owner           string // the client that created the room
+	invite          string // invite code, opens the room whatever its visibility and password
+	passwordSalt    []byte
+	passwordHash    []byte            // nil when the room has no password
+	params          map[string]string // values of the game parameters, as asserted in the room-param facts
+	clock           *roomClock        // nil when the game has no clock
+	bots            map[string]*Bot   // bots playing in the room by client id, guarded by clientsMutex
+	tournament      *Tournament       // tournament of the match played in the room, guarded by clientsMutex
+	tournamentMatch *TournamentMatch
+	holds           map[string]*seatHold // seats of the disconnected players by client id
+	standby         []string             // clients waiting for a seat handed over, in the order they came
+	holdsMutex      sync.Mutex
+}
+
+func (r *Room) Info() map[string]any {
+	r.clientsMutex.RLock()
+	defer r.clientsMutex.RUnlock()
+	r.watchersMutex.RLock()
+	defer r.watchersMutex.RUnlock()
+	clientIDs := make([]string, 0, len(r.clients)+len(r.watchers))
+	for clientID := range r.clients {
+		clientIDs = append(clientIDs, clientID)
+	}
+	for clientID := range r.watchers {
+		clientIDs = append(clientIDs, clientID)
+	}
+	var clipsInfo map[string]string
+	if r.clipsInstance != nil {
+		clipsInfo = r.clipsInstance.Info()
+	}
+	return map[string]any{
+		"id":                r.id,
+		"name":              r.name,
+		"description":       r.description,
+		"clips_instance":    clipsInfo,
+		"running_game":      r.game.name,
+		"num_clients":       r.maxClients,
+		"playing_clients":   r.clients,
+		"watching_clients":  r.watchers,
+		"seats":             r.seatsInfo(),
+		"connected_sockets": r.events.addrs(),
+		"presence":          r.presenceStates(clientIDs),
+		"action_log":        r.actionLogInfo(),
+		"corrupted":         r.corruptedReason(),
+		"ended":             r.hasEnded(),
+		"access":            r.accessInfo(),
+		"params":            r.params,
+		"clock":             r.clockInfo(),
+		"bots":              r.botsInfo(),
+		"tournament":        r.tournamentID(),
+		"disconnects":       r.holdsInfo(),
+		"teams":             r.teamsInfo(),
+	}
+}
+
+// emit sends an event to the room subscribers, the changes of the players, the watchers and the end of the game
+// are also announced in the lobby
+func (r *Room) emit(eventType, actor string, payload any) {
+	r.events.emit(eventType, actor, payload)
+	switch eventType {
+	case EventPlayerJoined, EventPlayerLeft, EventWatcherJoined, EventWatcherLeft, EventGameEnded:
+		r.announce(LobbyRoomUpdated, actor, map[string]string{"room": r.id, "change": eventType})
+	}
+	if eventType == EventGameEnded {
+		r.announce(LobbyRoomFinished, actor, map[string]string{"room": r.id, "game": r.game.id})
+	}
+}
+
+// markCorrupted flags the room as corrupted, further assertions are refused
+func (r *Room) markCorrupted(reason string) {
+	r.corruptedMutex.Lock()
+	defer r.corruptedMutex.Unlock()
+	r.corrupted = reason
+}
+
+func (r *Room) corruptedReason() string {
+	r.corruptedMutex.RLock()
+	defer r.corruptedMutex.RUnlock()
+	return r.corrupted
+}
+
+// markEnded flags the game of the room as ended, it returns false if it already was
+func (r *Room) markEnded() bool {
+	r.endedMutex.Lock()
+	defer r.endedMutex.Unlock()
+	if r.ended {
+		return false
+	}
+	r.ended = true
+	return true
+}
+
+func (r *Room) hasEnded() bool {
+	r.endedMutex.RLock()
+	defer r.endedMutex.RUnlock()
+	return r.ended
+}
+
+// logAction appends an entry to the room action log, dropping the oldest entries beyond the log size
+func (r *Room) logAction(entry ActionLogEntry) {
+	r.actionLogMutex.Lock()
+	defer r.actionLogMutex.Unlock()
+	entry.Time = time.Now().Unix()
+	r.actionLog = append(r.actionLog, entry)
+	if r.actionLogSize > 0 && len(r.actionLog) > r.actionLogSize {
+		r.actionLog = r.actionLog[len(r.actionLog)-r.actionLogSize:]
 	}
 }
-func (e *Engine) newRoom(name, description, gameRef string) (*Room, error) {
+
+func (r *Room) actionLogInfo() []ActionLogEntry {
+	r.actionLogMutex.RLock()
+	defer r.actionLogMutex.RUnlock()
+	entries := make([]ActionLogEntry, len(r.actionLog))
+	copy(entries, r.actionLog)
+	return entries
+}
+
+// publishOutput records the lines printed by the rules in the action log and sends them to the room sockets
+func (r *Room) publishOutput(lines []OutputLine) {
+	for _, line := range lines {
+		r.logAction(ActionLogEntry{Kind: "output", Channel: line.Channel, Text: line.Text})
+		r.emit(EventOutput, "", line)
+	}
+}
+
+// newRoom creates a room for a game with the settings chosen by its owner, invalid settings are reported as
+// FieldErrors
+func (e *Engine) newRoom(name, description, gameRef, owner string, settings RoomSettings) (*Room, error) {
 
 	game, err := e.searchGame(gameRef)
 	if err != nil {
 		return nil, err
 	}
+	access := settings.RoomAccess
+	if fields := access.validate(); len(fields) > 0 {
+		return nil, FieldErrors(fields)
+	}
+	params, fields := game.resolveParams(settings.Params)
+	if len(fields) > 0 {
+		return nil, fields
+	}
 
 	rulesLocation := game.rulesLocation
 
 	// Ensure unique ID generation and locking on the rooms map
 	var cli *ClipsInstance
+	var output []OutputLine
+	var factIndex map[string]map[int64]string
+	var turnFacts string
 	if !e.ClipsLessMode {
 		cli = e.NewClipsInstance()
+		cli.SetRunLimits(game.runLimits)
 		if err := cli.InitClips(); err != nil {
 			return nil, err
 		}
-		if err := cli.loadGame(rulesLocation); err != nil {
+		if err := cli.loadGame(rulesLocation, paramFacts(params)); err != nil {
+			cli.Dispose()
+			return nil, err
+		}
+		// Keep what the rules printed while setting up the game, it opens the room action log
+		if output, err = cli.TakeOutput(); err != nil {
+			cli.Dispose()
+			return nil, err
+		}
+		// The initial facts are the base of the first state diff, the turn relation tells who the clock times first
+		if err := cli.Do(context.Background(), func() error {
+			var err error
+			if factIndex, err = cli.indexFactsAtomic(game.queryableRelations()); err != nil {
+				return err
+			}
+			if game.clock != nil {
+				turnFacts, err = cli.QueryFactsAtomic(game.clock.TurnRelation)
+			}
+			return err
+		}); err != nil {
 			cli.Dispose()
 			return nil, err
 		}
@@ -78,22 +231,55 @@ func (e *Engine) newRoom(name, description, gameRef string) (*Room, error) {
 	e.roomsMutex.Lock()
 	defer e.roomsMutex.Unlock()
 	room := &Room{
-		name:          name,
-		description:   description,
-		id:            e.generateRoomUniqueID(),
-		game:          game,
-		clipsInstance: cli,
-		maxClients:    game.numPlayers,
-		clients:       make(map[string]*Client),
-		clientsMutex:  sync.RWMutex{},
-		watchers:      make(map[string]*Client),
-		watchersMutex: sync.RWMutex{},
-		sockets:       make(map[*websocket.Conn]socketChan),
-		socketsMutex:  sync.RWMutex{},
-		lastActive:    time.Now().Unix(),
+		name:           name,
+		description:    description,
+		id:             e.generateRoomUniqueID(),
+		game:           game,
+		clipsInstance:  cli,
+		maxClients:     game.numPlayers,
+		clients:        make(map[string]*Client),
+		clientsMutex:   sync.RWMutex{},
+		seats:          make(map[string]string),
+		watchers:       make(map[string]*Client),
+		watchersMutex:  sync.RWMutex{},
+		lastActive:     time.Now().Unix(),
+		created:        time.Now().Unix(),
+		actionLog:      make([]ActionLogEntry, 0),
+		actionLogSize:  e.ActionLogSize,
+		actionLogMutex: sync.RWMutex{},
+		corruptedMutex: sync.RWMutex{},
+		factIndex:      factIndex,
+		endedMutex:     sync.RWMutex{},
+		presence:       make(map[string]*presence),
+		presenceMutex:  sync.Mutex{},
+		holds:          make(map[string]*seatHold),
+		holdsMutex:     sync.Mutex{},
+		params:         params,
 	}
+	if invite, err := e.generateInviteCode(); err != nil {
+		if cli != nil {
+			cli.Dispose()
+		}
+		return nil, err
+	} else if err := room.setAccess(access, owner, invite); err != nil {
+		if cli != nil {
+			cli.Dispose()
+		}
+		return nil, err
+	}
+	if game.clock != nil {
+		room.clock = newRoomClock(*game.clock, game.seats, func(seat string) { e.clockTimeout(room, seat) })
+		if turnMap, err := genericFactToMap(e.Config, game.clock.TurnRelation, turnFacts); err == nil {
+			room.clock.setActive(activeSeat(turnMap, game.clock.TurnSlot, game.seats), time.Now())
+		}
+	}
+	room.events = newEventHub(room.id, e.EventBufferSize)
+	room.lobby = e.lobby
+	room.publishOutput(output)
 	e.numRooms++
 	e.rooms[room.id] = room
+	e.invites[room.invite] = room.id
+	room.announce(LobbyRoomCreated, "", room.lobbyInfo())
 
 	game.roomsMutex.Lock()
 	defer game.roomsMutex.Unlock()
@@ -124,11 +310,45 @@ func (e *Engine) removeRoom(id string) (*Room, error) {
 	e.roomsMutex.Lock()
 	defer e.roomsMutex.Unlock()
 	if room, exists := e.rooms[id]; exists {
+		if room.clock != nil {
+			room.clock.stop(time.Now())
+		}
+		room.stopBots()
+		room.releaseHolds()
+		if !room.hasEnded() {
+			// A match that cannot be played anymore counts as a draw
+			e.endTournamentMatch(room, nil, "room removed")
+		}
 		if !e.ClipsLessMode {
 			room.clipsInstance.Dispose()
 		}
 		delete(e.rooms, id)
+		delete(e.invites, room.invite)
 		e.numRooms--
+
+		// Forget the room everywhere it is referenced, so that nobody can join it anymore
+		room.game.roomsMutex.Lock()
+		delete(room.game.partialRooms, id)
+		delete(room.game.runningRooms, id)
+		room.game.roomsMutex.Unlock()
+
+		room.clientsMutex.RLock()
+		for _, client := range room.clients {
+			client.roomsMutex.Lock()
+			delete(client.playingRooms, id)
+			client.roomsMutex.Unlock()
+		}
+		room.clientsMutex.RUnlock()
+
+		room.watchersMutex.RLock()
+		for _, client := range room.watchers {
+			client.watchersMutex.Lock()
+			delete(client.watchingRooms, id)
+			client.watchersMutex.Unlock()
+		}
+		room.watchersMutex.RUnlock()
+
+		room.announce(LobbyRoomDeleted, "", map[string]string{"room": id})
 		return room, nil
 	}
 	return nil, errors.New("room not found")
@@ -0,0 +1,40 @@
+package rulemancer
+
+import "testing"
+
+// duelTestGame returns a two seats game, x and o, the tests set what else they need on it
+func duelTestGame() *Game {
+	return &Game{id: "g1", name: "duel", numPlayers: 2, seats: []string{"x", "o"}}
+}
+
+// newTestEngine returns an engine without CLIPS where the given game is registered
+func newTestEngine(game *Game) *Engine {
+	e := NewEngine("secret")
+	e.ClipsLessMode = true
+	if game.partialRooms == nil {
+		game.partialRooms = make(map[string]*Room)
+	}
+	if game.runningRooms == nil {
+		game.runningRooms = make(map[string]*Room)
+	}
+	e.games[game.id] = game
+	return e
+}
+
+// newTestRoom returns a room of the given game where a new client for each name is seated, in the given order
+func newTestRoom(t *testing.T, game *Game, players ...string) (*Engine, *Room, []*Client) {
+	e := newTestEngine(game)
+	room, err := e.newRoom(game.name, "", game.id, "", RoomSettings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := make([]*Client, 0, len(players))
+	for _, name := range players {
+		client := e.newClient(name, "")
+		if ce := e.seatClient(room, client); ce != nil {
+			t.Fatal(ce)
+		}
+		clients = append(clients, client)
+	}
+	return e, room, clients
+}
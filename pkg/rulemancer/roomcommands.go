@@ -0,0 +1,438 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// CommandError is the failure of a room command, Status is the HTTP status the REST API answers with and Fields
+// the validation errors of the payload, if any
+type CommandError struct {
+	Status  int
+	Message string
+	Fields  []FieldError
+}
+
+func (ce *CommandError) Error() string {
+	return ce.Message
+}
+
+// clipsCommandError maps an error returned by a CLIPS job as ClipsError does
+func clipsCommandError(err error, failure string) *CommandError {
+	switch {
+	case errors.Is(err, ErrClipsBusy):
+		return &CommandError{Status: http.StatusServiceUnavailable, Message: "room busy, retry later"}
+	case errors.Is(err, ErrRulesLimit), errors.Is(err, ErrRunTimeout), errors.Is(err, ErrRuleError), errors.Is(err, ErrRunHalted):
+		return &CommandError{Status: http.StatusUnprocessableEntity, Message: err.Error()}
+	default:
+		return &CommandError{Status: http.StatusInternalServerError, Message: failure}
+	}
+}
+
+// canAssert checks that the requester plays in the room and that the room still accepts assertions
+func (e *Engine) canAssert(room *Room, requester string) *CommandError {
+	room.clientsMutex.RLock()
+	_, isPlayer := room.clients[requester]
+	room.clientsMutex.RUnlock()
+
+	if !isPlayer {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/canAssert]")+" ", 0)
+			l.Printf("Forbidden assert attempt in room %s by %s", room.id, requester)
+		}
+		return &CommandError{Status: http.StatusForbidden, Message: "forbidden"}
+	}
+
+	if reason := room.corruptedReason(); reason != "" {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/canAssert]")+" ", 0)
+			l.Printf("Assert attempt in corrupted room %s: %s", room.id, reason)
+		}
+		return &CommandError{Status: http.StatusConflict, Message: "room is corrupted: " + reason}
+	}
+
+	if waiting := room.pausedBy(); len(waiting) > 0 {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/canAssert]")+" ", 0)
+			l.Printf("Assert attempt in room %s paused for %v", room.id, waiting)
+		}
+		return &CommandError{Status: http.StatusConflict, Message: "game paused, waiting for " + strings.Join(waiting, ", ")}
+	}
+	return nil
+}
+
+// canQuery checks that the requester plays in or watches the room
+func (e *Engine) canQuery(room *Room, requester string) *CommandError {
+	allowed := false
+	room.clientsMutex.RLock()
+	room.watchersMutex.RLock()
+	if _, ok := room.clients[requester]; ok {
+		allowed = true
+	}
+	if _, ok := room.watchers[requester]; ok {
+		allowed = true
+	}
+	room.watchersMutex.RUnlock()
+	room.clientsMutex.RUnlock()
+
+	if !allowed {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/canQuery]")+" ", 0)
+			l.Printf("Forbidden query attempt in room %s by %s", room.id, requester)
+		}
+		return &CommandError{Status: http.StatusForbidden, Message: "forbidden"}
+	}
+	return nil
+}
+
+// prepareAssertion validates an assertion payload of the requester and returns the facts to assert, CLIPS is not
+// touched. In team games the team slot of the relations is filled with the team of the requester.
+func (e *Engine) prepareAssertion(room *Room, requester, assertion string, raw map[string]json.RawMessage) ([]string, *CommandError) {
+	relList, ok := room.game.assertable[assertion]
+	if !ok {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/prepareAssertion]")+" ", 0)
+			l.Printf("Assertion not found for room %s: %s", room.id, assertion)
+		}
+		return nil, &CommandError{Status: http.StatusNotFound, Message: "assertion not found"}
+	}
+
+	raw, ce := room.injectTeam(requester, assertion, raw)
+	if ce != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/prepareAssertion]")+" ", 0)
+			l.Printf("Assertion of %s in room %s names another team: %+v", requester, room.id, ce.Fields)
+		}
+		return nil, ce
+	}
+
+	// Validate the payload against the relations templates before touching CLIPS
+	if fieldErrors := room.game.validateAssertion(assertion, raw); len(fieldErrors) > 0 {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/prepareAssertion]")+" ", 0)
+			l.Printf("Invalid payload for assertion in room %s: %+v", room.id, fieldErrors)
+		}
+		return nil, &CommandError{Status: http.StatusBadRequest, Message: "invalid payload", Fields: fieldErrors}
+	}
+
+	// Create the facts list
+	facts := make([]string, 0)
+
+	for _, rel := range relList {
+		if _, exists := raw[rel]; !exists {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/prepareAssertion]")+" ", 0)
+				l.Printf("Missing required field for assertion in room %s: %s", room.id, rel)
+			}
+			return nil, &CommandError{Status: http.StatusBadRequest, Message: "missing required field: " + rel}
+		} else {
+			if newFacts, err := jsonGenericDecoder(e.Config, raw[rel]); err != nil {
+				if e.Debug {
+					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/prepareAssertion]")+" ", 0)
+					l.Printf("Error decoding field for assertion in room %s - %s: %v", room.id, rel, err)
+				}
+				return nil, &CommandError{Status: http.StatusBadRequest, Message: "invalid field format: " + rel}
+			} else {
+				// Append each fact wrapped in the relation
+				for _, fact := range newFacts {
+					fact := "(" + rel + " " + fact + ")"
+					facts = append(facts, fact)
+				}
+			}
+		}
+	}
+	return facts, nil
+}
+
+// execAssertion asserts the facts on behalf of the requester, runs the rules, publishes the events of the
+// assertion and returns the facts of the response relations
+func (e *Engine) execAssertion(ctx context.Context, room *Room, requester, assertion string, facts []string) (map[string][]map[string]string, *CommandError) {
+	// Everything touching CLIPS runs as a single job on the room instance, the failure message tells which step
+	// went wrong
+	ci := room.clipsInstance
+	respRelations := room.game.responses[assertion]
+	asserted := make([]string, 0, len(facts))
+	allFacts := make([]string, len(respRelations))
+	var output []OutputLine
+	var state map[string]string
+	var diff map[string]factsDiff
+	failure := ""
+
+	err := ci.Do(ctx, func() error {
+		for _, fact := range facts {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/execAssertion]")+" ", 0)
+				l.Printf("Asserting fact in room %s: %s", room.id, fact)
+			}
+			if err := ci.AssertFactAtomic(fact); err != nil {
+				failure = "failed to assert"
+				return err
+			}
+			asserted = append(asserted, fact)
+		}
+
+		if err := ci.RunAtomic(); err != nil {
+			failure = "failed to run"
+			// The rules may have been stopped halfway, their output is still worth showing
+			output, _ = ci.TakeOutputAtomic()
+			return err
+		}
+
+		var err error
+		if output, err = ci.TakeOutputAtomic(); err != nil {
+			failure = "failed to read output"
+			return err
+		}
+
+		// Aggregate all facts from all relations, the conversion is done outside the job
+		for i, rel := range respRelations {
+			if factList, err := ci.QueryFactsAtomic(rel); err != nil {
+				failure = "failed to query status"
+				return err
+			} else {
+				allFacts[i] = factList
+			}
+		}
+
+		// The room state and what changed are sent to the room sockets after every run
+		if state, err = room.queryStateAtomic(); err != nil {
+			failure = "failed to query status"
+			return err
+		}
+		if diff, err = room.stateDiffAtomic(); err != nil {
+			failure = "failed to query status"
+			return err
+		}
+		return nil
+	})
+
+	for _, fact := range asserted {
+		room.logAction(ActionLogEntry{Kind: "assert", Actor: requester, Text: fact})
+	}
+
+	if err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/execAssertion]")+" ", 0)
+			l.Printf("Error asserting in room %s: %v", room.id, err)
+		}
+		e.publishAssertion(room, requester, assertion, asserted, output, nil, nil, nil)
+		if e.CorruptOnRunLimit && (errors.Is(err, ErrRulesLimit) || errors.Is(err, ErrRunTimeout)) {
+			room.markCorrupted(err.Error())
+		}
+		return nil, clipsCommandError(err, failure)
+	}
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/execAssertion]")+" ", 0)
+		l.Printf("Successfully asserted and ran CLIPS in room %s", room.id)
+	}
+
+	// Prepare the response
+	response := make(map[string][]map[string]string)
+
+	if len(respRelations) == 0 {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/execAssertion]")+" ", 0)
+			l.Printf("Assertion has no response relations in room %s: %s", room.id, assertion)
+		}
+	}
+	for i, factList := range allFacts {
+		if factMap, err := genericFactToMap(e.Config, respRelations[i], factList); err != nil {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/execAssertion]")+" ", 0)
+				l.Printf("Error converting fact to struct in room %s - %s: %v", room.id, respRelations[i], err)
+			}
+			e.publishAssertion(room, requester, assertion, asserted, output, nil, diff, state)
+			return nil, &CommandError{Status: http.StatusInternalServerError, Message: "failed to convert fact to struct"}
+		} else {
+			response[respRelations[i]] = factMap
+		}
+	}
+
+	// The private facts in the results are only returned to their owner
+	e.publishAssertion(room, requester, assertion, asserted, output, response, diff, state)
+	return room.visibleRelations(requester, response), nil
+}
+
+// execQuery returns the facts of the relations of a query the requester can see
+func (e *Engine) execQuery(ctx context.Context, room *Room, requester, query string) (map[string][]map[string]string, *CommandError) {
+	ci := room.clipsInstance
+	relList, ok := room.game.queryable[query]
+	if !ok {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/execQuery]")+" ", 0)
+			l.Printf("Query not found for room %s: %s", room.id, query)
+		}
+		return nil, &CommandError{Status: http.StatusNotFound, Message: "query not found"}
+	} else if len(relList) == 0 {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/execQuery]")+" ", 0)
+			l.Printf("No relations for query in room %s: %s", room.id, query)
+		}
+		return nil, &CommandError{Status: http.StatusNotFound, Message: "no relations for query"}
+	}
+
+	// Aggregate all facts from all relations, the loop is split to limit the time spent in the CLIPS job
+	allFacts := make([]string, len(relList))
+	if err := ci.Do(ctx, func() error {
+		for i, rel := range relList {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/execQuery]")+" ", 0)
+				l.Printf("Processing relation for query in room %s: %s", room.id, rel)
+			}
+			if factList, err := ci.QueryFactsAtomic(rel); err != nil {
+				return err
+			} else {
+				allFacts[i] = factList
+			}
+		}
+		return nil
+	}); err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/execQuery]")+" ", 0)
+			l.Printf("Error querying status in room %s: %v", room.id, err)
+		}
+		return nil, clipsCommandError(err, "failed to query status")
+	}
+
+	response := make(map[string][]map[string]string)
+	for i, factList := range allFacts {
+		if factMap, err := genericFactToMap(e.Config, relList[i], factList); err != nil {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/execQuery]")+" ", 0)
+				l.Printf("Error converting fact to struct in room %s - %s: %v", room.id, relList[i], err)
+			}
+			return nil, &CommandError{Status: http.StatusInternalServerError, Message: "failed to convert fact to struct"}
+		} else {
+			response[relList[i]] = factMap
+		}
+	}
+	return room.visibleRelations(requester, response), nil
+}
+
+// roomActions returns what can be sent to a room: the assertions with their relations and the relations of their
+// response, and the queries with their relations
+func (e *Engine) roomActions(room *Room) map[string]any {
+	assertions := make(map[string]any, len(room.game.assertable))
+	for name, relations := range room.game.assertable {
+		responses := room.game.responses[name]
+		if responses == nil {
+			responses = make([]string, 0)
+		}
+		assertions[name] = map[string][]string{"relations": relations, "responses": responses}
+	}
+	return map[string]any{
+		"assertions": assertions,
+		"queries":    room.game.queryable,
+	}
+}
+
+// Room websocket commands
+const (
+	CommandAssert  = "assert"
+	CommandQuery   = "query"
+	CommandTry     = "try"
+	CommandActions = "actions"
+)
+
+// CommandResponseType is the type of the answers to the websocket commands, the room events never use it
+const CommandResponseType = "response"
+
+// RoomCommand is a request sent on the room websocket, ID is echoed in the response to correlate them
+type RoomCommand struct {
+	ID        json.RawMessage            `json:"id,omitempty"`
+	Type      string                     `json:"type"`
+	Assertion string                     `json:"assertion,omitempty"`
+	Query     string                     `json:"query,omitempty"`
+	Payload   map[string]json.RawMessage `json:"payload,omitempty"`
+}
+
+// RoomCommandResponse is the answer to a RoomCommand, sent to the requesting socket only. Status is the one the
+// REST API would answer with.
+type RoomCommandResponse struct {
+	Version int             `json:"v"`
+	Type    string          `json:"type"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Command string          `json:"command"`
+	Status  int             `json:"status"`
+	Result  any             `json:"result,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Fields  []FieldError    `json:"fields,omitempty"`
+}
+
+// execRoomCommand runs a command received on the room websocket on behalf of the requester, with the same
+// authorization as the REST routes, and returns the encoded response
+func (e *Engine) execRoomCommand(ctx context.Context, room *Room, requester string, msg []byte) []byte {
+	var cmd RoomCommand
+	var result any
+	var ce *CommandError
+
+	if err := json.Unmarshal(msg, &cmd); err != nil {
+		ce = &CommandError{Status: http.StatusBadRequest, Message: "invalid JSON message"}
+	} else {
+		switch cmd.Type {
+		case CommandAssert:
+			if ce = e.canAssert(room, requester); ce == nil {
+				var facts []string
+				if facts, ce = e.prepareAssertion(room, requester, cmd.Assertion, cmd.Payload); ce == nil {
+					var response map[string][]map[string]string
+					if response, ce = e.execAssertion(ctx, room, requester, cmd.Assertion, facts); ce == nil {
+						result = map[string]any{"status": "asserted", "response": response}
+					}
+				}
+			}
+		case CommandTry:
+			// The assertion is checked as it would be asserted, nothing reaches CLIPS
+			if ce = e.canAssert(room, requester); ce == nil {
+				var facts []string
+				if facts, ce = e.prepareAssertion(room, requester, cmd.Assertion, cmd.Payload); ce == nil {
+					result = map[string]any{"status": "valid", "facts": facts}
+				}
+			}
+		case CommandQuery:
+			if ce = e.canQuery(room, requester); ce == nil {
+				var response map[string][]map[string]string
+				if response, ce = e.execQuery(ctx, room, requester, cmd.Query); ce == nil {
+					result = map[string]any{"response": response}
+				}
+			}
+		case CommandActions:
+			if ce = e.canQuery(room, requester); ce == nil {
+				result = e.roomActions(room)
+			}
+		default:
+			ce = &CommandError{Status: http.StatusBadRequest, Message: "unknown command: " + cmd.Type}
+		}
+	}
+
+	response := RoomCommandResponse{
+		Version: EventProtocolVersion,
+		Type:    CommandResponseType,
+		ID:      cmd.ID,
+		Command: cmd.Type,
+		Status:  http.StatusOK,
+		Result:  result,
+	}
+	if ce != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/execRoomCommand]")+" ", 0)
+			l.Printf("Command %q of %s in room %s failed: %v", cmd.Type, requester, room.id, ce)
+		}
+		response.Status = ce.Status
+		response.Error = ce.Message
+		response.Fields = ce.Fields
+	}
+
+	if message, err := json.Marshal(response); err != nil {
+		return []byte(`{"v":1,"type":"response","status":500,"error":"failed to encode response"}`)
+	} else {
+		return message
+	}
+}
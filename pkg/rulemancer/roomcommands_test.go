@@ -0,0 +1,79 @@
+package rulemancer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestExecRoomCommand(t *testing.T) {
+	e := NewEngine("secret")
+	game := &Game{
+		assertable: map[string][]string{"move": {"move"}},
+		responses:  map[string][]string{"move": {"last-move"}},
+		queryable:  map[string][]string{"board": {"cell"}},
+		templates: map[string]*TemplateSchema{
+			"move": {Name: "move", Slots: []SlotSchema{
+				{Name: "x", Types: []string{"INTEGER"}, Range: &SlotRange{Min: "1", Max: "3"}},
+			}},
+		},
+	}
+	room, _ := newEventsTestRoom(game)
+	room.clients["alice"] = &Client{id: "alice"}
+	room.watchers["bob"] = &Client{id: "bob"}
+
+	tests := []struct {
+		name      string
+		requester string
+		message   string
+		status    int
+		command   string
+		id        string
+	}{
+		{name: "invalid JSON", requester: "alice", message: "not json", status: http.StatusBadRequest},
+		{name: "unknown command", requester: "alice", message: `{"id": 1, "type": "jump"}`, status: http.StatusBadRequest, command: "jump", id: "1"},
+		{name: "actions for a player", requester: "alice", message: `{"id": "a", "type": "actions"}`, status: http.StatusOK, command: CommandActions, id: `"a"`},
+		{name: "actions for a watcher", requester: "bob", message: `{"id": "b", "type": "actions"}`, status: http.StatusOK, command: CommandActions, id: `"b"`},
+		{name: "actions for a stranger", requester: "carol", message: `{"id": "c", "type": "actions"}`, status: http.StatusForbidden, command: CommandActions, id: `"c"`},
+		{name: "valid try", requester: "alice", message: `{"id": 2, "type": "try", "assertion": "move", "payload": {"move": {"x": ["1"]}}}`, status: http.StatusOK, command: CommandTry, id: "2"},
+		{name: "try out of range", requester: "alice", message: `{"id": 3, "type": "try", "assertion": "move", "payload": {"move": {"x": ["7"]}}}`, status: http.StatusBadRequest, command: CommandTry, id: "3"},
+		{name: "try unknown assertion", requester: "alice", message: `{"id": 4, "type": "try", "assertion": "jump", "payload": {}}`, status: http.StatusNotFound, command: CommandTry, id: "4"},
+		{name: "watcher cannot try", requester: "bob", message: `{"id": 5, "type": "try", "assertion": "move", "payload": {"move": {"x": ["1"]}}}`, status: http.StatusForbidden, command: CommandTry, id: "5"},
+		{name: "watcher cannot assert", requester: "bob", message: `{"id": 6, "type": "assert", "assertion": "move", "payload": {"move": {"x": ["1"]}}}`, status: http.StatusForbidden, command: CommandAssert, id: "6"},
+		{name: "stranger cannot query", requester: "carol", message: `{"id": 7, "type": "query", "query": "board"}`, status: http.StatusForbidden, command: CommandQuery, id: "7"},
+		{name: "unknown query", requester: "bob", message: `{"id": 8, "type": "query", "query": "hand"}`, status: http.StatusNotFound, command: CommandQuery, id: "8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var response RoomCommandResponse
+			if err := json.Unmarshal(e.execRoomCommand(context.Background(), room, tt.requester, []byte(tt.message)), &response); err != nil {
+				t.Fatalf("invalid response: %v", err)
+			}
+			if response.Type != CommandResponseType || response.Version != EventProtocolVersion {
+				t.Errorf("unexpected envelope: %+v", response)
+			}
+			if response.Status != tt.status {
+				t.Errorf("expected status %d, got %d (%s)", tt.status, response.Status, response.Error)
+			}
+			if response.Command != tt.command {
+				t.Errorf("expected command %q, got %q", tt.command, response.Command)
+			}
+			if string(response.ID) != tt.id {
+				t.Errorf("expected id %s, got %s", tt.id, response.ID)
+			}
+			if tt.status != http.StatusOK && response.Error == "" {
+				t.Errorf("missing error message")
+			}
+		})
+	}
+
+	// A corrupted room refuses assertions before anything else is checked
+	room.markCorrupted("test")
+	var response RoomCommandResponse
+	json.Unmarshal(e.execRoomCommand(context.Background(), room, "alice", []byte(`{"type": "try", "assertion": "move"}`)), &response)
+	if response.Status != http.StatusConflict {
+		t.Errorf("expected status %d in a corrupted room, got %d", http.StatusConflict, response.Status)
+	}
+}
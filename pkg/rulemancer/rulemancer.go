@@ -15,23 +15,68 @@ const (
 )
 
 type Config struct {
-	ClipsLessMode bool              `json:"clipsless_mode"`
-	Debug         bool              `json:"debug"`
-	DebugLevel    int               `json:"debug_level"`
-	TLSCertFile   string            `json:"tls_cert_file"`
-	TLSKeyFile    string            `json:"tls_key_file"`
-	Games         []string          `json:"games"`
-	Bridges       map[string]string `json:"bridges"`
+	ClipsLessMode       bool              `json:"clipsless_mode"`
+	Debug               bool              `json:"debug"`
+	DebugLevel          int               `json:"debug_level"`
+	TLSCertFile         string            `json:"tls_cert_file"`
+	TLSKeyFile          string            `json:"tls_key_file"`
+	Games               []string          `json:"games"`
+	Bridges             map[string]string `json:"bridges"`
+	ActionLogSize       int               `json:"action_log_size"`        // Number of entries kept in each room action log
+	MaxRulesFired       int64             `json:"max_rules_fired"`        // Default maximum number of rules fired by a single run, 0 means no limit
+	RunTimeoutMs        int64             `json:"run_timeout_ms"`         // Default wall clock budget of a single run in milliseconds, 0 means no limit
+	CorruptOnRunLimit   bool              `json:"corrupt_on_run_limit"`   // Mark a room as corrupted when a run exceeds its limits
+	ClipsQueueTimeoutMs int64             `json:"clips_queue_timeout_ms"` // How long a request waits for a busy CLIPS instance, 0 means no limit
+	EventBufferSize     int               `json:"event_buffer_size"`      // Number of events kept in each room and in the lobby to replay them to reconnecting clients
+	WSPingIntervalMs    int64             `json:"ws_ping_interval_ms"`    // How often a ping is sent on the websockets, 0 disables the pings
+	WSPongTimeoutMs     int64             `json:"ws_pong_timeout_ms"`     // How long a websocket may stay silent, pongs included, before it is closed, 0 means no limit
+	WSWriteTimeoutMs    int64             `json:"ws_write_timeout_ms"`    // How long a write to a websocket may block before the connection is closed, 0 means no limit
+	PresenceAwayMs      int64             `json:"presence_away_ms"`       // How long a connected client may stay inactive before it is shown as away, 0 disables it
+	WSTicketTTLMs       int64             `json:"ws_ticket_ttl_ms"`       // How long a websocket ticket can be used after it is issued
+	AllowedOrigins      []string          `json:"allowed_origins"`        // Origins allowed to open websockets besides the server own one, "*" allows any
+	InitialRating       float64           `json:"initial_rating"`         // Rating of the clients that never played a game
+	EloKFactor          float64           `json:"elo_k_factor"`           // Largest rating change of a single game
+	MatchIntervalMs     int64             `json:"match_interval_ms"`      // How often the matchmaking queues are scanned for matches
+	MatchRatingWindow   float64           `json:"match_rating_window"`    // Largest rating difference between matched clients that just queued
+	MatchWindowGrowth   float64           `json:"match_window_growth"`    // How much the rating window grows for every second a client waits
+	BotMoveDelayMs      int64             `json:"bot_move_delay_ms"`      // How long a bot waits before playing its turn
+	MCTSPlayouts        int               `json:"mcts_playouts"`          // Number of games the mcts bots play out before each move
+	MCTSTimeMs          int64             `json:"mcts_time_ms"`           // Wall clock budget of the search of the mcts bots, 0 means no limit
+	MCTSMaxDepth        int               `json:"mcts_max_depth"`         // Number of moves after which a playout is scored as a draw
+	StatsFile           string            `json:"stats_file"`             // File archiving the results of the games, empty keeps them in memory only
+	DisconnectGraceMs   int64             `json:"disconnect_grace_ms"`    // How long the seat of a disconnected player is kept for it, games can override it, 0 keeps it forever
 }
 
 func NewConfig() *Config {
 	return &Config{
-		ClipsLessMode: false,
-		Debug:         false,
-		TLSCertFile:   "server.crt",
-		TLSKeyFile:    "server.key",
-		Games:         []string{},
-		Bridges:       make(map[string]string),
+		ClipsLessMode:       false,
+		Debug:               false,
+		TLSCertFile:         "server.crt",
+		TLSKeyFile:          "server.key",
+		Games:               []string{},
+		Bridges:             make(map[string]string),
+		ActionLogSize:       200,
+		MaxRulesFired:       100000,
+		RunTimeoutMs:        5000,
+		ClipsQueueTimeoutMs: 10000,
+		EventBufferSize:     256,
+		WSPingIntervalMs:    30000,
+		WSPongTimeoutMs:     75000,
+		WSWriteTimeoutMs:    10000,
+		PresenceAwayMs:      120000,
+		WSTicketTTLMs:       30000,
+		AllowedOrigins:      []string{},
+		InitialRating:       1500,
+		EloKFactor:          32,
+		MatchIntervalMs:     1000,
+		MatchRatingWindow:   100,
+		MatchWindowGrowth:   10,
+		BotMoveDelayMs:      500,
+		MCTSPlayouts:        500,
+		MCTSTimeMs:          2000,
+		MCTSMaxDepth:        100,
+		StatsFile:           "",
+		DisconnectGraceMs:   60000,
 	}
 }
 
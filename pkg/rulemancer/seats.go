@@ -0,0 +1,121 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"errors"
+	"strconv"
+)
+
+// parseGameSeats returns the names the rules give to the players, in seat order, as listed by the optional
+// game-seats fact. Without it the seats are numbered from 1.
+func parseGameSeats(facts []map[string]string, numPlayers int) ([]string, error) {
+	switch len(facts) {
+	case 0:
+		seats := make([]string, 0, numPlayers)
+		for i := 1; i <= numPlayers; i++ {
+			seats = append(seats, strconv.Itoa(i))
+		}
+		return seats, nil
+	case 1:
+		seats := make([]string, 0, numPlayers)
+		for _, seat := range factsSplit(facts[0]["players"]) {
+			if isInSlice(seats, seat) {
+				return nil, errors.New("game-seats players slot lists a player twice")
+			}
+			seats = append(seats, seat)
+		}
+		if len(seats) != numPlayers {
+			return nil, errors.New("game-seats players slot must list num-players players")
+		}
+		return seats, nil
+	default:
+		return nil, errors.New("multiple game-seats facts found in the rules location")
+	}
+}
+
+// addPlayer seats a client on the first free seat of the room, the caller holds clientsMutex
+func (r *Room) addPlayer(client *Client) {
+	seat := ""
+	if free := r.freeSeats(); len(free) > 0 {
+		seat = free[0]
+	}
+	r.seatPlayer(client, seat)
+}
+
+// seatPlayer seats a client on the given seat of the room, the caller holds clientsMutex
+func (r *Room) seatPlayer(client *Client, seat string) {
+	if r.seats == nil {
+		r.seats = make(map[string]string)
+	}
+	r.clients[client.id] = client
+	if seat != "" {
+		r.seats[client.id] = seat
+	}
+	r.emitPlayerJoined(client)
+}
+
+// freeSeats returns the seats nobody plays, in seat order, the caller holds clientsMutex
+func (r *Room) freeSeats() []string {
+	taken := make(map[string]bool, len(r.seats))
+	for _, seat := range r.seats {
+		taken[seat] = true
+	}
+	free := make([]string, 0, len(r.game.seats))
+	for _, seat := range r.game.seats {
+		if !taken[seat] {
+			free = append(free, seat)
+		}
+	}
+	return free
+}
+
+// removePlayer frees the seat of a client, the caller holds clientsMutex
+func (r *Room) removePlayer(clientID string) bool {
+	_, playing := r.clients[clientID]
+	delete(r.clients, clientID)
+	delete(r.seats, clientID)
+	return playing
+}
+
+// leaveRoom takes a player out of a room: its seat can be joined again unless the game is over, the seat held
+// for it if it was disconnected is released and a bot stops playing. It returns false when the client did not
+// play in the room.
+func (e *Engine) leaveRoom(room *Room, client *Client, actor, reason string) bool {
+	// Locks in the order of seatClient
+	game := room.game
+	game.roomsMutex.Lock()
+	room.clientsMutex.Lock()
+	playing := room.removePlayer(client.id)
+	bot := room.bots[client.id]
+	delete(room.bots, client.id)
+	if _, running := game.runningRooms[room.id]; playing && running && !room.hasEnded() {
+		delete(game.runningRooms, room.id)
+		game.partialRooms[room.id] = room
+	}
+	client.roomsMutex.Lock()
+	delete(client.playingRooms, room.id)
+	client.roomsMutex.Unlock()
+	room.clientsMutex.Unlock()
+	game.roomsMutex.Unlock()
+
+	if !playing {
+		return false
+	}
+	room.releaseHold(client.id)
+	if bot != nil {
+		bot.stopOnce.Do(func() { close(bot.stop) })
+	}
+	room.emit(EventPlayerLeft, actor, map[string]string{"client": client.id, "reason": reason})
+	return true
+}
+
+// seatsInfo returns the seat of each player, the caller holds clientsMutex
+func (r *Room) seatsInfo() map[string]string {
+	seats := make(map[string]string, len(r.seats))
+	for clientID, seat := range r.seats {
+		seats[clientID] = seat
+	}
+	return seats
+}
@@ -0,0 +1,172 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	jwtauth "github.com/go-chi/jwtauth/v5"
+)
+
+// sseKeepAlive is how often a comment is sent on an idle event stream, so that proxies do not close it
+const sseKeepAlive = 30 * time.Second
+
+// parseSince returns the sequence number of the last event a reconnecting client received, from the
+// Last-Event-ID header sent by the browsers on reconnection or from the since query parameter
+func parseSince(r *http.Request) (bool, uint64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("since")
+	}
+	if value == "" {
+		return false, 0, nil
+	}
+	if since, err := strconv.ParseUint(value, 10, 64); err != nil {
+		return false, 0, err
+	} else {
+		return true, since, nil
+	}
+}
+
+// apiRoomEvents streams the room events to the players and the watchers of the room
+func (e *Engine) apiRoomEvents(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if room, err := e.searchRoom(id); err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/apiRoomEvents]")+" ", 0)
+			l.Printf("Room not found: %s", id)
+		}
+		Error(w, http.StatusNotFound, "room not found")
+		return
+	} else {
+
+		requester := ""
+		_, claims, err := jwtauth.FromContext(r.Context())
+		if err != nil {
+			Error(w, http.StatusUnauthorized, "unauthorized")
+			return
+		} else if clientID, ok := claims["id"].(string); !ok {
+			Error(w, http.StatusUnauthorized, "unauthorized")
+			return
+		} else {
+			requester = clientID
+		}
+
+		if ce := e.canQuery(room, requester); ce != nil {
+			CommandFailure(w, ce)
+			return
+		}
+
+		e.roomConnected(room, requester)
+		defer e.roomDisconnected(room, requester)
+
+		e.serveEventStream(w, r, room.events, requester, func(ctx context.Context) (any, error) {
+			return e.roomSnapshot(ctx, room)
+		})
+	}
+}
+
+// serveEventStream sends the events of a hub as Server-Sent Events until the client goes away or its subscription
+// is ended. The event id is the sequence number, a client resuming with Last-Event-ID gets the events it missed
+// first.
+func (e *Engine) serveEventStream(w http.ResponseWriter, r *http.Request, hub *eventHub, client string, snapshot snapshotFunc) {
+	replay, since, err := parseSince(r)
+	if err != nil {
+		Error(w, http.StatusBadRequest, "invalid last event id")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		Error(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	sub, backlog, resync, seq := hub.subscribe(r.RemoteAddr, client, replay, since)
+	defer hub.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, yellow("[rulemancer/serveEventStream]")+" ", 0)
+		l.Printf("event stream opened by %s (replay %v since %d, resync %v)", r.RemoteAddr, replay, since, resync)
+	}
+
+	ctx := r.Context()
+	lastSeq := seq
+	if replay {
+		lastSeq = since
+	}
+
+	// send writes the messages, false when the client is gone
+	send := func(messages []socketMessage) bool {
+		for _, msg := range messages {
+			if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", msg.seq, msg.message); err != nil {
+				return false
+			}
+			lastSeq = msg.seq
+		}
+		flusher.Flush()
+		return true
+	}
+
+	catchUp := func(backlog []socketMessage, resync bool, seq uint64) bool {
+		if messages, err := hub.catchUp(ctx, snapshot, lastSeq, backlog, resync, seq); err != nil {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/serveEventStream]")+" ", 0)
+				l.Printf("catching up %s failed: %v", r.RemoteAddr, err)
+			}
+			return false
+		} else {
+			return send(messages)
+		}
+	}
+
+	if replay && !catchUp(backlog, resync, seq) {
+		return
+	}
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sub.closed:
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-sub.lagged:
+			// The channel was full and some events were not queued, they are taken from the ring
+			if !catchUp(hub.eventsSince(lastSeq)) {
+				return
+			}
+		case msg := <-sub.ch:
+			if msg.seq <= lastSeq {
+				// Already sent by a replay
+				continue
+			}
+			if !send([]socketMessage{msg}) {
+				return
+			}
+		}
+	}
+}
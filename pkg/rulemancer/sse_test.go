@@ -0,0 +1,77 @@
+package rulemancer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseSince(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		query   string
+		replay  bool
+		since   uint64
+		wantErr bool
+	}{
+		{name: "no resume"},
+		{name: "last event id", header: "12", replay: true, since: 12},
+		{name: "since parameter", query: "7", replay: true, since: 7},
+		{name: "header wins", header: "12", query: "7", replay: true, since: 12},
+		{name: "invalid value", query: "abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/events", nil)
+			if tt.query != "" {
+				r = httptest.NewRequest(http.MethodGet, "/events?since="+tt.query, nil)
+			}
+			if tt.header != "" {
+				r.Header.Set("Last-Event-ID", tt.header)
+			}
+			replay, since, err := parseSince(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if replay != tt.replay || since != tt.since {
+				t.Errorf("expected replay %v since %d, got %v since %d", tt.replay, tt.since, replay, since)
+			}
+		})
+	}
+}
+
+func TestServeEventStream(t *testing.T) {
+	e := NewEngine("secret")
+	hub := newEventHub("room1", 8)
+	for i := 0; i < 3; i++ {
+		hub.emit(EventOutput, "", OutputLine{Channel: "t", Text: "line"})
+	}
+
+	// The client is already gone, only the replay is written
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx)
+	r.Header.Set("Last-Event-ID", "1")
+	w := httptest.NewRecorder()
+	e.serveEventStream(w, r, hub, "", nil)
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	body := w.Body.String()
+	if strings.Contains(body, "id: 1\n") || !strings.Contains(body, "id: 2\ndata: {") || !strings.Contains(body, "id: 3\ndata: {") {
+		t.Errorf("expected events 2 and 3, got %q", body)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/events", nil)
+	r.Header.Set("Last-Event-ID", "x")
+	w = httptest.NewRecorder()
+	e.serveEventStream(w, r, hub, "", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
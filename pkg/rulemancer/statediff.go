@@ -0,0 +1,149 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IndexedFact is a fact along with its CLIPS fact index. A fact changed by modify keeps its index, so a fact is
+// identified by its index and its content together.
+type IndexedFact struct {
+	Index int64
+	Fact  string
+}
+
+// DiffFact is a fact added to a relation, as sent in the state_diff event
+type DiffFact struct {
+	Index int64             `json:"index"`
+	Fact  map[string]string `json:"fact"`
+}
+
+// RelationDiff is the change of the facts of a relation between two runs
+type RelationDiff struct {
+	Added     []DiffFact `json:"added"`
+	Retracted []int64    `json:"retracted"`
+}
+
+// factsDiff is a RelationDiff before the conversion of the added facts
+type factsDiff struct {
+	added     []IndexedFact
+	retracted []int64
+}
+
+// parseIndexedFacts parses the records returned by find_indexed_facts_as_string
+func parseIndexedFacts(raw string) ([]IndexedFact, error) {
+	facts := make([]IndexedFact, 0)
+	for _, record := range strings.Split(raw, "\x1e") {
+		record = sanitizeFacts(record)
+		if record == "" {
+			continue
+		}
+		index, fact, found := strings.Cut(record, " ")
+		if !found {
+			return nil, fmt.Errorf("invalid indexed fact: %q", record)
+		}
+		if n, err := strconv.ParseInt(index, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid fact index in %q: %v", record, err)
+		} else {
+			facts = append(facts, IndexedFact{Index: n, Fact: strings.TrimSpace(fact)})
+		}
+	}
+	return facts, nil
+}
+
+// indexFactsAtomic returns the facts of the given relations by fact index, it must be called from a CLIPS job
+func (ci *ClipsInstance) indexFactsAtomic(relations []string) (map[string]map[int64]string, error) {
+	index := make(map[string]map[int64]string)
+	for _, rel := range relations {
+		if facts, err := ci.QueryIndexedFactsAtomic(rel); err != nil {
+			return nil, err
+		} else {
+			index[rel] = make(map[int64]string, len(facts))
+			for _, fact := range facts {
+				index[rel][fact.Index] = fact.Fact
+			}
+		}
+	}
+	return index, nil
+}
+
+// diffFacts compares the facts of a relation with the previous ones, both lists of the result are sorted by index.
+// A fact whose content changed under the same index is both retracted and added, the retractions apply first.
+func diffFacts(previous, current map[int64]string) factsDiff {
+	diff := factsDiff{added: make([]IndexedFact, 0), retracted: make([]int64, 0)}
+	for index, fact := range current {
+		if old, ok := previous[index]; !ok || old != fact {
+			diff.added = append(diff.added, IndexedFact{Index: index, Fact: fact})
+		}
+	}
+	for index, fact := range previous {
+		if now, ok := current[index]; !ok || now != fact {
+			diff.retracted = append(diff.retracted, index)
+		}
+	}
+	sort.Slice(diff.added, func(i, j int) bool { return diff.added[i].Index < diff.added[j].Index })
+	sort.Slice(diff.retracted, func(i, j int) bool { return diff.retracted[i] < diff.retracted[j] })
+	return diff
+}
+
+// diffAll returns the whole fact index as added facts
+func diffAll(index map[string]map[int64]string) map[string]factsDiff {
+	diffs := make(map[string]factsDiff, len(index))
+	for rel, facts := range index {
+		diffs[rel] = diffFacts(nil, facts)
+	}
+	return diffs
+}
+
+// stateDiffAtomic returns the facts of the queryable relations added or retracted since the previous call, only
+// the relations that changed are included. It must be called from a CLIPS job, which also serializes the
+// access to the room fact index.
+func (r *Room) stateDiffAtomic() (map[string]factsDiff, error) {
+	index, err := r.clipsInstance.indexFactsAtomic(r.game.queryableRelations())
+	if err != nil {
+		return nil, err
+	}
+	diffs := make(map[string]factsDiff)
+	for rel, facts := range index {
+		if diff := diffFacts(r.factIndex[rel], facts); len(diff.added) > 0 || len(diff.retracted) > 0 {
+			diffs[rel] = diff
+		}
+	}
+	r.factIndex = index
+	return diffs, nil
+}
+
+// convertDiff converts the added facts of a state diff, facts that cannot be converted are skipped
+func (e *Engine) convertDiff(room *Room, diffs map[string]factsDiff) map[string]RelationDiff {
+	relations := make(map[string]RelationDiff, len(diffs))
+	for rel, diff := range diffs {
+		relDiff := RelationDiff{Added: make([]DiffFact, 0, len(diff.added)), Retracted: diff.retracted}
+		for _, fact := range diff.added {
+			if factMap, err := genericFactToMap(e.Config, rel, fact.Fact); err != nil || len(factMap) != 1 {
+				if e.Debug {
+					l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/convertDiff]")+" ", 0)
+					l.Printf("Error converting fact %d of %s in room %s: %v", fact.Index, rel, room.id, err)
+				}
+			} else {
+				relDiff.Added = append(relDiff.Added, DiffFact{Index: fact.Index, Fact: factMap[0]})
+			}
+		}
+		relations[rel] = relDiff
+	}
+	return relations
+}
+
+// publishDiff sends the state_diff event, nothing is sent when no queryable fact changed
+func (e *Engine) publishDiff(room *Room, actor string, diffs map[string]factsDiff) {
+	if len(diffs) == 0 {
+		return
+	}
+	room.emit(EventStateDiff, actor, map[string]any{"relations": e.convertDiff(room, diffs)})
+}
@@ -0,0 +1,147 @@
+package rulemancer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIndexedFacts(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		expected []IndexedFact
+		wantErr  bool
+	}{
+		{
+			name:     "no facts",
+			raw:      "",
+			expected: []IndexedFact{},
+		},
+		{
+			name: "several facts",
+			raw:  "3 (cell (x 1) (y 1) (value x))\x1e7 (cell (x 2) (y 1) (value o))\x1e",
+			expected: []IndexedFact{
+				{Index: 3, Fact: "(cell (x 1) (y 1) (value x))"},
+				{Index: 7, Fact: "(cell (x 2) (y 1) (value o))"},
+			},
+		},
+		{
+			name:     "pretty printed on several lines",
+			raw:      "12 (cell\n   (x 1)\n   (y 1))\x1e",
+			expected: []IndexedFact{{Index: 12, Fact: "(cell   (x 1)   (y 1))"}},
+		},
+		{
+			name:    "missing index",
+			raw:     "(cell (x 1))\x1e",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			facts, err := parseIndexedFacts(tt.raw)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", facts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(facts, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, facts)
+			}
+		})
+	}
+}
+
+func TestDiffFacts(t *testing.T) {
+	tests := []struct {
+		name      string
+		previous  map[int64]string
+		current   map[int64]string
+		added     []int64
+		retracted []int64
+	}{
+		{
+			name:      "no change",
+			previous:  map[int64]string{1: "(a)", 2: "(b)"},
+			current:   map[int64]string{1: "(a)", 2: "(b)"},
+			added:     []int64{},
+			retracted: []int64{},
+		},
+		{
+			name:      "first diff",
+			current:   map[int64]string{4: "(a)", 2: "(b)"},
+			added:     []int64{2, 4},
+			retracted: []int64{},
+		},
+		{
+			name:      "modified fact",
+			previous:  map[int64]string{1: "(a)", 2: "(cell (value empty))"},
+			current:   map[int64]string{1: "(a)", 9: "(cell (value x))"},
+			added:     []int64{9},
+			retracted: []int64{2},
+		},
+		{
+			name:      "modified in place",
+			previous:  map[int64]string{1: "(a)", 2: "(cell (value empty))"},
+			current:   map[int64]string{1: "(a)", 2: "(cell (value x))"},
+			added:     []int64{2},
+			retracted: []int64{2},
+		},
+		{
+			name:      "all retracted",
+			previous:  map[int64]string{5: "(a)", 3: "(b)"},
+			current:   map[int64]string{},
+			added:     []int64{},
+			retracted: []int64{3, 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := diffFacts(tt.previous, tt.current)
+			added := make([]int64, 0)
+			for _, fact := range diff.added {
+				added = append(added, fact.Index)
+				if fact.Fact != tt.current[fact.Index] {
+					t.Errorf("fact %d: expected %s, got %s", fact.Index, tt.current[fact.Index], fact.Fact)
+				}
+			}
+			if !reflect.DeepEqual(added, tt.added) {
+				t.Errorf("expected added %v, got %v", tt.added, added)
+			}
+			if !reflect.DeepEqual(diff.retracted, tt.retracted) {
+				t.Errorf("expected retracted %v, got %v", tt.retracted, diff.retracted)
+			}
+		})
+	}
+}
+
+func TestPublishDiff(t *testing.T) {
+	e := NewEngine("secret")
+	room, ch := newEventsTestRoom(&Game{queryable: map[string][]string{"board": {"cell"}}})
+
+	e.publishDiff(room, "alice", map[string]factsDiff{})
+	e.publishDiff(room, "alice", map[string]factsDiff{
+		"cell": {added: []IndexedFact{{Index: 9, Fact: "(cell (x 1) (y 1) (value x))"}}, retracted: []int64{2}},
+	})
+
+	events := receivedEvents(t, ch)
+	if len(events) != 1 || events[0].Type != EventStateDiff {
+		t.Fatalf("expected a single state_diff event, got %+v", events)
+	}
+	payload, _ := events[0].Payload.(map[string]any)
+	relations, _ := payload["relations"].(map[string]any)
+	cell, _ := relations["cell"].(map[string]any)
+	added, _ := cell["added"].([]any)
+	if len(added) != 1 || !reflect.DeepEqual(cell["retracted"], []any{float64(2)}) {
+		t.Fatalf("unexpected diff: %v", relations)
+	}
+	fact, _ := added[0].(map[string]any)
+	if fact["index"] != float64(9) || !reflect.DeepEqual(fact["fact"], map[string]any{"x": "1", "y": "1", "value": "x"}) {
+		t.Errorf("unexpected added fact: %v", fact)
+	}
+}
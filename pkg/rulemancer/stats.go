@@ -0,0 +1,414 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Leaderboard limits
+const (
+	leaderboardDefaultLimit = 50
+	leaderboardMaxLimit     = 200
+)
+
+// statsWindows are the time windows of the stats and the leaderboards, all covers every recorded game
+var statsWindows = map[string]time.Duration{
+	"all":   0,
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"year":  365 * 24 * time.Hour,
+}
+
+// GameRecord is the result of a finished game. Games are recorded by name, their ids change with every start of
+// the engine.
+type GameRecord struct {
+	Game    string         `json:"game"`
+	Room    string         `json:"room"`
+	Ended   int64          `json:"ended"` // unix time
+	Players []RecordPlayer `json:"players"`
+}
+
+// RecordPlayer is a player of a recorded game with its score, 1 for a win, 0 for a loss and 0.5 for a draw
+type RecordPlayer struct {
+	Client string  `json:"client"`
+	Name   string  `json:"name"`
+	Seat   string  `json:"seat"`
+	Team   string  `json:"team,omitempty"`
+	Score  float64 `json:"score"`
+	Bot    bool    `json:"bot,omitempty"`
+}
+
+// scores returns the score of each player of the game
+func (gr GameRecord) scores() map[string]float64 {
+	scores := make(map[string]float64, len(gr.Players))
+	for _, p := range gr.Players {
+		scores[p.Client] = p.Score
+	}
+	return scores
+}
+
+// Outcomes counts the games of a client. Points sum the scores, WinRate is the share of the games won.
+type Outcomes struct {
+	Played     int     `json:"played"`
+	Wins       int     `json:"wins"`
+	Draws      int     `json:"draws"`
+	Losses     int     `json:"losses"`
+	Points     float64 `json:"points"`
+	WinRate    float64 `json:"win_rate"`
+	LastPlayed int64   `json:"last_played"`
+}
+
+func (o *Outcomes) add(score float64, ended int64) {
+	o.Played++
+	o.Points += score
+	switch {
+	case score > 0.5:
+		o.Wins++
+	case score < 0.5:
+		o.Losses++
+	default:
+		o.Draws++
+	}
+	o.WinRate = float64(o.Wins) / float64(o.Played)
+	if ended > o.LastPlayed {
+		o.LastPlayed = ended
+	}
+}
+
+// GameStats are the outcomes of a client in a game, with its current rating
+type GameStats struct {
+	Game   string  `json:"game"`
+	Rating float64 `json:"rating"`
+	Outcomes
+}
+
+// LeaderboardEntry is the position of a client in the leaderboard of a game
+type LeaderboardEntry struct {
+	Rank   int     `json:"rank"`
+	Client string  `json:"client"`
+	Name   string  `json:"name"`
+	Rating float64 `json:"rating"`
+	Outcomes
+}
+
+// statsStore keeps the results of the finished games. With a file the records are loaded from it at the start
+// and appended to it as the games end, one JSON object per line.
+type statsStore struct {
+	records []GameRecord
+	file    *os.File // nil keeps the records in memory only
+	mutex   sync.RWMutex
+}
+
+func newStatsStore() *statsStore {
+	return &statsStore{
+		records: make([]GameRecord, 0),
+		mutex:   sync.RWMutex{},
+	}
+}
+
+// open loads the records of a file, created if missing, and appends the following ones to it. The lines that
+// cannot be read, as the last one of an interrupted write, are skipped and their number returned.
+func (s *statsStore) open(path string) (int, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+	records := make([]GameRecord, 0)
+	skipped := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record GameRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			skipped++
+			continue
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return skipped, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.records = append(records, s.records...)
+	s.file = file
+	return skipped, nil
+}
+
+// close stops writing the records to the file
+func (s *statsStore) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file != nil {
+		s.file.Close()
+		s.file = nil
+	}
+}
+
+// add keeps a record and appends it to the file, the record is kept in memory even if the write fails
+func (s *statsStore) add(record GameRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.records = append(s.records, record)
+	if s.file == nil {
+		return nil
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// since returns the records of the games ended at or after the given unix time, in the order they ended
+func (s *statsStore) since(since int64) []GameRecord {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	records := make([]GameRecord, 0, len(s.records))
+	for _, record := range s.records {
+		if record.Ended >= since {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// parseStatsWindow reads the window query parameter and returns the unix time the window starts at, 0 for all
+func parseStatsWindow(values url.Values, now time.Time) (string, int64, []FieldError) {
+	window := values.Get("window")
+	if window == "" {
+		window = "all"
+	}
+	length, ok := statsWindows[window]
+	if !ok {
+		return window, 0, []FieldError{{Path: "window", Message: "must be one of all, day, week, month, year"}}
+	}
+	if length == 0 {
+		return window, 0, nil
+	}
+	return window, now.Add(-length).Unix(), nil
+}
+
+// clientStats returns the outcomes of a client in every game it played, sorted by game, and the last name it
+// played with
+func clientStats(records []GameRecord, clientID string) ([]GameStats, string) {
+	byGame := make(map[string]*GameStats)
+	name := ""
+	for _, record := range records {
+		for _, p := range record.Players {
+			if p.Client != clientID {
+				continue
+			}
+			stats, ok := byGame[record.Game]
+			if !ok {
+				stats = &GameStats{Game: record.Game}
+				byGame[record.Game] = stats
+			}
+			stats.add(p.Score, record.Ended)
+			name = p.Name
+		}
+	}
+	games := make([]GameStats, 0, len(byGame))
+	for _, stats := range byGame {
+		games = append(games, *stats)
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].Game < games[j].Game })
+	return games, name
+}
+
+// leaderboardQuery selects and sorts the entries of a leaderboard
+type leaderboardQuery struct {
+	window   string
+	since    int64
+	sort     string
+	minGames int
+	limit    int
+}
+
+// parseLeaderboardQuery reads the leaderboard query parameters, the invalid ones are reported as field errors
+func parseLeaderboardQuery(values url.Values, now time.Time) (leaderboardQuery, []FieldError) {
+	q := leaderboardQuery{
+		sort:     values.Get("sort"),
+		minGames: 1,
+		limit:    leaderboardDefaultLimit,
+	}
+	window, since, fields := parseStatsWindow(values, now)
+	q.window, q.since = window, since
+	if fields == nil {
+		fields = make([]FieldError, 0)
+	}
+
+	switch q.sort {
+	case "":
+		q.sort = "points"
+	case "points", "wins", "win_rate", "rating", "played":
+	default:
+		fields = append(fields, FieldError{Path: "sort", Message: "must be one of points, wins, win_rate, rating, played"})
+	}
+
+	integers := []struct {
+		name  string
+		value *int
+		min   int
+		max   int
+	}{
+		{"min_games", &q.minGames, 1, -1},
+		{"limit", &q.limit, 1, leaderboardMaxLimit},
+	}
+	for _, p := range integers {
+		raw := values.Get(p.name)
+		if raw == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(raw); err != nil || n < p.min || (p.max >= 0 && n > p.max) {
+			message := "must be an integer not lower than " + strconv.Itoa(p.min)
+			if p.max >= 0 {
+				message += " and not greater than " + strconv.Itoa(p.max)
+			}
+			fields = append(fields, FieldError{Path: p.name, Message: message})
+		} else {
+			*p.value = n
+		}
+	}
+	return q, fields
+}
+
+// leaderboard ranks the clients that played a game in the records, bots excluded. Ties are broken by points,
+// then by win rate, then by games played and by client id. The rating of a client is given by the rating function.
+func (q leaderboardQuery) leaderboard(records []GameRecord, game string, rating func(clientID string) float64) ([]LeaderboardEntry, int) {
+	byClient := make(map[string]*LeaderboardEntry)
+	for _, record := range records {
+		if record.Game != game {
+			continue
+		}
+		for _, p := range record.Players {
+			if p.Bot {
+				continue
+			}
+			entry, ok := byClient[p.Client]
+			if !ok {
+				entry = &LeaderboardEntry{Client: p.Client}
+				byClient[p.Client] = entry
+			}
+			entry.Name = p.Name
+			entry.add(p.Score, record.Ended)
+		}
+	}
+
+	entries := make([]LeaderboardEntry, 0, len(byClient))
+	for _, entry := range byClient {
+		if entry.Played >= q.minGames {
+			entry.Rating = rating(entry.Client)
+			entries = append(entries, *entry)
+		}
+	}
+	key := func(entry LeaderboardEntry) float64 {
+		switch q.sort {
+		case "wins":
+			return float64(entry.Wins)
+		case "win_rate":
+			return entry.WinRate
+		case "rating":
+			return entry.Rating
+		case "played":
+			return float64(entry.Played)
+		default:
+			return entry.Points
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		switch {
+		case key(a) != key(b):
+			return key(a) > key(b)
+		case a.Points != b.Points:
+			return a.Points > b.Points
+		case a.WinRate != b.WinRate:
+			return a.WinRate > b.WinRate
+		case a.Played != b.Played:
+			return a.Played > b.Played
+		default:
+			return a.Client < b.Client
+		}
+	})
+
+	total := len(entries)
+	if len(entries) > q.limit {
+		entries = entries[:q.limit]
+	}
+	for i := range entries {
+		entries[i].Rank = i + 1
+	}
+	return entries, total
+}
+
+// loadStats opens the stats file and replays the recorded games on the ratings, so that the leaderboards show the
+// players of the archived games. Clients get a new id at every start, their stats and ratings start over.
+func (e *Engine) loadStats() {
+	if e.StatsFile == "" {
+		return
+	}
+	skipped, err := e.stats.open(e.StatsFile)
+	if err != nil {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/loadStats]")+" ", 0)
+		l.Printf("Failed to open the stats file %s, the results will not be kept: %v", e.StatsFile, err)
+		return
+	}
+	records := e.stats.since(0)
+	for _, record := range records {
+		if game, err := e.searchGame(record.Game); err == nil {
+			e.ratings.update(game.id, record.scores(), e.EloKFactor, e.InitialRating)
+		}
+	}
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/loadStats]")+" ", 0)
+		l.Printf("Loaded %d game results from %s, %d unreadable lines skipped", len(records), e.StatsFile, skipped)
+	}
+}
+
+// recordGame keeps the result of a game that just ended with the given scores
+func (e *Engine) recordGame(room *Room, scores map[string]float64) {
+	if scores == nil {
+		return
+	}
+	room.clientsMutex.RLock()
+	players := make([]RecordPlayer, 0, len(room.clients))
+	for clientID, client := range room.clients {
+		players = append(players, RecordPlayer{
+			Client: clientID,
+			Name:   client.name,
+			Seat:   room.seats[clientID],
+			Team:   room.playerTeam(clientID),
+			Score:  scores[clientID],
+			Bot:    client.bot,
+		})
+	}
+	room.clientsMutex.RUnlock()
+	sort.Slice(players, func(i, j int) bool { return players[i].Seat < players[j].Seat })
+
+	record := GameRecord{Game: room.game.name, Room: room.id, Ended: time.Now().Unix(), Players: players}
+	if err := e.stats.add(record); err != nil {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/recordGame]")+" ", 0)
+		l.Printf("Failed to write the result of room %s to the stats file: %v", room.id, err)
+	}
+}
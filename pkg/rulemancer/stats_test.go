@@ -0,0 +1,147 @@
+package rulemancer
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testRecord returns the record of a game between alice and bob, alice scoring the given score
+func testRecord(game string, ended int64, alice float64) GameRecord {
+	return GameRecord{Game: game, Room: "room1", Ended: ended, Players: []RecordPlayer{
+		{Client: "alice", Name: "Alice", Seat: "x", Score: alice},
+		{Client: "bob", Name: "Bob", Seat: "o", Score: 1 - alice},
+	}}
+}
+
+func TestStatsStoreFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.jsonl")
+	store := newStatsStore()
+	if _, err := store.open(path); err != nil {
+		t.Fatal(err)
+	}
+	for i, score := range []float64{1, 0.5, 0} {
+		if err := store.add(testRecord("tictactoe", int64(100+i), score)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.close()
+
+	// An interrupted write leaves a broken last line
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"game": "tictac`)
+	file.Close()
+
+	reloaded := newStatsStore()
+	skipped, err := reloaded.open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reloaded.close()
+	if skipped != 1 {
+		t.Errorf("expected the broken line to be skipped, got %d", skipped)
+	}
+	if records := reloaded.since(0); len(records) != 3 || records[1].Players[0].Score != 0.5 {
+		t.Errorf("expected the 3 records back in order, got %v", records)
+	}
+	if records := reloaded.since(101); len(records) != 2 {
+		t.Errorf("expected 2 records since 101, got %d", len(records))
+	}
+}
+
+func TestClientStats(t *testing.T) {
+	records := []GameRecord{
+		testRecord("tictactoe", 100, 1),
+		testRecord("tictactoe", 200, 0.5),
+		testRecord("tictactoe", 300, 0),
+		testRecord("chess", 400, 1),
+	}
+	games, name := clientStats(records, "alice")
+	if name != "Alice" || len(games) != 2 {
+		t.Fatalf("expected the 2 games of Alice, got %s %v", name, games)
+	}
+	if games[0].Game != "chess" || games[0].Wins != 1 {
+		t.Errorf("unexpected chess stats %+v", games[0])
+	}
+	ttt := games[1].Outcomes
+	if ttt.Played != 3 || ttt.Wins != 1 || ttt.Draws != 1 || ttt.Losses != 1 || ttt.Points != 1.5 || ttt.LastPlayed != 300 {
+		t.Errorf("unexpected tictactoe stats %+v", ttt)
+	}
+	if games, name := clientStats(records, "carol"); len(games) != 0 || name != "" {
+		t.Errorf("carol never played, got %s %v", name, games)
+	}
+}
+
+func TestParseLeaderboardQuery(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	q, fields := parseLeaderboardQuery(url.Values{"window": {"day"}, "sort": {"win_rate"}, "limit": {"10"}}, now)
+	if len(fields) > 0 {
+		t.Fatalf("unexpected errors %v", fields)
+	}
+	if q.since != 1000000-86400 || q.sort != "win_rate" || q.limit != 10 || q.minGames != 1 {
+		t.Errorf("unexpected query %+v", q)
+	}
+
+	q, fields = parseLeaderboardQuery(url.Values{}, now)
+	if len(fields) > 0 || q.window != "all" || q.since != 0 || q.sort != "points" || q.limit != leaderboardDefaultLimit {
+		t.Errorf("unexpected defaults %+v %v", q, fields)
+	}
+
+	_, fields = parseLeaderboardQuery(url.Values{"window": {"decade"}, "sort": {"elo"}, "limit": {"0"}, "min_games": {"x"}}, now)
+	if len(fields) != 4 {
+		t.Errorf("expected 4 invalid fields, got %v", fields)
+	}
+}
+
+func TestLeaderboard(t *testing.T) {
+	records := []GameRecord{
+		testRecord("tictactoe", 100, 1),
+		testRecord("tictactoe", 200, 1),
+		testRecord("tictactoe", 300, 0),
+		{Game: "tictactoe", Ended: 400, Players: []RecordPlayer{
+			{Client: "carol", Name: "Carol", Score: 1},
+			{Client: "bot1", Name: "bot-random", Score: 0, Bot: true},
+		}},
+		testRecord("chess", 500, 0),
+	}
+	ratings := map[string]float64{"alice": 1510, "bob": 1490, "carol": 1516}
+	rating := func(clientID string) float64 { return ratings[clientID] }
+
+	q := leaderboardQuery{sort: "points", minGames: 1, limit: 50}
+	entries, total := q.leaderboard(records, "tictactoe", rating)
+	if total != 3 || len(entries) != 3 {
+		t.Fatalf("expected alice, bob and carol without the bot, got %d %v", total, entries)
+	}
+	if entries[0].Client != "alice" || entries[0].Points != 2 || entries[0].Rank != 1 || entries[0].Rating != 1510 {
+		t.Errorf("expected alice first with 2 points, got %+v", entries[0])
+	}
+	if entries[1].Client != "carol" || entries[2].Client != "bob" {
+		t.Errorf("expected carol then bob, got %v", entries)
+	}
+
+	q.sort = "win_rate"
+	if entries, _ := q.leaderboard(records, "tictactoe", rating); entries[0].Client != "carol" {
+		t.Errorf("expected carol first by win rate, got %v", entries)
+	}
+	q.sort, q.minGames, q.limit = "points", 2, 1
+	if entries, total := q.leaderboard(records, "tictactoe", rating); total != 2 || len(entries) != 1 || entries[0].Client != "alice" {
+		t.Errorf("expected alice alone among the 2 players with 2 games, got %d %v", total, entries)
+	}
+
+	// A window leaves out the earlier games
+	q = leaderboardQuery{sort: "points", minGames: 1, limit: 50}
+	window := make([]GameRecord, 0)
+	for _, record := range records {
+		if record.Ended >= 300 {
+			window = append(window, record)
+		}
+	}
+	if entries, _ := q.leaderboard(window, "tictactoe", rating); entries[0].Client != "bob" {
+		t.Errorf("expected bob first in the window, ahead of carol on the client id, got %v", entries)
+	}
+}
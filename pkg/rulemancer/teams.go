@@ -0,0 +1,249 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// teamSlot is the slot of the asserted relations the engine fills with the team of the asserting player
+const teamSlot = "team"
+
+// GameTeam is a team of a game and the seats it plays, declared with a game-team fact
+type GameTeam struct {
+	Name  string   `json:"name"`
+	Seats []string `json:"seats"`
+}
+
+// parseGameTeams reads the optional game-team facts. Without them every player plays for itself, with them every
+// seat plays for exactly one team.
+func parseGameTeams(facts []map[string]string, seats []string) ([]GameTeam, error) {
+	teams := make([]GameTeam, 0, len(facts))
+	if len(facts) == 0 {
+		return teams, nil
+	}
+	teamOf := make(map[string]string, len(seats))
+	for _, fact := range facts {
+		team := GameTeam{Name: fact["name"], Seats: factsSplit(fact["seats"])}
+		if team.Name == "" || team.Name == "nil" {
+			return nil, errors.New("game-team missing name slot")
+		}
+		if isInSlice(seats, team.Name) {
+			return nil, fmt.Errorf("game-team %s has the name of a seat", team.Name)
+		}
+		for _, other := range teams {
+			if other.Name == team.Name {
+				return nil, fmt.Errorf("game-team %s declared twice", team.Name)
+			}
+		}
+		if len(team.Seats) == 0 {
+			return nil, fmt.Errorf("game-team %s has no seats", team.Name)
+		}
+		for _, seat := range team.Seats {
+			if !isInSlice(seats, seat) {
+				return nil, fmt.Errorf("game-team %s seat %s is not a seat of the game", team.Name, seat)
+			}
+			if other, ok := teamOf[seat]; ok {
+				return nil, fmt.Errorf("game-team seat %s plays for both %s and %s", seat, other, team.Name)
+			}
+			teamOf[seat] = team.Name
+		}
+		teams = append(teams, team)
+	}
+	for _, seat := range seats {
+		if _, ok := teamOf[seat]; !ok {
+			return nil, fmt.Errorf("game-team facts found but seat %s plays for no team", seat)
+		}
+	}
+	return teams, nil
+}
+
+// parseGamePrivate reads the optional game-private facts and returns the slot telling the owner of the facts of
+// each private relation, owner-slot defaults to team
+func parseGamePrivate(facts []map[string]string) (map[string]string, error) {
+	private := make(map[string]string)
+	for _, fact := range facts {
+		slot := fact["owner-slot"]
+		if slot == "" || slot == "nil" {
+			slot = teamSlot
+		}
+		relations := factsSplit(fact["relations"])
+		if len(relations) == 0 {
+			return nil, errors.New("game-private relations slot lists no relation")
+		}
+		for _, rel := range relations {
+			if _, ok := private[rel]; ok {
+				return nil, fmt.Errorf("game-private relation %s declared twice", rel)
+			}
+			private[rel] = slot
+		}
+	}
+	return private, nil
+}
+
+// checkPrivateTemplates verifies that the private relations have their owner slot and that they do not end the
+// game, whose facts everybody gets
+func checkPrivateTemplates(private map[string]string, endRelations []string, templates map[string]*TemplateSchema) error {
+	for rel, slot := range private {
+		if tmpl, ok := templates[rel]; !ok || tmpl.Slot(slot) == nil {
+			return fmt.Errorf("game-private relation %s with slot %s not found", rel, slot)
+		}
+		if isInSlice(endRelations, rel) {
+			return fmt.Errorf("game-private relation %s ends the game, it cannot be private", rel)
+		}
+	}
+	return nil
+}
+
+// teamOf returns the team a seat plays for, empty when the game has no teams
+func (g *Game) teamOf(seat string) string {
+	for _, team := range g.teams {
+		if isInSlice(team.Seats, seat) {
+			return team.Name
+		}
+	}
+	return ""
+}
+
+// publicRelations returns the facts of the relations that are not private, those everybody in the room can see
+func (g *Game) publicRelations(relations map[string][]map[string]string) map[string][]map[string]string {
+	if relations == nil {
+		return nil
+	}
+	public := make(map[string][]map[string]string, len(relations))
+	for rel, facts := range relations {
+		if _, private := g.private[rel]; !private {
+			public[rel] = facts
+		}
+	}
+	return public
+}
+
+// playerTeam returns the team of a player, empty for the other clients and in games without teams. The caller
+// holds clientsMutex.
+func (r *Room) playerTeam(clientID string) string {
+	if seat, ok := r.seats[clientID]; ok {
+		return r.game.teamOf(seat)
+	}
+	return ""
+}
+
+// teamOf returns the team of a player
+func (r *Room) teamOf(clientID string) string {
+	r.clientsMutex.RLock()
+	defer r.clientsMutex.RUnlock()
+	return r.playerTeam(clientID)
+}
+
+// teamsInfo returns the players of each team, nil for games without teams. The caller holds clientsMutex.
+func (r *Room) teamsInfo() map[string][]string {
+	if len(r.game.teams) == 0 {
+		return nil
+	}
+	teams := make(map[string][]string, len(r.game.teams))
+	for _, team := range r.game.teams {
+		players := make([]string, 0, len(team.Seats))
+		for _, seat := range team.Seats {
+			for clientID, s := range r.seats {
+				if s == seat {
+					players = append(players, clientID)
+				}
+			}
+		}
+		teams[team.Name] = players
+	}
+	return teams
+}
+
+// visibleRelations returns the facts of the relations a client can see: all the facts of the public relations
+// and the facts of the private ones owned by its seat or by its team
+func (r *Room) visibleRelations(clientID string, relations map[string][]map[string]string) map[string][]map[string]string {
+	if len(r.game.private) == 0 || relations == nil {
+		return relations
+	}
+	r.clientsMutex.RLock()
+	seat, playing := r.seats[clientID]
+	team := r.playerTeam(clientID)
+	r.clientsMutex.RUnlock()
+
+	visible := make(map[string][]map[string]string, len(relations))
+	for rel, facts := range relations {
+		slot, private := r.game.private[rel]
+		if !private {
+			visible[rel] = facts
+			continue
+		}
+		owned := make([]map[string]string, 0, len(facts))
+		for _, fact := range facts {
+			if owner := fact[slot]; playing && (owner == seat || (team != "" && owner == team)) {
+				owned = append(owned, fact)
+			}
+		}
+		visible[rel] = owned
+	}
+	return visible
+}
+
+// injectTeam fills the team slot of the relations of an assertion with the team of the asserting player. A
+// payload naming another team is refused.
+func (r *Room) injectTeam(clientID, assertion string, raw map[string]json.RawMessage) (map[string]json.RawMessage, *CommandError) {
+	team := r.teamOf(clientID)
+	if team == "" {
+		return raw, nil
+	}
+	// Slot values are lists of strings, as in every assertion payload
+	teamValue, _ := json.Marshal([]string{team})
+	injected := make(map[string]json.RawMessage, len(raw))
+	for rel, value := range raw {
+		injected[rel] = value
+	}
+	for _, rel := range r.game.assertable[assertion] {
+		if tmpl, ok := r.game.templates[rel]; !ok || tmpl.Slot(teamSlot) == nil {
+			continue
+		}
+		value, ok := raw[rel]
+		if !ok {
+			continue
+		}
+
+		// A relation is given as an object or as a list of objects, one per fact
+		var facts []map[string]json.RawMessage
+		list := true
+		if err := json.Unmarshal(value, &facts); err != nil {
+			var fact map[string]json.RawMessage
+			if err := json.Unmarshal(value, &fact); err != nil {
+				// Left to the validation of the payload
+				continue
+			}
+			facts, list = []map[string]json.RawMessage{fact}, false
+		}
+		for i, fact := range facts {
+			if given, ok := fact[teamSlot]; ok {
+				var names []string
+				if err := json.Unmarshal(given, &names); err != nil || len(names) != 1 || names[0] != team {
+					path := rel + "." + teamSlot
+					if list {
+						path = fmt.Sprintf("%s[%d].%s", rel, i, teamSlot)
+					}
+					return nil, &CommandError{Status: http.StatusBadRequest, Message: "invalid payload",
+						Fields: []FieldError{{Path: path, Message: "must be your team " + team}}}
+				}
+			}
+			fact[teamSlot] = teamValue
+		}
+		var err error
+		if list {
+			injected[rel], err = json.Marshal(facts)
+		} else {
+			injected[rel], err = json.Marshal(facts[0])
+		}
+		if err != nil {
+			return nil, &CommandError{Status: http.StatusBadRequest, Message: "invalid field format: " + rel}
+		}
+	}
+	return injected, nil
+}
@@ -0,0 +1,255 @@
+package rulemancer
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseGameTeams(t *testing.T) {
+	seats := []string{"n", "e", "s", "w"}
+	tests := []struct {
+		name   string
+		facts  []map[string]string
+		teams  []GameTeam
+		failed bool
+	}{
+		{name: "no teams", facts: nil, teams: []GameTeam{}},
+		{
+			name:  "two pairs",
+			facts: []map[string]string{{"name": "ns", "seats": "n s"}, {"name": "ew", "seats": "e w"}},
+			teams: []GameTeam{{Name: "ns", Seats: []string{"n", "s"}}, {Name: "ew", Seats: []string{"e", "w"}}},
+		},
+		{name: "missing name", facts: []map[string]string{{"name": "nil", "seats": "n e s w"}}, failed: true},
+		{name: "seat name", facts: []map[string]string{{"name": "n", "seats": "n e s w"}}, failed: true},
+		{name: "unknown seat", facts: []map[string]string{{"name": "all", "seats": "n e s w x"}}, failed: true},
+		{
+			name:   "seat in two teams",
+			facts:  []map[string]string{{"name": "ns", "seats": "n s e"}, {"name": "ew", "seats": "e w"}},
+			failed: true,
+		},
+		{name: "seat in no team", facts: []map[string]string{{"name": "ns", "seats": "n s"}}, failed: true},
+		{
+			name:   "declared twice",
+			facts:  []map[string]string{{"name": "ns", "seats": "n s"}, {"name": "ns", "seats": "e w"}},
+			failed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			teams, err := parseGameTeams(tt.facts, seats)
+			if tt.failed {
+				if err == nil {
+					t.Errorf("expected an error, got %v", teams)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(teams, tt.teams) {
+				t.Errorf("expected %v, got %v", tt.teams, teams)
+			}
+		})
+	}
+}
+
+func TestParseGamePrivate(t *testing.T) {
+	tests := []struct {
+		name    string
+		facts   []map[string]string
+		private map[string]string
+		failed  bool
+	}{
+		{name: "none", facts: nil, private: map[string]string{}},
+		{
+			name:    "default owner slot",
+			facts:   []map[string]string{{"relations": "hand plan", "owner-slot": "nil"}},
+			private: map[string]string{"hand": "team", "plan": "team"},
+		},
+		{
+			name:    "seat owner",
+			facts:   []map[string]string{{"relations": "hand", "owner-slot": "seat"}, {"relations": "plan"}},
+			private: map[string]string{"hand": "seat", "plan": "team"},
+		},
+		{name: "no relations", facts: []map[string]string{{"relations": ""}}, failed: true},
+		{name: "declared twice", facts: []map[string]string{{"relations": "hand"}, {"relations": "hand"}}, failed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			private, err := parseGamePrivate(tt.facts)
+			if tt.failed {
+				if err == nil {
+					t.Errorf("expected an error, got %v", private)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(private, tt.private) {
+				t.Errorf("expected %v, got %v", tt.private, private)
+			}
+		})
+	}
+}
+
+// newTeamTestRoom returns a room of a four seats game played by two teams, seated in the given order
+func newTeamTestRoom(t *testing.T, players ...string) (*Engine, *Room, []*Client) {
+	return newTestRoom(t, &Game{id: "bridge", name: "bridge", numPlayers: 4, seats: []string{"n", "e", "s", "w"},
+		teams:      []GameTeam{{Name: "ns", Seats: []string{"n", "s"}}, {Name: "ew", Seats: []string{"e", "w"}}},
+		private:    map[string]string{"hand": "seat", "plan": "team"},
+		assertable: map[string][]string{"bid": {"bid"}},
+		templates: map[string]*TemplateSchema{
+			"bid": {Name: "bid", Slots: []SlotSchema{{Name: "level"}, {Name: "team"}}},
+		},
+	}, players...)
+}
+
+func TestVisibleRelations(t *testing.T) {
+	_, room, clients := newTeamTestRoom(t, "north", "east", "south")
+	north, east, south := clients[0], clients[1], clients[2]
+	relations := map[string][]map[string]string{
+		"trick": {{"card": "AS"}},
+		"hand":  {{"seat": "n", "card": "KH"}, {"seat": "s", "card": "2C"}},
+		"plan":  {{"team": "ns", "goal": "4S"}, {"team": "ew", "goal": "pass"}},
+	}
+
+	tests := []struct {
+		name     string
+		clientID string
+		expected map[string][]map[string]string
+	}{
+		{
+			name:     "north",
+			clientID: north.id,
+			expected: map[string][]map[string]string{
+				"trick": {{"card": "AS"}},
+				"hand":  {{"seat": "n", "card": "KH"}},
+				"plan":  {{"team": "ns", "goal": "4S"}},
+			},
+		},
+		{
+			name:     "south sees the plan of north",
+			clientID: south.id,
+			expected: map[string][]map[string]string{
+				"trick": {{"card": "AS"}},
+				"hand":  {{"seat": "s", "card": "2C"}},
+				"plan":  {{"team": "ns", "goal": "4S"}},
+			},
+		},
+		{
+			name:     "east",
+			clientID: east.id,
+			expected: map[string][]map[string]string{
+				"trick": {{"card": "AS"}},
+				"hand":  {},
+				"plan":  {{"team": "ew", "goal": "pass"}},
+			},
+		},
+		{
+			name:     "watcher",
+			clientID: "somebody",
+			expected: map[string][]map[string]string{"trick": {{"card": "AS"}}, "hand": {}, "plan": {}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if visible := room.visibleRelations(tt.clientID, relations); !reflect.DeepEqual(visible, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, visible)
+			}
+		})
+	}
+	if public := room.game.publicRelations(relations); !reflect.DeepEqual(public, map[string][]map[string]string{"trick": relations["trick"]}) {
+		t.Errorf("expected only the trick to be public, got %v", public)
+	}
+}
+
+func TestInjectTeam(t *testing.T) {
+	_, room, clients := newTeamTestRoom(t, "north", "east")
+	north, east := clients[0], clients[1]
+
+	tests := []struct {
+		name     string
+		clientID string
+		payload  string
+		expected string
+		path     string
+	}{
+		{name: "object", clientID: north.id, payload: `{"bid": {"level": ["4"]}}`, expected: `{"level": ["4"], "team": ["ns"]}`},
+		{name: "other team", clientID: east.id, payload: `{"bid": {"level": ["4"]}}`, expected: `{"level": ["4"], "team": ["ew"]}`},
+		{name: "own team", clientID: north.id, payload: `{"bid": {"level": ["4"], "team": ["ns"]}}`, expected: `{"level": ["4"], "team": ["ns"]}`},
+		{name: "list", clientID: north.id, payload: `{"bid": [{"level": ["4"]}]}`, expected: `[{"level": ["4"], "team": ["ns"]}]`},
+		{name: "not a player", clientID: "somebody", payload: `{"bid": {"level": ["4"]}}`, expected: `{"level": ["4"]}`},
+		{name: "wrong team", clientID: north.id, payload: `{"bid": {"level": ["4"], "team": ["ew"]}}`, path: "bid.team"},
+		{name: "wrong team in a list", clientID: north.id, payload: `{"bid": [{"level": ["4"], "team": ["ew"]}]}`, path: "bid[0].team"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var raw map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(tt.payload), &raw); err != nil {
+				t.Fatal(err)
+			}
+			injected, ce := room.injectTeam(tt.clientID, "bid", raw)
+			if tt.path != "" {
+				if ce == nil || ce.Status != http.StatusBadRequest || len(ce.Fields) != 1 || ce.Fields[0].Path != tt.path {
+					t.Errorf("expected a field error on %s, got %v", tt.path, ce)
+				}
+				return
+			}
+			if ce != nil {
+				t.Fatalf("unexpected error: %v", ce)
+			}
+			var got, expected any
+			if err := json.Unmarshal(injected["bid"], &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.expected), &expected); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("expected %v, got %v", expected, got)
+			}
+		})
+	}
+}
+
+func TestSeatScoresTeams(t *testing.T) {
+	seats := []string{"n", "e", "s", "w"}
+	teams := []GameTeam{{Name: "ns", Seats: []string{"n", "s"}}, {Name: "ew", Seats: []string{"e", "w"}}}
+
+	tests := []struct {
+		name   string
+		ending map[string][]map[string]string
+		scores map[string]float64
+	}{
+		{
+			name:   "team wins",
+			ending: map[string][]map[string]string{"winner": {{"team": "ew"}}},
+			scores: map[string]float64{"n": 0, "e": 1, "s": 0, "w": 1},
+		},
+		{
+			name:   "seat wins",
+			ending: map[string][]map[string]string{"winner": {{"seat": "n"}}},
+			scores: map[string]float64{"n": 1, "e": 0, "s": 0, "w": 0},
+		},
+		{
+			name:   "draw",
+			ending: map[string][]map[string]string{"draw": {{"teams": "ns ew"}}},
+			scores: map[string]float64{"n": 0.5, "e": 0.5, "s": 0.5, "w": 0.5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if scores := seatScores(seats, teams, tt.ending); !reflect.DeepEqual(scores, tt.scores) {
+				t.Errorf("expected %v, got %v", tt.scores, scores)
+			}
+		})
+	}
+}
@@ -8,6 +8,21 @@ source "$(dirname "$0")/common.sh"
 
 ROOM_ID="${1:?usage: $0 <room_id>{{ $instr }}}"
 
+{{- range $rel := .CurrentAssertRelations }}
+{{- range $slotElem := index $.Slots $rel }}
+{{- $hint := $.SlotHint $rel $slotElem }}
+{{- if $hint }}
+# {{ $slotElem }}: {{ $hint }}
+{{- end }}
+{{- end }}
+{{- range $multislotElem := index $.Multislots $rel }}
+{{- $hint := $.SlotHint $rel $multislotElem }}
+{{- if $hint }}
+# {{ $multislotElem }}: {{ $hint }}
+{{- end }}
+{{- end }}
+{{- end }}
+
 {{- $num := 2 }}
 {{- range $slotElem := .CurrentAssertParams }}
 {{ tovar $slotElem }}="{{ print "${" $num}}:?usage: $0 <room_id>{{ $instr }}}"
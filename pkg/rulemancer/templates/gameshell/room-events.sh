@@ -0,0 +1,21 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+source "$(dirname "$0")/common.sh"
+
+ROOM_ID="${1:?usage: $0 <room_id> [event_type] [since_seq]}"
+EVENT_TYPE="${2:-}"
+SINCE="${3:-}"
+
+WS_URL="${API_HOST/#http/ws}${API_BASE}/room/$ROOM_ID/ws"
+if [[ -n "$SINCE" ]]; then
+  # Replay the events following the given sequence number
+  WS_URL="$WS_URL?since=$SINCE"
+fi
+
+echo "WS $WS_URL" >&2
+
+# Every message is a JSON event envelope, only the version known by this script is printed
+websocat -k -H "Authorization: Bearer $API_TOKEN" "$WS_URL" |
+  jq -c --arg type "$EVENT_TYPE" \
+    'select(.v == {{ .EventProtocolVersion }} and ($type == "" or .type == $type))'
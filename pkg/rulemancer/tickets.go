@@ -0,0 +1,165 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	jwtauth "github.com/go-chi/jwtauth/v5"
+)
+
+// wsTicket lets a browser open a websocket or an event stream, it cannot set the Authorization header on them.
+// A ticket is used once, before it expires, on one of the paths it was issued for.
+type wsTicket struct {
+	client  string
+	paths   []string
+	expires time.Time
+}
+
+// ticketStore keeps the tickets issued and not used yet
+type ticketStore struct {
+	tickets map[string]*wsTicket
+	mutex   sync.Mutex
+}
+
+func newTicketStore() *ticketStore {
+	return &ticketStore{
+		tickets: make(map[string]*wsTicket),
+		mutex:   sync.Mutex{},
+	}
+}
+
+// issue returns a new ticket for the client, valid on the given paths for ttl. The expired tickets are dropped.
+func (ts *ticketStore) issue(client string, paths []string, ttl time.Duration) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	now := time.Now()
+	for key, ticket := range ts.tickets {
+		if now.After(ticket.expires) {
+			delete(ts.tickets, key)
+		}
+	}
+	ts.tickets[id] = &wsTicket{client: client, paths: paths, expires: now.Add(ttl)}
+	return id, nil
+}
+
+// redeem consumes a ticket and returns the client it was issued to. A ticket presented on a path it was not
+// issued for is consumed as well.
+func (ts *ticketStore) redeem(id, requestPath string) (string, bool) {
+	ts.mutex.Lock()
+	defer ts.mutex.Unlock()
+	ticket, ok := ts.tickets[id]
+	if !ok {
+		return "", false
+	}
+	delete(ts.tickets, id)
+	if time.Now().After(ticket.expires) {
+		return "", false
+	}
+	for _, p := range ticket.paths {
+		if p == requestPath {
+			return ticket.client, true
+		}
+	}
+	return "", false
+}
+
+// ticketVerifier authenticates the requests carrying a ticket query parameter as the client the ticket was issued
+// to. It sits between the JWT verifier and the authenticator, the requests without a ticket are left alone.
+func (e *Engine) ticketVerifier(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("ticket")
+		if id == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if clientID, ok := e.tickets.redeem(id, r.URL.Path); !ok {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/ticketVerifier]")+" ", 0)
+				l.Printf("Invalid or expired ticket for %s", r.URL.Path)
+			}
+			Error(w, http.StatusUnauthorized, "invalid ticket")
+			return
+		} else if token, _, err := e.Encode(map[string]interface{}{"id": clientID}); err != nil {
+			Error(w, http.StatusInternalServerError, "failed to authenticate ticket")
+			return
+		} else {
+			next.ServeHTTP(w, r.WithContext(jwtauth.NewContext(r.Context(), token, nil)))
+		}
+	})
+}
+
+// issueTicket answers with a ticket for the given paths
+func (e *Engine) issueTicket(w http.ResponseWriter, clientID string, paths []string) {
+	ttl := time.Duration(e.WSTicketTTLMs) * time.Millisecond
+	if ticket, err := e.tickets.issue(clientID, paths, ttl); err != nil {
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/issueTicket]")+" ", 0)
+			l.Printf("Error issuing ticket: %v", err)
+		}
+		Error(w, http.StatusInternalServerError, "failed to issue ticket")
+	} else {
+		JSON(w, http.StatusOK, map[string]any{
+			"ticket":        ticket,
+			"expires_in_ms": e.WSTicketTTLMs,
+		})
+	}
+}
+
+// apiRoomTicket issues a ticket for the websocket and the event stream of a room to its players and watchers
+func (e *Engine) apiRoomTicket(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if room, err := e.searchRoom(id); err != nil {
+		Error(w, http.StatusNotFound, "room not found")
+		return
+	} else {
+		requester := ""
+		_, claims, err := jwtauth.FromContext(r.Context())
+		if err != nil {
+			Error(w, http.StatusUnauthorized, "unauthorized")
+			return
+		} else if clientID, ok := claims["id"].(string); !ok {
+			Error(w, http.StatusUnauthorized, "unauthorized")
+			return
+		} else {
+			requester = clientID
+		}
+
+		if ce := e.canQuery(room, requester); ce != nil {
+			CommandFailure(w, ce)
+			return
+		}
+
+		base := path.Dir(r.URL.Path)
+		e.issueTicket(w, requester, []string{base + "/ws", base + "/events"})
+	}
+}
+
+// apiSystemTicket issues a ticket for the system websocket to the admin
+func (e *Engine) apiSystemTicket(w http.ResponseWriter, r *http.Request) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	} else if clientID, ok := claims["id"].(string); !ok || clientID != "admin" {
+		Error(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	e.issueTicket(w, "admin", []string{path.Dir(r.URL.Path) + "/ws"})
+}
@@ -0,0 +1,116 @@
+package rulemancer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	jwtauth "github.com/go-chi/jwtauth/v5"
+)
+
+func TestTicketStore(t *testing.T) {
+	ts := newTicketStore()
+	paths := []string{"/api/v1/room/r1/ws", "/api/v1/room/r1/events"}
+
+	ticket, err := ts.issue("alice", paths, time.Minute)
+	if err != nil {
+		t.Fatalf("issue failed: %v", err)
+	}
+	if client, ok := ts.redeem(ticket, "/api/v1/room/r1/events"); !ok || client != "alice" {
+		t.Errorf("expected alice, got %q %v", client, ok)
+	}
+	if _, ok := ts.redeem(ticket, "/api/v1/room/r1/ws"); ok {
+		t.Errorf("a ticket must not be used twice")
+	}
+
+	ticket, _ = ts.issue("alice", paths, time.Minute)
+	if _, ok := ts.redeem(ticket, "/api/v1/room/r2/ws"); ok {
+		t.Errorf("a ticket must not be used on another path")
+	}
+	if _, ok := ts.redeem(ticket, "/api/v1/room/r1/ws"); ok {
+		t.Errorf("a ticket presented on another path must be consumed")
+	}
+
+	ticket, _ = ts.issue("alice", paths, -time.Second)
+	if _, ok := ts.redeem(ticket, "/api/v1/room/r1/ws"); ok {
+		t.Errorf("an expired ticket must be refused")
+	}
+	if _, ok := ts.redeem("unknown", "/api/v1/room/r1/ws"); ok {
+		t.Errorf("an unknown ticket must be refused")
+	}
+}
+
+func TestTicketVerifier(t *testing.T) {
+	e := NewEngine("secret")
+	ticket, _ := e.tickets.issue("alice", []string{"/api/v1/room/r1/ws"}, time.Minute)
+
+	handler := e.ticketVerifier(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := ""
+		if _, claims, err := jwtauth.FromContext(r.Context()); err == nil {
+			id, _ = claims["id"].(string)
+		}
+		w.Write([]byte(id))
+	}))
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+		client string
+	}{
+		{name: "no ticket", target: "/api/v1/room/r1/ws", status: http.StatusOK, client: ""},
+		{name: "valid ticket", target: "/api/v1/room/r1/ws?ticket=" + ticket, status: http.StatusOK, client: "alice"},
+		{name: "used ticket", target: "/api/v1/room/r1/ws?ticket=" + ticket, status: http.StatusUnauthorized},
+		{name: "unknown ticket", target: "/api/v1/room/r1/ws?ticket=nope", status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			if rec.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			if tt.status == http.StatusOK && rec.Body.String() != tt.client {
+				t.Errorf("expected client %q, got %q", tt.client, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	e := NewEngine("secret")
+	e.AllowedOrigins = []string{"https://games.example.com/"}
+
+	tests := []struct {
+		name    string
+		origin  string
+		allowed bool
+	}{
+		{name: "no origin", origin: "", allowed: true},
+		{name: "same origin", origin: "https://localhost:3000", allowed: true},
+		{name: "allowed origin", origin: "https://games.example.com", allowed: true},
+		{name: "foreign origin", origin: "https://evil.example.com", allowed: false},
+		{name: "invalid origin", origin: "::", allowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "https://localhost:3000/api/v1/system/ws", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := e.checkOrigin(r); got != tt.allowed {
+				t.Errorf("expected %v, got %v", tt.allowed, got)
+			}
+		})
+	}
+
+	e.AllowedOrigins = []string{"*"}
+	r := httptest.NewRequest(http.MethodGet, "https://localhost:3000/api/v1/system/ws", nil)
+	r.Header.Set("Origin", "https://evil.example.com")
+	if !e.checkOrigin(r) {
+		t.Errorf("the wildcard must allow any origin")
+	}
+}
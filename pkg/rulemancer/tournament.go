@@ -0,0 +1,747 @@
+/*
+Copyright © 2026 Mirko Mariotti mirko@mirkomariotti.it
+*/
+package rulemancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Tournament formats
+const (
+	FormatSingleElimination = "single_elimination"
+	FormatRoundRobin        = "round_robin"
+	FormatSwiss             = "swiss"
+)
+
+// Tournament states
+const (
+	TournamentRegistering = "registering" // players can register and leave
+	TournamentRunning     = "running"     // the rounds are being played
+	TournamentFinished    = "finished"    // every round was played
+)
+
+// Tournament event types, sent on the tournament websocket with the room events envelope
+const (
+	TournamentPlayerRegistered   = "player_registered"
+	TournamentPlayerUnregistered = "player_unregistered"
+	TournamentRoundStarted       = "round_started"
+	TournamentMatchEnded         = "match_ended"
+	TournamentEnded              = "tournament_ended"
+)
+
+// TournamentPlayer is a client registered to a tournament. The seed is given at the start, by rating.
+type TournamentPlayer struct {
+	Client string  `json:"client"`
+	Name   string  `json:"name"`
+	Rating float64 `json:"rating"`
+	Seed   int     `json:"seed,omitempty"`
+}
+
+// TournamentMatch is a game of a round. A match with a single player is a bye, won without playing. Scores are
+// those of the game, 1 for a win, 0 for a loss and 0.5 for a draw.
+type TournamentMatch struct {
+	Round    int                `json:"round"`
+	Room     string             `json:"room,omitempty"`
+	Players  []string           `json:"players"` // clients in seat order
+	Scores   map[string]float64 `json:"scores,omitempty"`
+	Finished bool               `json:"finished"`
+	Note     string             `json:"note,omitempty"` // why the match was not played to the end
+}
+
+// Standing is the position of a player in a tournament. Buchholz, the sum of the points of the opponents,
+// breaks the ties, then the seed.
+type Standing struct {
+	Rank       int     `json:"rank"`
+	Client     string  `json:"client"`
+	Name       string  `json:"name"`
+	Seed       int     `json:"seed"`
+	Points     float64 `json:"points"`
+	Played     int     `json:"played"`
+	Wins       int     `json:"wins"`
+	Draws      int     `json:"draws"`
+	Losses     int     `json:"losses"`
+	Byes       int     `json:"byes"`
+	Buchholz   float64 `json:"buchholz"`
+	Eliminated bool    `json:"eliminated"`
+}
+
+// NewTournamentRequest is the body of a request creating a tournament
+type NewTournamentRequest struct {
+	Name       string `json:"name"`
+	Game       string `json:"game"`
+	Format     string `json:"format"`
+	Rounds     int    `json:"rounds"`      // swiss only, 0 plays enough rounds to tell a winner
+	MaxPlayers int    `json:"max_players"` // 0 means no limit
+}
+
+// Tournament pairs its players in rounds of two player games, each match played in a room of its own
+type Tournament struct {
+	id         string
+	name       string
+	owner      string
+	game       *Game
+	format     string
+	maxPlayers int
+	numRounds  int // rounds to play, fixed at the start for every format but swiss
+	created    int64
+	state      string
+	players    []*TournamentPlayer // in registration order, in seed order once started
+	rounds     [][]*TournamentMatch
+	winner     string
+	events     *eventHub // tournament events and their subscribers
+	mutex      sync.Mutex
+}
+
+// summary describes the tournament without its rounds, the caller holds the mutex
+func (t *Tournament) summary() map[string]any {
+	return map[string]any{
+		"id":          t.id,
+		"name":        t.name,
+		"owner":       t.owner,
+		"game":        t.game.id,
+		"format":      t.format,
+		"state":       t.state,
+		"players":     len(t.players),
+		"max_players": t.maxPlayers,
+		"round":       len(t.rounds),
+		"rounds":      t.numRounds,
+		"winner":      t.winner,
+		"created":     t.created,
+	}
+}
+
+func (t *Tournament) Info() map[string]any {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	// Copies, the response is encoded once the tournament is released
+	players := make([]TournamentPlayer, len(t.players))
+	for i, p := range t.players {
+		players[i] = *p
+	}
+	rounds := make([][]TournamentMatch, len(t.rounds))
+	for i, matches := range t.rounds {
+		rounds[i] = make([]TournamentMatch, len(matches))
+		for j, m := range matches {
+			rounds[i][j] = *m
+		}
+	}
+	info := t.summary()
+	info["registered"] = players
+	info["matches"] = rounds
+	info["standings"] = t.standings()
+	return info
+}
+
+// player returns a registered player, nil if the client is not registered
+func (t *Tournament) player(clientID string) *TournamentPlayer {
+	for _, p := range t.players {
+		if p.Client == clientID {
+			return p
+		}
+	}
+	return nil
+}
+
+// roundComplete tells whether every match of the last round is over, true before the first round
+func (t *Tournament) roundComplete() bool {
+	if len(t.rounds) == 0 {
+		return true
+	}
+	for _, m := range t.rounds[len(t.rounds)-1] {
+		if !m.Finished {
+			return false
+		}
+	}
+	return true
+}
+
+// finishMatch records the scores of a match, a draw when they are missing. It returns false when the match was
+// already over.
+func (t *Tournament) finishMatch(m *TournamentMatch, scores map[string]float64, note string) bool {
+	if m.Finished {
+		return false
+	}
+	if scores == nil {
+		scores = make(map[string]float64, len(m.Players))
+		for _, clientID := range m.Players {
+			scores[clientID] = 0.5
+		}
+	}
+	m.Scores = scores
+	m.Note = note
+	m.Finished = true
+	return true
+}
+
+// advancing returns the player of an elimination match going to the next round: the one with the best score, the
+// best seed on a draw
+func (t *Tournament) advancing(m *TournamentMatch) string {
+	best := ""
+	for _, clientID := range m.Players {
+		if best == "" || m.Scores[clientID] > m.Scores[best] ||
+			(m.Scores[clientID] == m.Scores[best] && t.player(clientID).Seed < t.player(best).Seed) {
+			best = clientID
+		}
+	}
+	return best
+}
+
+// seed sorts the players by rating and fixes the number of rounds. A swiss tournament without a number of rounds
+// plays as many as an elimination bracket of its players.
+func (t *Tournament) seed(rounds int) {
+	sort.SliceStable(t.players, func(i, j int) bool { return t.players[i].Rating > t.players[j].Rating })
+	for i, p := range t.players {
+		p.Seed = i + 1
+	}
+	bracket := 0
+	for size := 1; size < len(t.players); size *= 2 {
+		bracket++
+	}
+	switch t.format {
+	case FormatSingleElimination:
+		t.numRounds = bracket
+	case FormatRoundRobin:
+		t.numRounds = len(t.players) - 1 + len(t.players)%2
+	case FormatSwiss:
+		if t.numRounds = rounds; t.numRounds == 0 {
+			t.numRounds = bracket
+		}
+	}
+}
+
+// nextPairings returns the matches of the next round, nil when the tournament is over
+func (t *Tournament) nextPairings() [][]string {
+	round := len(t.rounds)
+	if round >= t.numRounds {
+		return nil
+	}
+	clients := make([]string, len(t.players))
+	for i, p := range t.players {
+		clients[i] = p.Client
+	}
+
+	switch t.format {
+	case FormatSingleElimination:
+		if round > 0 {
+			previous := t.rounds[round-1]
+			pairs := make([][]string, 0, len(previous)/2)
+			for i := 0; i+1 < len(previous); i += 2 {
+				pairs = append(pairs, []string{t.advancing(previous[i]), t.advancing(previous[i+1])})
+			}
+			return pairs
+		}
+		size := 1 << t.numRounds
+		order := bracketOrder(size)
+		pairs := make([][]string, 0, size/2)
+		for i := 0; i < size; i += 2 {
+			pair := make([]string, 0, 2)
+			for _, seed := range order[i : i+2] {
+				if seed <= len(clients) {
+					pair = append(pair, clients[seed-1])
+				}
+			}
+			pairs = append(pairs, pair)
+		}
+		return pairs
+	case FormatRoundRobin:
+		return roundRobinRound(clients, round)
+	default:
+		met := make(map[string]map[string]bool)
+		byes := make(map[string]bool)
+		for _, matches := range t.rounds {
+			for _, m := range matches {
+				if len(m.Players) == 1 {
+					byes[m.Players[0]] = true
+				}
+				for _, a := range m.Players {
+					for _, b := range m.Players {
+						if met[a] == nil {
+							met[a] = make(map[string]bool)
+						}
+						met[a][b] = a != b
+					}
+				}
+			}
+		}
+		standings := t.standings()
+		ranking := make([]string, len(standings))
+		for i, s := range standings {
+			ranking[i] = s.Client
+		}
+		return swissRound(ranking, met, byes)
+	}
+}
+
+// bracketOrder returns the seeds of an elimination bracket of the given size, a power of two, in bracket order:
+// the first two meet, their winner meets the winner of the next two and so on, the best seeds meet last
+func bracketOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		next := make([]int, 0, 2*len(order))
+		for _, seed := range order {
+			next = append(next, seed, 2*len(order)+1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+// roundRobinRound returns the matches of a round robin round with the circle method: the first player stays, the
+// others turn around it a place every round. With an odd number of players one of them has a bye every round.
+func roundRobinRound(clients []string, round int) [][]string {
+	circle := make([]string, len(clients), len(clients)+1)
+	copy(circle, clients)
+	if len(circle)%2 == 1 {
+		circle = append(circle, "")
+	}
+	n := len(circle)
+	rotated := make([]string, n)
+	rotated[0] = circle[0]
+	for i := 1; i < n; i++ {
+		rotated[i] = circle[1+(i-1+round)%(n-1)]
+	}
+
+	pairs := make([][]string, 0, n/2)
+	for i := 0; i < n/2; i++ {
+		a, b := rotated[i], rotated[n-1-i]
+		// The first player changes seat every round
+		if i == 0 && round%2 == 1 {
+			a, b = b, a
+		}
+		switch {
+		case a == "":
+			pairs = append(pairs, []string{b})
+		case b == "":
+			pairs = append(pairs, []string{a})
+		default:
+			pairs = append(pairs, []string{a, b})
+		}
+	}
+	return pairs
+}
+
+// swissPairingBudget bounds the pairings tried by a swiss round looking for one without rematches
+const swissPairingBudget = 10000
+
+// swissRound pairs the players of a swiss round from the ranking: from the top each player meets the first player
+// below it that it did not meet yet and that leaves the others a pairing without rematches. When there is no such
+// pairing, or it takes too long to find, each player meets the first one below it that it did not meet, or the
+// first one below when it met them all. With an odd number of players the lowest ranked one without a bye gets one.
+func swissRound(ranking []string, met map[string]map[string]bool, byes map[string]bool) [][]string {
+	pool := make([]string, len(ranking))
+	copy(pool, ranking)
+	var bye []string
+	if len(pool)%2 == 1 {
+		i := len(pool) - 1
+		for ; i > 0 && byes[pool[i]]; i-- {
+		}
+		bye = []string{pool[i]}
+		pool = append(pool[:i], pool[i+1:]...)
+	}
+
+	budget := swissPairingBudget
+	pairs, ok := pairUnmet(pool, met, &budget)
+	if !ok {
+		pairs = make([][]string, 0, len(pool)/2+1)
+		for len(pool) > 1 {
+			opponent := 1
+			for i := 1; i < len(pool); i++ {
+				if !met[pool[0]][pool[i]] {
+					opponent = i
+					break
+				}
+			}
+			pairs = append(pairs, []string{pool[0], pool[opponent]})
+			pool = append(pool[1:opponent], pool[opponent+1:]...)
+		}
+	}
+	if bye != nil {
+		pairs = append(pairs, bye)
+	}
+	return pairs
+}
+
+// pairUnmet pairs the pool without rematches, backtracking when the players left cannot be paired. False is
+// returned when there is no such pairing or the budget runs out.
+func pairUnmet(pool []string, met map[string]map[string]bool, budget *int) ([][]string, bool) {
+	if len(pool) == 0 {
+		return [][]string{}, true
+	}
+	for i := 1; i < len(pool); i++ {
+		if met[pool[0]][pool[i]] {
+			continue
+		}
+		if *budget--; *budget < 0 {
+			return nil, false
+		}
+		rest := make([]string, 0, len(pool)-2)
+		rest = append(rest, pool[1:i]...)
+		rest = append(rest, pool[i+1:]...)
+		if pairs, ok := pairUnmet(rest, met, budget); ok {
+			return append([][]string{{pool[0], pool[i]}}, pairs...), true
+		}
+	}
+	return nil, false
+}
+
+// standings ranks the players on the finished matches, the caller holds the mutex
+func (t *Tournament) standings() []Standing {
+	byClient := make(map[string]*Standing, len(t.players))
+	standings := make([]*Standing, 0, len(t.players))
+	for _, p := range t.players {
+		s := &Standing{Client: p.Client, Name: p.Name, Seed: p.Seed}
+		byClient[p.Client] = s
+		standings = append(standings, s)
+	}
+
+	opponents := make(map[string][]string)
+	for _, matches := range t.rounds {
+		for _, m := range matches {
+			if !m.Finished {
+				continue
+			}
+			if len(m.Players) == 1 {
+				byClient[m.Players[0]].Points++
+				byClient[m.Players[0]].Byes++
+				continue
+			}
+			a, b := m.Players[0], m.Players[1]
+			for _, pair := range [][2]string{{a, b}, {b, a}} {
+				s := byClient[pair[0]]
+				s.Played++
+				s.Points += m.Scores[pair[0]]
+				switch {
+				case m.Scores[pair[0]] > m.Scores[pair[1]]:
+					s.Wins++
+				case m.Scores[pair[0]] < m.Scores[pair[1]]:
+					s.Losses++
+				default:
+					s.Draws++
+				}
+				opponents[pair[0]] = append(opponents[pair[0]], pair[1])
+			}
+			if t.format == FormatSingleElimination {
+				winner := t.advancing(m)
+				for _, clientID := range m.Players {
+					byClient[clientID].Eliminated = clientID != winner
+				}
+			}
+		}
+	}
+	for _, s := range standings {
+		for _, opponent := range opponents[s.Client] {
+			s.Buchholz += byClient[opponent].Points
+		}
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		switch {
+		case a.Eliminated != b.Eliminated:
+			return !a.Eliminated
+		case a.Points != b.Points:
+			return a.Points > b.Points
+		case a.Buchholz != b.Buchholz:
+			return a.Buchholz > b.Buchholz
+		default:
+			return a.Seed < b.Seed
+		}
+	})
+	result := make([]Standing, len(standings))
+	for i, s := range standings {
+		s.Rank = i + 1
+		result[i] = *s
+	}
+	return result
+}
+
+// finish closes the tournament and names the winner, the caller holds the mutex
+func (t *Tournament) finish() {
+	t.state = TournamentFinished
+	if standings := t.standings(); len(standings) > 0 {
+		t.winner = standings[0].Client
+	}
+	if t.format == FormatSingleElimination && len(t.rounds) > 0 {
+		if final := t.rounds[len(t.rounds)-1]; len(final) == 1 {
+			t.winner = t.advancing(final[0])
+		}
+	}
+}
+
+// newTournament creates a tournament for a two player game, invalid requests are reported as CommandErrors
+func (e *Engine) newTournament(owner string, req NewTournamentRequest) (*Tournament, *CommandError) {
+	fields := make([]FieldError, 0)
+	game, err := e.searchGame(req.Game)
+	if err != nil {
+		fields = append(fields, FieldError{Path: "game", Message: "unknown game"})
+	}
+	switch req.Format {
+	case FormatSingleElimination, FormatRoundRobin, FormatSwiss:
+	default:
+		fields = append(fields, FieldError{Path: "format", Message: "must be one of single_elimination, round_robin, swiss"})
+	}
+	if req.Rounds < 0 || (req.Rounds > 0 && req.Format != FormatSwiss) {
+		fields = append(fields, FieldError{Path: "rounds", Message: "must be a positive integer, for swiss tournaments only"})
+	}
+	if req.MaxPlayers < 0 || req.MaxPlayers == 1 {
+		fields = append(fields, FieldError{Path: "max_players", Message: "must be at least 2, or 0 for no limit"})
+	}
+	if len(fields) > 0 {
+		return nil, &CommandError{Status: http.StatusBadRequest, Message: "invalid payload", Fields: fields}
+	}
+	if game.numPlayers != 2 {
+		return nil, &CommandError{Status: http.StatusUnprocessableEntity, Message: "tournaments need a two player game"}
+	}
+	name := req.Name
+	if name == "" {
+		name = "tournament " + game.name
+	}
+
+	e.tournamentsMutex.Lock()
+	defer e.tournamentsMutex.Unlock()
+	t := &Tournament{
+		id:         e.generateTournamentUniqueID(),
+		name:       name,
+		owner:      owner,
+		game:       game,
+		format:     req.Format,
+		maxPlayers: req.MaxPlayers,
+		numRounds:  req.Rounds,
+		created:    time.Now().Unix(),
+		state:      TournamentRegistering,
+		players:    make([]*TournamentPlayer, 0),
+		rounds:     make([][]*TournamentMatch, 0),
+		mutex:      sync.Mutex{},
+	}
+	t.events = newEventHub("", e.EventBufferSize)
+	e.tournaments[t.id] = t
+	return t, nil
+}
+
+func (e *Engine) generateTournamentUniqueID() string {
+	for {
+		newId := randStringBytes(16)
+		if _, exists := e.tournaments[newId]; !exists {
+			return newId
+		}
+	}
+}
+
+func (e *Engine) searchTournament(id string) (*Tournament, error) {
+	e.tournamentsMutex.RLock()
+	defer e.tournamentsMutex.RUnlock()
+	if t, exists := e.tournaments[id]; exists {
+		return t, nil
+	}
+	return nil, errors.New("tournament not found")
+}
+
+// listTournaments describes the tournaments, sorted by id
+func (e *Engine) listTournaments() []map[string]any {
+	e.tournamentsMutex.RLock()
+	tournaments := make([]*Tournament, 0, len(e.tournaments))
+	for _, t := range e.tournaments {
+		tournaments = append(tournaments, t)
+	}
+	e.tournamentsMutex.RUnlock()
+	sort.Slice(tournaments, func(i, j int) bool { return tournaments[i].id < tournaments[j].id })
+
+	infos := make([]map[string]any, 0, len(tournaments))
+	for _, t := range tournaments {
+		t.mutex.Lock()
+		infos = append(infos, t.summary())
+		t.mutex.Unlock()
+	}
+	return infos
+}
+
+// registerPlayer adds a client to the players of a tournament still open to registration
+func (e *Engine) registerPlayer(t *Tournament, client *Client) *CommandError {
+	rating := e.ratings.get(t.game.id, client.id, e.InitialRating)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.state != TournamentRegistering {
+		return &CommandError{Status: http.StatusConflict, Message: "registration is closed"}
+	}
+	if t.player(client.id) != nil {
+		return &CommandError{Status: http.StatusConflict, Message: "already registered"}
+	}
+	if t.maxPlayers > 0 && len(t.players) >= t.maxPlayers {
+		return &CommandError{Status: http.StatusForbidden, Message: "tournament is full"}
+	}
+	p := &TournamentPlayer{Client: client.id, Name: client.name, Rating: rating.Rating}
+	t.players = append(t.players, p)
+	t.events.emit(TournamentPlayerRegistered, client.id, map[string]any{"tournament": t.id, "player": p})
+	return nil
+}
+
+// unregisterPlayer removes a client from the players of a tournament that did not start yet
+func (e *Engine) unregisterPlayer(t *Tournament, clientID string) *CommandError {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.state != TournamentRegistering {
+		return &CommandError{Status: http.StatusConflict, Message: "tournament already started"}
+	}
+	for i, p := range t.players {
+		if p.Client == clientID {
+			t.players = append(t.players[:i], t.players[i+1:]...)
+			t.events.emit(TournamentPlayerUnregistered, clientID, map[string]any{"tournament": t.id, "client": clientID})
+			return nil
+		}
+	}
+	return &CommandError{Status: http.StatusNotFound, Message: "not registered"}
+}
+
+// startTournament closes the registration, seeds the players and starts the first round
+func (e *Engine) startTournament(t *Tournament) *CommandError {
+	t.mutex.Lock()
+	if t.state != TournamentRegistering {
+		t.mutex.Unlock()
+		return &CommandError{Status: http.StatusConflict, Message: "tournament already started"}
+	}
+	if len(t.players) < 2 {
+		t.mutex.Unlock()
+		return &CommandError{Status: http.StatusConflict, Message: "not enough players"}
+	}
+	t.seed(t.numRounds)
+	t.state = TournamentRunning
+	t.mutex.Unlock()
+
+	if e.Debug {
+		l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/startTournament]")+" ", 0)
+		l.Printf("Tournament %s started with %d players", t.id, len(t.players))
+	}
+	e.startRound(t)
+	return nil
+}
+
+// startRound pairs the players of the next round and seats them in the rooms of their matches, or ends the
+// tournament when every round was played. Nothing happens while a match of the current round is on.
+func (e *Engine) startRound(t *Tournament) {
+	t.mutex.Lock()
+	if t.state != TournamentRunning || !t.roundComplete() {
+		t.mutex.Unlock()
+		return
+	}
+	pairs := t.nextPairings()
+	if pairs == nil {
+		t.finish()
+		if e.Debug {
+			l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, green("[rulemancer/startRound]")+" ", 0)
+			l.Printf("Tournament %s won by %s", t.id, t.winner)
+		}
+		t.events.emit(TournamentEnded, "", map[string]any{"tournament": t.id, "winner": t.winner, "standings": t.standings()})
+		t.mutex.Unlock()
+		return
+	}
+	round := len(t.rounds) + 1
+	matches := make([]*TournamentMatch, 0, len(pairs))
+	for _, pair := range pairs {
+		m := &TournamentMatch{Round: round, Players: pair}
+		if len(pair) == 1 {
+			t.finishMatch(m, map[string]float64{pair[0]: 1}, "bye")
+		}
+		matches = append(matches, m)
+	}
+	t.rounds = append(t.rounds, matches)
+	t.mutex.Unlock()
+
+	// The rooms are created without holding the tournament, loading the rules takes a while
+	for _, m := range matches {
+		if m.Finished {
+			continue
+		}
+		if err := e.tournamentRoom(t, m); err != nil {
+			if e.Debug {
+				l := log.New(&writer{os.Stdout, "2006-01-02 15:04:05 "}, red("[rulemancer/startRound]")+" ", 0)
+				l.Printf("Failed to set up a match of tournament %s: %v", t.id, err)
+			}
+			t.mutex.Lock()
+			t.finishMatch(m, nil, "failed to set up the room")
+			t.mutex.Unlock()
+		}
+	}
+
+	t.mutex.Lock()
+	t.events.emit(TournamentRoundStarted, "", map[string]any{"tournament": t.id, "round": round, "matches": matches})
+	complete := t.roundComplete()
+	t.mutex.Unlock()
+	if complete {
+		e.startRound(t)
+	}
+}
+
+// tournamentRoom creates the room of a match and seats its players
+func (e *Engine) tournamentRoom(t *Tournament, m *TournamentMatch) error {
+	room, err := e.newRoom(fmt.Sprintf("%s round %d", t.name, m.Round), "Room of a match of tournament "+t.id, t.game.id, "", seatedRoomSettings())
+	if err != nil {
+		return err
+	}
+	for _, clientID := range m.Players {
+		client, err := e.searchClient(clientID)
+		if err != nil {
+			e.removeRoom(room.id)
+			return err
+		}
+		if ce := e.seatClient(room, client); ce != nil {
+			e.removeRoom(room.id)
+			return ce
+		}
+	}
+
+	// The game cannot end before its players are seated, the match is tied to the room only now so that a room
+	// that failed to be set up does not end it
+	room.clientsMutex.Lock()
+	room.tournament = t
+	room.tournamentMatch = m
+	room.clientsMutex.Unlock()
+	t.mutex.Lock()
+	m.Room = room.id
+	t.mutex.Unlock()
+	return nil
+}
+
+// endTournamentMatch records the scores of the match played in a room, a draw when they are missing, and starts
+// the next round once every match of the round is over
+func (e *Engine) endTournamentMatch(room *Room, scores map[string]float64, note string) {
+	room.clientsMutex.RLock()
+	t, m := room.tournament, room.tournamentMatch
+	room.clientsMutex.RUnlock()
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	recorded := t.finishMatch(m, scores, note)
+	if recorded {
+		t.events.emit(TournamentMatchEnded, "", map[string]any{"tournament": t.id, "match": m, "standings": t.standings()})
+	}
+	complete := recorded && t.roundComplete()
+	t.mutex.Unlock()
+	if complete {
+		go e.startRound(t)
+	}
+}
+
+// tournamentID returns the id of the tournament of the room, empty for rooms outside tournaments. The caller holds
+// clientsMutex.
+func (r *Room) tournamentID() string {
+	if r.tournament == nil {
+		return ""
+	}
+	return r.tournament.id
+}
+
+// snapshot returns the full state a tournament subscriber starts again from
+func (t *Tournament) snapshot(ctx context.Context) (any, error) {
+	return t.Info(), nil
+}
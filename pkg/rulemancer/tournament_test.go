@@ -0,0 +1,238 @@
+package rulemancer
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// newTestTournament returns a started tournament of the given format whose players are seeded in the order p1, p2...
+func newTestTournament(format string, players, rounds int) *Tournament {
+	t := &Tournament{id: "t1", format: format, game: &Game{id: "g1"}, state: TournamentRunning}
+	for i := 1; i <= players; i++ {
+		t.players = append(t.players, &TournamentPlayer{Client: fmt.Sprintf("p%d", i), Rating: float64(2000 - i)})
+	}
+	t.seed(rounds)
+	return t
+}
+
+// playTournament plays every round as startRound does, without rooms, the better seed wins unless result says
+// otherwise
+func playTournament(t *Tournament, result func(m *TournamentMatch) map[string]float64) {
+	for pairs := t.nextPairings(); pairs != nil; pairs = t.nextPairings() {
+		matches := make([]*TournamentMatch, 0, len(pairs))
+		for _, pair := range pairs {
+			m := &TournamentMatch{Round: len(t.rounds) + 1, Players: pair}
+			switch {
+			case len(pair) == 1:
+				t.finishMatch(m, map[string]float64{pair[0]: 1}, "bye")
+			case result != nil && result(m) != nil:
+				t.finishMatch(m, result(m), "")
+			case t.player(pair[0]).Seed < t.player(pair[1]).Seed:
+				t.finishMatch(m, map[string]float64{pair[0]: 1, pair[1]: 0}, "")
+			default:
+				t.finishMatch(m, map[string]float64{pair[0]: 0, pair[1]: 1}, "")
+			}
+			matches = append(matches, m)
+		}
+		t.rounds = append(t.rounds, matches)
+	}
+	t.finish()
+}
+
+func TestBracketOrder(t *testing.T) {
+	if order := bracketOrder(8); !reflect.DeepEqual(order, []int{1, 8, 4, 5, 2, 7, 3, 6}) {
+		t.Errorf("unexpected bracket %v", order)
+	}
+	if order := bracketOrder(2); !reflect.DeepEqual(order, []int{1, 2}) {
+		t.Errorf("unexpected bracket %v", order)
+	}
+}
+
+func TestSingleElimination(t *testing.T) {
+	tr := newTestTournament(FormatSingleElimination, 5, 0)
+	if tr.numRounds != 3 {
+		t.Fatalf("expected 3 rounds for 5 players, got %d", tr.numRounds)
+	}
+	first := tr.nextPairings()
+	byes := 0
+	for _, pair := range first {
+		if len(pair) == 1 {
+			byes++
+		}
+	}
+	if len(first) != 4 || byes != 3 {
+		t.Errorf("expected 4 matches with 3 byes, got %v", first)
+	}
+
+	// The fifth seed beats the fourth, then the better seed wins
+	playTournament(tr, func(m *TournamentMatch) map[string]float64 {
+		if reflect.DeepEqual(m.Players, []string{"p4", "p5"}) {
+			return map[string]float64{"p4": 0, "p5": 1}
+		}
+		return nil
+	})
+	if tr.state != TournamentFinished || tr.winner != "p1" {
+		t.Errorf("expected p1 to win, got %s (%s)", tr.winner, tr.state)
+	}
+	standings := tr.standings()
+	if standings[0].Client != "p1" || standings[0].Eliminated {
+		t.Errorf("expected p1 on top, got %+v", standings[0])
+	}
+	for _, s := range standings {
+		if s.Client == "p4" && !s.Eliminated {
+			t.Errorf("p4 lost and must be eliminated")
+		}
+	}
+}
+
+func TestEliminationDraw(t *testing.T) {
+	tr := newTestTournament(FormatSingleElimination, 2, 0)
+	playTournament(tr, func(m *TournamentMatch) map[string]float64 {
+		return map[string]float64{"p1": 0.5, "p2": 0.5}
+	})
+	if tr.winner != "p1" {
+		t.Errorf("the best seed goes on after a draw, got %s", tr.winner)
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	for _, players := range []int{4, 5} {
+		t.Run(fmt.Sprintf("%d players", players), func(t *testing.T) {
+			tr := newTestTournament(FormatRoundRobin, players, 0)
+			playTournament(tr, nil)
+
+			met := make(map[string]int)
+			byes := make(map[string]int)
+			for _, matches := range tr.rounds {
+				for _, m := range matches {
+					if len(m.Players) == 1 {
+						byes[m.Players[0]]++
+						continue
+					}
+					a, b := m.Players[0], m.Players[1]
+					if a > b {
+						a, b = b, a
+					}
+					met[a+"-"+b]++
+				}
+			}
+			if len(met) != players*(players-1)/2 {
+				t.Errorf("expected every pair to meet, got %v", met)
+			}
+			for pair, n := range met {
+				if n != 1 {
+					t.Errorf("%s met %d times", pair, n)
+				}
+			}
+			if players%2 == 1 && len(byes) != players {
+				t.Errorf("expected a bye for every player, got %v", byes)
+			}
+
+			standings := tr.standings()
+			if tr.winner != "p1" || standings[0].Wins != players-1 {
+				t.Errorf("expected p1 to win every game, got %s %+v", tr.winner, standings[0])
+			}
+		})
+	}
+}
+
+func TestSwiss(t *testing.T) {
+	tr := newTestTournament(FormatSwiss, 6, 3)
+	playTournament(tr, nil)
+	if len(tr.rounds) != 3 {
+		t.Fatalf("expected 3 rounds, got %d", len(tr.rounds))
+	}
+	met := make(map[string]bool)
+	for _, matches := range tr.rounds {
+		for _, m := range matches {
+			key := fmt.Sprint(m.Players)
+			if met[key] {
+				t.Errorf("rematch %v", m.Players)
+			}
+			met[key] = true
+			met[fmt.Sprint([]string{m.Players[1], m.Players[0]})] = true
+		}
+	}
+	if standings := tr.standings(); standings[0].Client != "p1" || standings[0].Points != 3 {
+		t.Errorf("expected p1 on top with 3 points, got %+v", standings[0])
+	}
+
+	pairs := swissRound([]string{"a", "b", "c"}, nil, map[string]bool{"c": true})
+	if !reflect.DeepEqual(pairs, [][]string{{"a", "c"}, {"b"}}) {
+		t.Errorf("expected the bye for b, c already had one, got %v", pairs)
+	}
+	metAB := map[string]map[string]bool{"a": {"b": true}, "b": {"a": true}}
+	if pairs := swissRound([]string{"a", "b"}, metAB, nil); !reflect.DeepEqual(pairs, [][]string{{"a", "b"}}) {
+		t.Errorf("expected a rematch when there is no other pairing, got %v", pairs)
+	}
+}
+
+func TestTournamentLifecycle(t *testing.T) {
+	e := newTestEngine(duelTestGame())
+	e.games["g3"] = &Game{id: "g3", name: "trio", numPlayers: 3}
+
+	invalid := []NewTournamentRequest{
+		{Game: "nope", Format: FormatSwiss},
+		{Game: "g1", Format: "ladder"},
+		{Game: "g1", Format: FormatRoundRobin, Rounds: 3},
+		{Game: "g1", Format: FormatSwiss, MaxPlayers: 1},
+	}
+	for _, req := range invalid {
+		if _, ce := e.newTournament("alice", req); ce == nil || ce.Status != http.StatusBadRequest {
+			t.Errorf("expected %+v to be refused, got %v", req, ce)
+		}
+	}
+	if _, ce := e.newTournament("alice", NewTournamentRequest{Game: "g3", Format: FormatSwiss}); ce == nil || ce.Status != http.StatusUnprocessableEntity {
+		t.Errorf("expected a three player game to be refused, got %v", ce)
+	}
+
+	tr, ce := e.newTournament("alice", NewTournamentRequest{Game: "duel", Format: FormatSingleElimination, MaxPlayers: 2})
+	if ce != nil {
+		t.Fatalf("unexpected error: %v", ce)
+	}
+	alice, bob, carol := e.newClient("alice", ""), e.newClient("bob", ""), e.newClient("carol", "")
+	if ce := e.startTournament(tr); ce == nil || ce.Status != http.StatusConflict {
+		t.Errorf("a tournament without players cannot start, got %v", ce)
+	}
+	for _, c := range []*Client{alice, bob} {
+		if ce := e.registerPlayer(tr, c); ce != nil {
+			t.Fatalf("unexpected error: %v", ce)
+		}
+	}
+	if ce := e.registerPlayer(tr, alice); ce == nil || ce.Status != http.StatusConflict {
+		t.Errorf("expected a second registration to be refused, got %v", ce)
+	}
+	if ce := e.registerPlayer(tr, carol); ce == nil || ce.Status != http.StatusForbidden {
+		t.Errorf("expected a full tournament, got %v", ce)
+	}
+
+	if ce := e.startTournament(tr); ce != nil {
+		t.Fatalf("unexpected error: %v", ce)
+	}
+	if ce := e.unregisterPlayer(tr, bob.id); ce == nil || ce.Status != http.StatusConflict {
+		t.Errorf("expected the registration to be closed, got %v", ce)
+	}
+	tr.mutex.Lock()
+	m := tr.rounds[0][0]
+	tr.mutex.Unlock()
+	room, err := e.searchRoom(m.Room)
+	if err != nil {
+		t.Fatalf("the match room was not created: %v", err)
+	}
+	if len(room.clients) != 2 {
+		t.Errorf("expected both players seated, got %d", len(room.clients))
+	}
+	if room.openToStrangers() || room.admit(carol.id, RoomCredentials{}, false) == nil {
+		t.Errorf("the match room must be kept to its players")
+	}
+
+	// The final is removed before it ends, it counts as a draw and the best seed wins the tournament
+	e.removeRoom(room.id)
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+	if !m.Finished || m.Scores[alice.id] != 0.5 || m.Note != "room removed" {
+		t.Errorf("expected the match drawn, got %+v", m)
+	}
+}
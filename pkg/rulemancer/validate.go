@@ -0,0 +1,123 @@
+package rulemancer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FieldError describes a single problem found in an assert payload, Path locates the offending field
+// using the relation name, the item index (for list payloads) and the slot name, e.g. "move[0].x"
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"error"`
+}
+
+// validateAssertion checks an assert payload against the deftemplate schema of the relations involved in the
+// assertion. Every relation of the assertion must be present, no other relation is allowed and each item must
+// only use the slots and multislots declared for its relation. Slots take exactly one value, multislots any
+// number of values and can be omitted. Every value must match the type, allowed values and range constraints of
+// its slot. Relations whose deftemplate is unknown are only checked for presence.
+func (g *Game) validateAssertion(assertion string, raw map[string]json.RawMessage) []FieldError {
+	errs := make([]FieldError, 0)
+
+	relList, ok := g.assertable[assertion]
+	if !ok {
+		return append(errs, FieldError{Path: assertion, Message: "unknown assertion"})
+	}
+
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !isInSlice(relList, key) {
+			errs = append(errs, FieldError{Path: key, Message: "unexpected relation for assertion " + assertion})
+		}
+	}
+
+	for _, rel := range relList {
+		body, exists := raw[rel]
+		if !exists {
+			errs = append(errs, FieldError{Path: rel, Message: "missing relation"})
+			continue
+		}
+
+		var items []map[string]json.RawMessage
+		var item map[string]json.RawMessage
+		if err := json.Unmarshal(body, &items); err == nil {
+			for i, it := range items {
+				errs = append(errs, g.validateRelationItem(rel, fmt.Sprintf("%s[%d]", rel, i), it)...)
+			}
+		} else if err := json.Unmarshal(body, &item); err == nil {
+			errs = append(errs, g.validateRelationItem(rel, rel, item)...)
+		} else {
+			errs = append(errs, FieldError{Path: rel, Message: "must be an object or a list of objects"})
+		}
+	}
+
+	return errs
+}
+
+// validateRelationItem checks a single fact payload of the given relation. Slot names and values are pasted as
+// they are in the asserted fact, so they must be single CLIPS atoms even when the deftemplate is unknown.
+func (g *Game) validateRelationItem(rel, path string, item map[string]json.RawMessage) []FieldError {
+	errs := make([]FieldError, 0)
+
+	// Without a known deftemplate only the atoms are checked
+	tmpl, known := g.templates[rel]
+	known = known && len(tmpl.Slots) > 0
+
+	fields := make([]string, 0, len(item))
+	for field := range item {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		fieldPath := path + "." + field
+		var slot *SlotSchema
+		if known {
+			if slot = tmpl.Slot(field); slot == nil {
+				errs = append(errs, FieldError{Path: fieldPath, Message: "unknown slot"})
+				continue
+			}
+		} else if !isClipsAtom(field) || strings.HasPrefix(field, `"`) {
+			errs = append(errs, FieldError{Path: fieldPath, Message: "invalid slot name"})
+			continue
+		}
+
+		var values []string
+		if err := json.Unmarshal(item[field], &values); err != nil {
+			errs = append(errs, FieldError{Path: fieldPath, Message: "must be a list of strings"})
+			continue
+		}
+
+		if slot != nil && !slot.Multi && len(values) != 1 {
+			errs = append(errs, FieldError{Path: fieldPath, Message: fmt.Sprintf("slot expects exactly one value, got %d", len(values))})
+			continue
+		}
+
+		for _, value := range values {
+			if !isClipsAtom(value) {
+				errs = append(errs, FieldError{Path: fieldPath, Message: fmt.Sprintf("value %q is not a single CLIPS value", value)})
+			} else if slot != nil {
+				if msg := slot.checkValue(value); msg != "" {
+					errs = append(errs, FieldError{Path: fieldPath, Message: msg})
+				}
+			}
+		}
+	}
+
+	if known {
+		for _, slot := range tmpl.RequiredSlotNames() {
+			if _, exists := item[slot]; !exists {
+				errs = append(errs, FieldError{Path: path + "." + slot, Message: "missing slot"})
+			}
+		}
+	}
+
+	return errs
+}
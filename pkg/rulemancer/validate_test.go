@@ -0,0 +1,172 @@
+package rulemancer
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestValidateAssertion(t *testing.T) {
+	game := &Game{
+		assertable: map[string][]string{
+			"move":  {"move"},
+			"play":  {"play-card"},
+			"chant": {"chant"},
+			"pass":  {"pass"},
+		},
+		templates: map[string]*TemplateSchema{
+			"move": {Name: "move", Slots: []SlotSchema{
+				{Name: "x", Types: []string{"INTEGER"}, Range: &SlotRange{Min: "1", Max: "3"}},
+				{Name: "y", Types: []string{"INTEGER"}, Range: &SlotRange{Min: "1", Max: "3"}},
+				{Name: "player", Types: []string{"SYMBOL"}, AllowedValues: []string{"x", "o"}},
+			}},
+			"play-card": {Name: "play-card", Slots: []SlotSchema{
+				{Name: "card"},
+				{Name: "targets", Multi: true},
+			}},
+			"chant": {Name: "chant", Slots: []SlotSchema{}},
+			"pass": {Name: "pass", Slots: []SlotSchema{
+				{Name: "player", Types: []string{"SYMBOL"}, DefaultType: "none"},
+				{Name: "reason", Types: []string{"SYMBOL"}, DefaultType: "static", Default: []string{"nil"}},
+				{Name: "time", Types: []string{"INTEGER"}, DefaultType: "dynamic"},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		assertion string
+		payload   string
+		want      []FieldError
+	}{
+		{
+			name:      "valid list payload",
+			assertion: "move",
+			payload:   `{"move": [{"x": ["1"], "y": ["2"], "player": ["x"]}]}`,
+			want:      []FieldError{},
+		},
+		{
+			name:      "valid object payload with multislot",
+			assertion: "play",
+			payload:   `{"play-card": {"card": ["bear"], "targets": ["a", "b"]}}`,
+			want:      []FieldError{},
+		},
+		{
+			name:      "multislot can be omitted",
+			assertion: "play",
+			payload:   `{"play-card": {"card": ["bear"]}}`,
+			want:      []FieldError{},
+		},
+		{
+			name:      "defaulted slots can be omitted",
+			assertion: "pass",
+			payload:   `{"pass": {"player": ["x"]}}`,
+			want:      []FieldError{},
+		},
+		{
+			name:      "slot without default is required",
+			assertion: "pass",
+			payload:   `{"pass": {"reason": ["tired"]}}`,
+			want:      []FieldError{{Path: "pass.player", Message: "missing slot"}},
+		},
+		{
+			name:      "unknown assertion",
+			assertion: "jump",
+			payload:   `{}`,
+			want:      []FieldError{{Path: "jump", Message: "unknown assertion"}},
+		},
+		{
+			name:      "missing relation and unexpected relation",
+			assertion: "move",
+			payload:   `{"mvoe": [{"x": ["1"]}]}`,
+			want: []FieldError{
+				{Path: "mvoe", Message: "unexpected relation for assertion move"},
+				{Path: "move", Message: "missing relation"},
+			},
+		},
+		{
+			name:      "unknown and missing slots",
+			assertion: "move",
+			payload:   `{"move": [{"x": ["1"], "z": ["2"], "player": ["x"]}]}`,
+			want: []FieldError{
+				{Path: "move[0].z", Message: "unknown slot"},
+				{Path: "move[0].y", Message: "missing slot"},
+			},
+		},
+		{
+			name:      "slot with multiple values",
+			assertion: "move",
+			payload:   `{"move": [{"x": ["1"], "y": ["2", "3"], "player": ["x"]}, {"x": [], "y": ["2"], "player": ["o"]}]}`,
+			want: []FieldError{
+				{Path: "move[0].y", Message: "slot expects exactly one value, got 2"},
+				{Path: "move[1].x", Message: "slot expects exactly one value, got 0"},
+			},
+		},
+		{
+			name:      "values not a list of strings",
+			assertion: "play",
+			payload:   `{"play-card": {"card": "bear"}}`,
+			want:      []FieldError{{Path: "play-card.card", Message: "must be a list of strings"}},
+		},
+		{
+			name:      "relation not an object",
+			assertion: "move",
+			payload:   `{"move": "x 1"}`,
+			want:      []FieldError{{Path: "move", Message: "must be an object or a list of objects"}},
+		},
+		{
+			name:      "values violating type, allowed values and range",
+			assertion: "move",
+			payload:   `{"move": {"x": ["a"], "y": ["4"], "player": ["z"]}}`,
+			want: []FieldError{
+				{Path: "move.player", Message: "value z is not one of x o"},
+				{Path: "move.x", Message: "value a has type SYMBOL, expected INTEGER"},
+				{Path: "move.y", Message: "value 4 is above the maximum 3"},
+			},
+		},
+		{
+			name:      "relation without known template",
+			assertion: "chant",
+			payload:   `{"chant": {"anything": ["goes"]}}`,
+			want:      []FieldError{},
+		},
+		{
+			name:      "value closing the slot and asserting another fact",
+			assertion: "move",
+			payload:   `{"move": {"x": ["1"], "y": ["2"], "player": ["x) (winner (player x"]}}`,
+			want:      []FieldError{{Path: "move.player", Message: `value "x) (winner (player x" is not a single CLIPS value`}},
+		},
+		{
+			name:      "untyped slot with a variable and an unbalanced string",
+			assertion: "play",
+			payload:   `{"play-card": {"card": ["?x"], "targets": ["\"a\" b\"", "$?rest"]}}`,
+			want: []FieldError{
+				{Path: "play-card.card", Message: `value "?x" is not a single CLIPS value`},
+				{Path: "play-card.targets", Message: `value "\"a\" b\"" is not a single CLIPS value`},
+				{Path: "play-card.targets", Message: `value "$?rest" is not a single CLIPS value`},
+			},
+		},
+		{
+			name:      "relation without known template is still made of atoms",
+			assertion: "chant",
+			payload:   `{"chant": {"any thing": ["goes"], "words": ["la) (winner (player x"]}}`,
+			want: []FieldError{
+				{Path: "chant.any thing", Message: "invalid slot name"},
+				{Path: "chant.words", Message: `value "la) (winner (player x" is not a single CLIPS value`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var raw map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(tt.payload), &raw); err != nil {
+				t.Fatalf("invalid test payload: %v", err)
+			}
+			got := game.validateAssertion(tt.assertion, raw)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validateAssertion() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,23 +1,174 @@
 #include "clips.h"
 
+#define RULEMANCER_OUTPUT_DATA USER_ENVIRONMENT_DATA + 0
+#define RULEMANCER_OUTPUT_MAX 65536
+
+struct outputData {
+    StringBuilder *sb;
+    bool errors; // capture the error and warning channels too
+};
+
+#define OutputData(env) ((struct outputData *) GetEnvironmentData(env, RULEMANCER_OUTPUT_DATA))
+
+// The output router captures every logical name but the standard input and the error and warning channels,
+// which are left to CLIPS unless an evaluation is in progress. It is only active while rules are running or an
+// expression is evaluated.
+static bool output_query(Environment *env, const char *logicalName, void *context) {
+    if (strcmp(logicalName, STDIN) == 0) return false;
+    if (OutputData(env)->errors) return true;
+    if (strcmp(logicalName, STDERR) == 0) return false;
+    if (strcmp(logicalName, STDWRN) == 0) return false;
+    return true;
+}
+
+// Every write is recorded as "logical name \x1f text \x1e", output beyond RULEMANCER_OUTPUT_MAX is dropped
+static void output_write(Environment *env, const char *logicalName, const char *str, void *context) {
+    StringBuilder *sb = OutputData(env)->sb;
+    if (sb->length > RULEMANCER_OUTPUT_MAX) return;
+
+    SBAppend(sb, logicalName);
+    SBAppend(sb, "\x1f");
+    SBAppend(sb, str);
+    SBAppend(sb, "\x1e");
+}
+
+static void output_cleanup(Environment *env) {
+    if (OutputData(env)->sb != NULL) {
+        SBDispose(OutputData(env)->sb);
+    }
+}
+
 void* clips_create() {
-    return CreateEnvironment();
+    Environment *env = CreateEnvironment();
+
+    AllocateEnvironmentData(env, RULEMANCER_OUTPUT_DATA, sizeof(struct outputData), output_cleanup);
+    OutputData(env)->sb = CreateStringBuilder(env, 1024);
+    OutputData(env)->errors = false;
+    AddRouter(env, "rulemancer-output", 40, output_query, output_write, NULL, NULL, NULL, NULL);
+    DeactivateRouter(env, "rulemancer-output");
+
+    // Environments share the server process, neither the rules nor the admin eval may end it
+    RemoveUDF(env, "exit");
+
+    return env;
 }
 
 void clips_destroy(void* env) {
     DestroyEnvironment(env);
 }
 
-void clips_load(void* env, const char* file) {
-    Load(env, file);
+// Every parser error is written as a record terminated by \x1e, the fields of the record are separated by \x1f:
+// file, line, error text.
+static void collect_parser_error(Environment *env, const char *fileName, const char *warningString,
+                                 const char *errorString, long lineNumber, void *context) {
+    StringBuilder *sb = (StringBuilder *) context;
+
+    if (errorString == NULL) return;
+
+    SBAppend(sb, fileName ? fileName : "");
+    SBAppend(sb, "\x1f");
+    SBAppendInteger(sb, lineNumber);
+    SBAppend(sb, "\x1f");
+    SBAppend(sb, errorString);
+    SBAppend(sb, "\x1e");
 }
 
+// clips_load loads a constructs file, it returns NULL on success or the collected errors otherwise.
+char *clips_load(void* env, const char* file) {
+    StringBuilder *sb = CreateStringBuilder(env, 256);
+    if (!sb) return NULL;
+
+    SetParserErrorCallback(env, collect_parser_error, sb);
+    LoadError err = Load(env, file);
+    SetParserErrorCallback(env, NULL, NULL);
+
+    if (err == LE_OPEN_FILE_ERROR) {
+        SBAppend(sb, file);
+        SBAppend(sb, "\x1f0\x1funable to open file\x1e");
+    } else if (err == LE_PARSING_ERROR && sb->length == 0) {
+        SBAppend(sb, file);
+        SBAppend(sb, "\x1f0\x1fparsing error\x1e");
+    }
+
+    char *result = NULL;
+    if (sb->length > 0) {
+        result = CopyString(env, sb->contents);
+    }
+
+    SBDispose(sb);
+    return result;
+}
+
+void clips_clear_halt(void* env);
+
 void clips_reset(void* env) {
     Reset(env);
 }
 
-void clips_run(void* env) {
-    Run(env, -1);
+// clips_run fires at most limit rules (no limit if negative). It returns 0 when the agenda is exhausted, 1 when
+// the limit is reached with activations still pending, 2 when the execution is halted, by clips_halt or by the
+// halt function of a rule, and 3 when it stops on an evaluation error in the actions of a rule.
+int clips_run(void* env, long long limit) {
+    int status = 0;
+
+    ActivateRouter(env, "rulemancer-output");
+    long long fired = Run(env, limit);
+    DeactivateRouter(env, "rulemancer-output");
+
+    if (GetEvaluationError(env)) {
+        status = 3;
+    } else if (GetHaltExecution(env) || GetHaltRules(env)) {
+        status = 2;
+    } else if (limit >= 0 && fired >= limit && GetNextActivation(env, NULL) != NULL) {
+        status = 1;
+    }
+    clips_clear_halt(env);
+
+    return status;
+}
+
+// clips_halt stops the rules execution as soon as possible, it is meant to be called by a watchdog while
+// clips_run is running on another thread
+void clips_halt(void* env) {
+    SetHaltRules(env, true);
+    SetHaltExecution(env, true);
+}
+
+void clips_clear_halt(void* env) {
+    SetHaltRules(env, false);
+    SetHaltExecution(env, false);
+    SetEvaluationError(env, false);
+}
+
+// clips_refresh_logical clears the agenda and puts back only the activations of the rules with a logical
+// conditional element, of every module. Facts copied from another environment then get their logical support
+// again, without firing once more the rules that already fired on them there.
+void clips_refresh_logical(void* env) {
+    Defmodule *current = GetCurrentModule(env);
+
+    for (Defmodule *module = GetNextDefmodule(env, NULL); module != NULL; module = GetNextDefmodule(env, module)) {
+        DeleteAllActivations(module);
+    }
+    for (Defmodule *module = GetNextDefmodule(env, NULL); module != NULL; module = GetNextDefmodule(env, module)) {
+        SetCurrentModule(env, module);
+        for (Defrule *rule = GetNextDefrule(env, NULL); rule != NULL; rule = GetNextDefrule(env, rule)) {
+            for (Defrule *disjunct = rule; disjunct != NULL; disjunct = disjunct->disjunct) {
+                if (disjunct->logicalJoin != NULL) {
+                    Refresh(rule);
+                    break;
+                }
+            }
+        }
+    }
+    SetCurrentModule(env, current);
+}
+
+// clips_take_output returns the output captured since the last call and clears it
+char *clips_take_output(void *env) {
+    StringBuilder *sb = OutputData(env)->sb;
+    char *result = CopyString(env, sb->length > 0 ? sb->contents : "");
+    SBReset(sb);
+    return result;
 }
 
 void clips_assert(void* env, const char* fact) {
@@ -48,6 +199,36 @@ char *find_facts_as_string(void *env, const char *fact_name) {
     return result;
 }
 
+// find_indexed_facts_as_string returns the facts of a relation as "<index> <fact>" records, separated by the
+// ASCII record separator since the pretty printed facts may span several lines
+char *find_indexed_facts_as_string(void *env, const char *fact_name) {
+    StringBuilder *sb = CreateStringBuilder(env, 1024);
+    if (!sb) return NULL;
+    StringBuilder *pp = CreateStringBuilder(env, 256);
+    if (!pp) {
+        SBDispose(sb);
+        return NULL;
+    }
+
+    for (Fact *fact = GetNextFact(env, NULL); fact != NULL; fact = GetNextFact(env, fact)) {
+        CLIPSLexeme *rel = FactRelation(fact);
+        if (rel == NULL || strcmp(rel->contents, fact_name) != 0) continue;
+
+        SBReset(pp);
+        FactPPForm(fact, pp, false);
+        SBAppendInteger(sb, FactIndex(fact));
+        SBAppend(sb, " ");
+        SBAppend(sb, pp->contents);
+        SBAppend(sb, "\x1e");
+    }
+
+    char *result = CopyString(env, sb->length > 0 ? sb->contents : "");
+
+    SBDispose(pp);
+    SBDispose(sb);
+    return result;
+}
+
 char * find_all_facts_as_string(void *env) {
     Fact *fact = GetNextFact(env, NULL);
 
@@ -71,4 +252,125 @@ void clips_free_string(void *env,char *str) {
     if (str != NULL) {
         rm(env, str, strlen(str)+1);
     }
-}
\ No newline at end of file
+}
+static void append_value(StringBuilder *sb, CLIPSValue *cv) {
+    switch (cv->header->type) {
+        case INTEGER_TYPE:
+            SBAppendInteger(sb, cv->integerValue->contents);
+            break;
+        case FLOAT_TYPE:
+            SBAppendFloat(sb, cv->floatValue->contents);
+            break;
+        case STRING_TYPE:
+            SBAppend(sb, "\"");
+            SBAppend(sb, cv->lexemeValue->contents);
+            SBAppend(sb, "\"");
+            break;
+        case SYMBOL_TYPE:
+        case INSTANCE_NAME_TYPE:
+            SBAppend(sb, cv->lexemeValue->contents);
+            break;
+        case MULTIFIELD_TYPE:
+            for (size_t i = 0; i < cv->multifieldValue->length; i++) {
+                if (i > 0) SBAppend(sb, " ");
+                append_value(sb, &cv->multifieldValue->contents[i]);
+            }
+            break;
+        default:
+            break;
+    }
+}
+
+// Every slot of every deftemplate is written as a record terminated by \x1e, the fields of the record are
+// separated by \x1f: template, slot, multi (0|1), types, allowed values, range, default type, default value.
+char *clips_templates_as_string(void *env) {
+    StringBuilder *sb = CreateStringBuilder(env, 1024);
+    if (!sb) return NULL;
+
+    for (Deftemplate *dt = GetNextDeftemplate(env, NULL); dt != NULL; dt = GetNextDeftemplate(env, dt)) {
+        CLIPSValue names;
+        DeftemplateSlotNames(dt, &names);
+        if (names.header->type != MULTIFIELD_TYPE) continue;
+
+        for (size_t i = 0; i < names.multifieldValue->length; i++) {
+            const char *slot = names.multifieldValue->contents[i].lexemeValue->contents;
+            CLIPSValue cv;
+
+            // Ordered facts have a single implied multislot, they have no schema to expose
+            if (names.multifieldValue->length == 1 && strcmp(slot, "implied") == 0) break;
+
+            SBAppend(sb, DeftemplateName(dt));
+            SBAppend(sb, "\x1f");
+            SBAppend(sb, slot);
+            SBAppend(sb, "\x1f");
+            SBAppend(sb, DeftemplateSlotMultiP(dt, slot) ? "1" : "0");
+            SBAppend(sb, "\x1f");
+            if (DeftemplateSlotTypes(dt, slot, &cv)) append_value(sb, &cv);
+            SBAppend(sb, "\x1f");
+            if (DeftemplateSlotAllowedValues(dt, slot, &cv) && cv.header->type == MULTIFIELD_TYPE) append_value(sb, &cv);
+            SBAppend(sb, "\x1f");
+            if (DeftemplateSlotRange(dt, slot, &cv) && cv.header->type == MULTIFIELD_TYPE) append_value(sb, &cv);
+            SBAppend(sb, "\x1f");
+            switch (DeftemplateSlotDefaultP(dt, slot)) {
+                case NO_DEFAULT:
+                    SBAppend(sb, "none");
+                    break;
+                case STATIC_DEFAULT:
+                    SBAppend(sb, "static");
+                    break;
+                case DYNAMIC_DEFAULT:
+                    SBAppend(sb, "dynamic");
+                    break;
+            }
+            SBAppend(sb, "\x1f");
+            if (DeftemplateSlotDefaultValue(dt, slot, &cv)) append_value(sb, &cv);
+            SBAppend(sb, "\x1e");
+        }
+    }
+
+    char *result = CopyString(env, sb->contents);
+
+    SBDispose(sb);
+    return result;
+}
+
+// clips_eval evaluates an expression, what it prints (errors included) is captured as the rules output. It
+// returns the printed form of the result, or NULL if the expression cannot be parsed or evaluated.
+char *clips_eval(void *env, const char *expr) {
+    CLIPSValue cv;
+
+    OutputData(env)->errors = true;
+    ActivateRouter(env, "rulemancer-output");
+    EvalError err = Eval(env, expr, &cv);
+    DeactivateRouter(env, "rulemancer-output");
+    OutputData(env)->errors = false;
+    clips_clear_halt(env);
+
+    if (err != EE_NO_ERROR) return NULL;
+
+    StringBuilder *sb = CreateStringBuilder(env, 256);
+    if (!sb) return NULL;
+
+    switch (cv.header->type) {
+        case MULTIFIELD_TYPE:
+            SBAppend(sb, "(");
+            append_value(sb, &cv);
+            SBAppend(sb, ")");
+            break;
+        case FACT_ADDRESS_TYPE:
+            SBAppend(sb, "<Fact-");
+            SBAppendInteger(sb, FactIndex(cv.factValue));
+            SBAppend(sb, ">");
+            break;
+        case VOID_TYPE:
+            break;
+        default:
+            append_value(sb, &cv);
+            break;
+    }
+
+    char *result = CopyString(env, sb->contents);
+
+    SBDispose(sb);
+    return result;
+}